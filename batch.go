@@ -0,0 +1,195 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrMissingResponse is set as the Err of a BatchRequest for which the server didn't return a response.
+var ErrMissingResponse = errors.New("rpcclient: missing response in batch")
+
+// ErrUnmatchedResponse is returned by SendBatch if the server returned a response which doesn't match a request, like
+// the error bitcoind returns with a null ID for a malformed request.
+var ErrUnmatchedResponse = errors.New("rpcclient: response in batch matches no request")
+
+// BatchRequest is one of the requests sent together with SendBatch.
+type BatchRequest struct {
+	// Method and Params are the method and params of the request, like those passed to SendReq.
+	Method string
+	Params []any
+
+	// Result is the pointer the result is decoded into, like the result passed to SendReq. It may be nil.
+	Result any
+
+	// Err is set by SendBatch to the error of this request, eg an *RPCError, or nil if it succeeded.
+	Err error
+}
+
+// BatchSendFunc sends the requests of a batch to the RPC server, and decodes their results into the requests.
+type BatchSendFunc func(ctx context.Context, reqs []*BatchRequest) error
+
+// BatchInterceptor intercepts the batches of a Client, like an Interceptor intercepts single requests. It is called
+// with the requests of every batch, and next, which sends them to the next interceptor or the server. The errors of
+// the requests are set when next returns.
+type BatchInterceptor func(ctx context.Context, reqs []*BatchRequest, next BatchSendFunc) error
+
+// batchRequest is a request in the JSON array sent to the server. Unlike single requests, it has an ID, so the
+// responses can be matched to the requests.
+type batchRequest struct {
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+	Params []any  `json:"params"`
+}
+
+// batchResponse is a response in the JSON array returned by the server. ID is nil if the server couldn't read the ID of
+// the request.
+type batchResponse struct {
+	ID     *int            `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// chainBatchInterceptors returns a BatchSendFunc which calls the interceptors around send. The first interceptor is
+// the outermost.
+func chainBatchInterceptors(send BatchSendFunc, interceptors []BatchInterceptor) BatchSendFunc {
+	for idx := len(interceptors) - 1; idx >= 0; idx-- {
+		interceptor, next := interceptors[idx], send
+		send = func(ctx context.Context, reqs []*BatchRequest) error {
+			return interceptor(ctx, reqs, next)
+		}
+	}
+
+	return send
+}
+
+// SendBatch sends reqs to the RPC server in a single JSON-RPC batch, and sets the Result and Err of every request.
+// The returned error is only for failures of the batch as a whole, like a server which can't be reached, in which
+// case the requests are left unchanged.
+//
+// Batches pass through Config.BatchInterceptors, not Config.Interceptors. They are retried like single requests if
+// every method is read-only, and are neither deduplicated nor streamed. Clients created with NewWithSendFunc send the
+// requests one by one, through their SendFunc.
+func (c *Client) SendBatch(ctx context.Context, reqs []*BatchRequest) error {
+	if c.sendBatch == nil {
+		return c.sendEach(ctx, reqs)
+	}
+
+	return c.sendBatch(ctx, reqs)
+}
+
+// sendEach sends reqs one by one with the client's SendFunc.
+func (c *Client) sendEach(ctx context.Context, reqs []*BatchRequest) error {
+	for _, req := range reqs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		req.Err = c.send(ctx, req.Method, req.Result, req.Params...)
+	}
+
+	return nil
+}
+
+// sendBatchHTTP sends reqs to the RPC server in Config.Host in one HTTP request.
+func (c *Client) sendBatchHTTP(ctx context.Context, reqs []*BatchRequest) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	rawReqs := make([]*batchRequest, len(reqs))
+	readOnly := true
+
+	// The response may be as large as the largest response allowed for one of the requests.
+	limit, unlimited := int64(0), false
+
+	// The batch is queued with the highest priority of its requests, and costs as much as all of them.
+	priority, cost := PriorityLow, 0.0
+
+	for idx, req := range reqs {
+		rawReqs[idx] = &batchRequest{
+			ID:     idx,
+			Method: req.Method,
+			Params: append(make([]any, 0, len(req.Params)), req.Params...),
+		}
+
+		readOnly = readOnly && isReadOnly(req.Method)
+		priority = max(priority, c.priority(ctx, req.Method))
+		cost += c.cost(req.Method)
+
+		size := c.maxResponseSize(req.Method)
+		unlimited = unlimited || size <= 0
+		limit = max(limit, size)
+	}
+
+	if unlimited {
+		limit = 0
+	}
+
+	reqBody, err := json.Marshal(rawReqs)
+	if err != nil {
+		return err
+	}
+
+	var rawResps []*batchResponse
+
+	err = c.retry(ctx, readOnly, func() error {
+		return c.post(ctx, priority, cost, reqBody, limit, func(dec *json.Decoder) error {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+
+			// Bitcoind answers a batch it can't parse with a single error instead of an array.
+			rawResp := &Response{}
+			if err := json.Unmarshal(raw, rawResp); err == nil && rawResp.Error != nil {
+				return rawResp.Error
+			}
+
+			return json.Unmarshal(raw, &rawResps)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// The responses are matched first, so the requests are left unchanged if one of them doesn't match.
+	matched := make([]*batchResponse, len(reqs))
+
+	for _, rawResp := range rawResps {
+		if rawResp == nil {
+			return fmt.Errorf("%w: null response", ErrUnmatchedResponse)
+		}
+
+		if rawResp.ID == nil || *rawResp.ID < 0 || *rawResp.ID >= len(reqs) || matched[*rawResp.ID] != nil {
+			if rawResp.Error != nil {
+				return fmt.Errorf("%w: %w", ErrUnmatchedResponse, rawResp.Error)
+			}
+
+			return fmt.Errorf("%w: unexpected id", ErrUnmatchedResponse)
+		}
+
+		matched[*rawResp.ID] = rawResp
+	}
+
+	for idx, rawResp := range matched {
+		req := reqs[idx]
+
+		switch {
+		case rawResp == nil:
+			req.Err = ErrMissingResponse
+		case rawResp.Error != nil:
+			req.Err = rawResp.Error
+		case req.Result != nil && len(rawResp.Result) > 0:
+			req.Err = json.Unmarshal(rawResp.Result, req.Result)
+		default:
+			req.Err = nil
+		}
+	}
+
+	return nil
+}
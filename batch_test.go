@@ -0,0 +1,171 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBatchServer starts a server which answers every batch with the JSON encoding of respond(reqs).
+func newBatchServer(t *testing.T, config *Config, respond func(reqs []*batchRequest) any) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []*batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(respond(reqs))
+	}))
+	t.Cleanup(server.Close)
+
+	if config == nil {
+		config = &Config{}
+	}
+
+	config.Host = server.URL
+	config.DisableTLS = true
+
+	client, err := New(config)
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestClient_SendBatch(t *testing.T) {
+	var received [][]*batchRequest
+
+	var calls []string
+	record := func(name string) BatchInterceptor {
+		return func(ctx context.Context, reqs []*BatchRequest, next BatchSendFunc) error {
+			calls = append(calls, name)
+			return next(ctx, reqs)
+		}
+	}
+
+	client := newBatchServer(t, &Config{
+		BatchInterceptors: []BatchInterceptor{record("outer"), record("inner")},
+	}, func(reqs []*batchRequest) any {
+		received = append(received, reqs)
+
+		// Answer in reverse order and leave out the last request, to check responses are matched by ID.
+		resps := make([]map[string]any, 0, len(reqs))
+		for idx := len(reqs) - 2; idx >= 0; idx-- {
+			resp := map[string]any{"id": reqs[idx].ID, "result": nil, "error": nil}
+			if reqs[idx].Method == "getblockhash" {
+				resp["error"] = &RPCError{Code: -8, Message: "Block height out of range"}
+			} else {
+				resp["result"] = reqs[idx].Method
+			}
+
+			resps = append(resps, resp)
+		}
+
+		return resps
+	})
+
+	var bestHash, count string
+	reqs := []*BatchRequest{
+		{Method: "getbestblockhash", Result: &bestHash},
+		{Method: "getblockhash", Params: []any{1000}},
+		{Method: "getblockcount", Result: &count},
+		{Method: "getdifficulty"},
+	}
+
+	require.NoError(t, client.SendBatch(context.Background(), reqs))
+	assert.Equal(t, []string{"outer", "inner"}, calls)
+
+	require.Len(t, received, 1)
+	assert.Equal(t, &batchRequest{ID: 1, Method: "getblockhash", Params: []any{float64(1000)}}, received[0][1])
+
+	assert.NoError(t, reqs[0].Err)
+	assert.Equal(t, "getbestblockhash", bestHash)
+	assert.Equal(t, &RPCError{Code: -8, Message: "Block height out of range"}, reqs[1].Err)
+	assert.NoError(t, reqs[2].Err)
+	assert.Equal(t, "getblockcount", count)
+	assert.ErrorIs(t, reqs[3].Err, ErrMissingResponse)
+
+	require.NoError(t, client.SendBatch(context.Background(), nil))
+	assert.Len(t, received, 1)
+}
+
+func TestClient_SendBatchWithSendFunc(t *testing.T) {
+	var sent []string
+	client := NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		sent = append(sent, method)
+		if method == "getblockhash" {
+			return &RPCError{Code: -8, Message: "Block height out of range"}
+		}
+
+		*result.(*int64) = 42
+
+		return nil
+	})
+
+	var count int64
+	reqs := []*BatchRequest{
+		{Method: "getblockcount", Result: &count},
+		{Method: "getblockhash", Params: []any{1000}},
+	}
+
+	require.NoError(t, client.SendBatch(context.Background(), reqs))
+	assert.Equal(t, []string{"getblockcount", "getblockhash"}, sent)
+	assert.Equal(t, int64(42), count)
+	assert.NoError(t, reqs[0].Err)
+	assert.Equal(t, &RPCError{Code: -8, Message: "Block height out of range"}, reqs[1].Err)
+}
+
+func TestClient_SendBatchMaxResponseSizes(t *testing.T) {
+	block := strings.Repeat("00", 200)
+
+	client := newBatchServer(t, &Config{
+		MaxResponseSize:  100,
+		MaxResponseSizes: map[string]int64{"getblock": 1000},
+	}, func(reqs []*batchRequest) any {
+		resps := make([]map[string]any, len(reqs))
+		for idx, req := range reqs {
+			resps[idx] = map[string]any{"id": req.ID, "result": block}
+		}
+
+		return resps
+	})
+
+	// The batch may be as large as the limit of getblock.
+	reqs := []*BatchRequest{{Method: "getblock", Params: []any{"00"}}, {Method: "getblockcount"}}
+	require.NoError(t, client.SendBatch(context.Background(), reqs))
+	assert.NoError(t, reqs[0].Err)
+
+	reqs = []*BatchRequest{{Method: "getblockcount"}, {Method: "getblockcount"}}
+	assert.ErrorIs(t, client.SendBatch(context.Background(), reqs), ErrResponseTooLarge)
+}
+
+func TestClient_SendBatchUnmatchedResponse(t *testing.T) {
+	client := newBatchServer(t, nil, func(reqs []*batchRequest) any {
+		return []map[string]any{
+			{"id": reqs[0].ID, "result": 42},
+			{"id": nil, "error": &RPCError{Code: -32600, Message: "Invalid Request object"}},
+		}
+	})
+
+	var count int64
+	reqs := []*BatchRequest{{Method: "getblockcount", Result: &count}, {Method: "getblockhash"}}
+
+	err := client.SendBatch(context.Background(), reqs)
+	assert.ErrorIs(t, err, ErrUnmatchedResponse)
+
+	var rpcErr *RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, -32600, rpcErr.Code)
+
+	// The null ID isn't taken for the first request, and the requests are left unchanged.
+	assert.Zero(t, count)
+	assert.NoError(t, reqs[0].Err)
+	assert.NoError(t, reqs[1].Err)
+}
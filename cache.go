@@ -0,0 +1,380 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// Enforce CachingClient has to be implementation of IClient.
+var _ IClient = &CachingClient{}
+
+// Defaults for the options in CacheConfig.
+const (
+	defaultCacheEntries     = 10000
+	defaultMinConfirmations = 6
+	defaultReorgDepth       = 100
+	defaultTipInterval      = 5 * time.Second
+)
+
+// CacheConfig are the options for a CachingClient.
+type CacheConfig struct {
+	// Client is the client whose results are cached.
+	Client *Client
+
+	// Store holds the cached results. Defaults to an LRU store with 10000 entries.
+	Store CacheStore
+
+	// MinConfirmations is the number of confirmations a block or transaction needs before it is cached. Defaults to 6.
+	MinConfirmations int
+
+	// ReorgDepth is the depth up to which cached blocks are checked when the chain reorganizes. Blocks which are buried
+	// deeper are assumed to be final. Defaults to 100.
+	ReorgDepth int
+
+	// TipInterval is how often the chain tip is checked for reorganizations. Defaults to 5 seconds.
+	TipInterval time.Duration
+}
+
+// CacheStats are the counters of a CachingClient.
+type CacheStats struct {
+	// Hits is the number of requests answered from the cache.
+	Hits uint64
+	// Misses is the number of cacheable requests sent to the node.
+	Misses uint64
+	// Invalidations is the number of entries removed because their block is no longer in the main chain.
+	Invalidations uint64
+}
+
+// CachingClient is an IClient which caches decoded blocks, block headers and transactions once they have enough
+// confirmations. The chain tip is tracked, and entries of blocks that are reorganized out of the main chain are
+// removed from the cache. All other requests and streamed results are passed through.
+type CachingClient struct {
+	*Client
+
+	config *CacheConfig
+	store  CacheStore
+
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+
+	// mu guards the chain tip and the tracked blocks. It is never held during requests.
+	mu        sync.Mutex
+	tipHash   string
+	tipHeight int64
+	tipAt     time.Time
+	// refreshing is set while a request refreshes the tip, so other requests use the previous tip meanwhile.
+	refreshing bool
+	// tracked are the cached blocks which are not yet buried deeper than ReorgDepth, by block hash.
+	tracked map[string]*trackedBlock
+}
+
+// trackedBlock holds the cache keys of the entries of a block.
+type trackedBlock struct {
+	height int64
+	keys   map[string]bool
+}
+
+// cacheEntry is the value stored in the CacheStore.
+type cacheEntry struct {
+	Block  string          `json:"block"`
+	Height int64           `json:"height"`
+	Result json.RawMessage `json:"result"`
+}
+
+// NewCachingClient creates a new *CachingClient based on the provided config.
+func NewCachingClient(config *CacheConfig) *CachingClient {
+	c := &CachingClient{
+		config:  config,
+		store:   config.Store,
+		tracked: map[string]*trackedBlock{},
+	}
+
+	if c.store == nil {
+		c.store = NewLRUStore(defaultCacheEntries)
+	}
+
+	c.Client = NewWithSendFunc(c.send)
+
+	return c
+}
+
+// Stats returns the counters of the cache.
+func (c *CachingClient) Stats() CacheStats {
+	return CacheStats{
+		Hits:          atomic.LoadUint64(&c.hits),
+		Misses:        atomic.LoadUint64(&c.misses),
+		Invalidations: atomic.LoadUint64(&c.invalidations),
+	}
+}
+
+// isCacheable reports whether the request returns a decoded block, block header or transaction.
+func isCacheable(method string, params []any) bool {
+	if len(params) < 2 {
+		return false
+	}
+
+	verbosity := fmt.Sprint(params[1])
+
+	switch method {
+	case "getblock":
+		return verbosity == "1" || verbosity == "2"
+	case "getblockheader", "getrawtransaction":
+		return verbosity == "true" || verbosity == "1"
+	}
+
+	return false
+}
+
+// send is the SendFunc of the CachingClient.
+func (c *CachingClient) send(ctx context.Context, method string, result any, params ...any) error {
+	if _, ok := result.(StreamResult); ok || !isCacheable(method, params) {
+		return c.config.Client.SendReqContext(ctx, method, result, params...)
+	}
+
+	tipHeight, err := c.refreshTip(ctx)
+	if err != nil {
+		// Without the tip, cached entries can't be checked for reorganizations.
+		return c.config.Client.SendReqContext(ctx, method, result, params...)
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	key := method + string(paramsJSON)
+
+	if entry, ok := c.lookup(ctx, key, tipHeight); ok {
+		atomic.AddUint64(&c.hits, 1)
+
+		res, err := withConfirmations(entry.Result, tipHeight-entry.Height+1)
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(res, result)
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+
+	var raw json.RawMessage
+	if err := c.config.Client.SendReqContext(ctx, method, &raw, params...); err != nil {
+		return err
+	}
+
+	var meta struct {
+		Hash          string `json:"hash"`
+		Height        *int64 `json:"height"`
+		BlockHash     string `json:"blockhash"`
+		Confirmations int64  `json:"confirmations"`
+	}
+
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return err
+	}
+
+	minConfirmations := int64(c.config.MinConfirmations)
+	if minConfirmations <= 0 {
+		minConfirmations = defaultMinConfirmations
+	}
+
+	if meta.Confirmations >= minConfirmations {
+		entry := cacheEntry{Block: meta.Hash, Result: raw}
+		if method == "getrawtransaction" {
+			entry.Block, entry.Height = meta.BlockHash, tipHeight-meta.Confirmations+1
+		} else if meta.Height != nil {
+			entry.Height = *meta.Height
+		}
+
+		if value, err := json.Marshal(entry); err == nil {
+			c.store.Set(key, value)
+			c.track(entry.Block, entry.Height, key, tipHeight)
+		}
+	}
+
+	return json.Unmarshal(raw, result)
+}
+
+// withConfirmations returns result with its confirmations set to confirmations, as they grow with every block after the
+// result was cached. Results without confirmations are returned unchanged.
+func withConfirmations(result json.RawMessage, confirmations int64) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(result, &fields); err != nil {
+		return nil, err
+	}
+
+	if _, ok := fields["confirmations"]; !ok {
+		return result, nil
+	}
+
+	fields["confirmations"] = json.RawMessage(strconv.FormatInt(confirmations, 10))
+
+	return json.Marshal(fields)
+}
+
+// lookup returns the entry for key from the store. Entries of blocks which aren't tracked yet, eg because they were
+// stored by a previous process, are checked to still be in the main chain before they are used.
+func (c *CachingClient) lookup(ctx context.Context, key string, tipHeight int64) (*cacheEntry, bool) {
+	value, ok := c.store.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(value, entry); err != nil {
+		return nil, false
+	}
+
+	if entry.Height > tipHeight-c.reorgDepth() && !c.isTracked(entry.Block) && !c.inMainChain(ctx, entry.Block) {
+		c.store.Delete(key)
+		atomic.AddUint64(&c.invalidations, 1)
+
+		return nil, false
+	}
+
+	c.track(entry.Block, entry.Height, key, tipHeight)
+
+	return entry, true
+}
+
+// isTracked reports whether block is tracked.
+func (c *CachingClient) isTracked(block string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.tracked[block]
+
+	return ok
+}
+
+// inMainChain reports whether block is in the main chain. Errors are treated as the block not being in the main chain,
+// as removing an entry from the cache is always safe.
+func (c *CachingClient) inMainChain(ctx context.Context, block string) bool {
+	var header *types.BlockHeader
+	err := c.config.Client.SendReqContext(ctx, "getblockheader", &header, block, true)
+
+	return err == nil && header.Confirmations >= 0
+}
+
+// track records that the entry for key belongs to block, so it is removed if the block is reorganized.
+func (c *CachingClient) track(block string, height int64, key string, tipHeight int64) {
+	if height <= tipHeight-c.reorgDepth() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tracked, ok := c.tracked[block]
+	if !ok {
+		tracked = &trackedBlock{height: height, keys: map[string]bool{}}
+		c.tracked[block] = tracked
+	}
+
+	tracked.keys[key] = true
+}
+
+// reorgDepth returns CacheConfig.ReorgDepth or its default.
+func (c *CachingClient) reorgDepth() int64 {
+	if c.config.ReorgDepth <= 0 {
+		return defaultReorgDepth
+	}
+
+	return int64(c.config.ReorgDepth)
+}
+
+// refreshTip checks the chain tip if it hasn't been checked for TipInterval, and returns the height of the tip. If the
+// new tip doesn't extend the previous one, the tracked blocks are checked and the entries of the blocks which are no
+// longer in the main chain are removed. While the tip is being refreshed, other requests use the previous tip.
+func (c *CachingClient) refreshTip(ctx context.Context) (int64, error) {
+	interval := c.config.TipInterval
+	if interval <= 0 {
+		interval = defaultTipInterval
+	}
+
+	c.mu.Lock()
+	prevHash, prevHeight := c.tipHash, c.tipHeight
+
+	if prevHash != "" && (c.refreshing || time.Since(c.tipAt) < interval) {
+		c.mu.Unlock()
+
+		return prevHeight, nil
+	}
+
+	c.refreshing = true
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.refreshing = false
+		c.mu.Unlock()
+	}()
+
+	var info *types.BlockChainInfo
+	if err := c.config.Client.SendReqContext(ctx, "getblockchaininfo", &info); err != nil {
+		return 0, err
+	}
+
+	if info.BestBlockHash == prevHash {
+		c.mu.Lock()
+		c.tipAt = time.Now()
+		c.mu.Unlock()
+
+		return prevHeight, nil
+	}
+
+	extends := false
+	if prevHash != "" {
+		var header *types.BlockHeader
+		err := c.config.Client.SendReqContext(ctx, "getblockheader", &header, info.BestBlockHash, true)
+		extends = err == nil && header.Previousblockhash == prevHash
+	}
+
+	height := int64(info.Blocks)
+
+	var check []string
+
+	c.mu.Lock()
+	for hash, tracked := range c.tracked {
+		if tracked.height <= height-c.reorgDepth() {
+			delete(c.tracked, hash)
+			continue
+		}
+
+		if !extends && prevHash != "" {
+			check = append(check, hash)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, hash := range check {
+		if c.inMainChain(ctx, hash) {
+			continue
+		}
+
+		c.mu.Lock()
+		if tracked, ok := c.tracked[hash]; ok {
+			for key := range tracked.keys {
+				c.store.Delete(key)
+				atomic.AddUint64(&c.invalidations, 1)
+			}
+
+			delete(c.tracked, hash)
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	c.tipHash, c.tipHeight, c.tipAt = info.BestBlockHash, height, time.Now()
+	c.mu.Unlock()
+
+	return height, nil
+}
@@ -0,0 +1,148 @@
+package rpcclient
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore is the storage of a CachingClient. Implementations must be safe for concurrent use. Failures are treated
+// as a missing entry, so a store may drop entries at any time.
+type CacheStore interface {
+	// Get returns the value for key, and whether it was found.
+	Get(key string) ([]byte, bool)
+	// Set stores value for key.
+	Set(key string, value []byte)
+	// Delete removes the value for key.
+	Delete(key string)
+}
+
+// lruStore is an in-memory CacheStore which evicts the least recently used entries.
+type lruStore struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// lruEntry is an element of lruStore.order.
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// NewLRUStore creates an in-memory CacheStore which holds up to maxEntries entries, evicting the least recently used
+// entry when it is full.
+func NewLRUStore(maxEntries int) CacheStore {
+	return &lruStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+// Get implements CacheStore.
+func (s *lruStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	s.order.MoveToFront(elem)
+
+	return elem.Value.(*lruEntry).value, true
+}
+
+// Set implements CacheStore.
+func (s *lruStore) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		elem.Value.(*lruEntry).value = value
+		s.order.MoveToFront(elem)
+
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, value: value})
+
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Delete implements CacheStore.
+func (s *lruStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+	}
+}
+
+// diskStore is a CacheStore which stores every entry in a file in a directory.
+type diskStore struct {
+	dir string
+}
+
+// NewDiskStore creates a CacheStore which stores every entry in a file in dir, so the cache survives restarts. The
+// directory is created if it doesn't exist. Entries are never evicted.
+func NewDiskStore(dir string) (CacheStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &diskStore{dir: dir}, nil
+}
+
+// path returns the path of the file for key.
+func (s *diskStore) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return filepath.Join(s.dir, hex.EncodeToString(hash[:]))
+}
+
+// Get implements CacheStore.
+func (s *diskStore) Get(key string) ([]byte, bool) {
+	value, err := os.ReadFile(s.path(key))
+
+	return value, err == nil
+}
+
+// Set implements CacheStore. The value is written to a temporary file first, so readers never see a partial entry.
+func (s *diskStore) Set(key string, value []byte) {
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(key))
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// Delete implements CacheStore.
+func (s *diskStore) Delete(key string) {
+	_ = os.Remove(s.path(key))
+}
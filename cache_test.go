@@ -0,0 +1,273 @@
+package rpcclient
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testChain is a fake node for CachingClient tests, which counts the requests for each method.
+type testChain struct {
+	mu    sync.Mutex
+	main  []string
+	stale map[string]int
+	calls map[string]int
+
+	// tipGate holds back getblockchaininfo until it is closed, if set.
+	tipGate chan struct{}
+	// tipRequested is closed when a held back getblockchaininfo arrives.
+	tipRequested chan struct{}
+}
+
+// newTestChain creates a chain of n blocks named prefix1 to prefixn, after a genesis block.
+func newTestChain(prefix string, n int) *testChain {
+	c := &testChain{main: []string{"genesis"}, stale: map[string]int{}, calls: map[string]int{}}
+	c.extend(prefix, n)
+
+	return c
+}
+
+// extend appends n blocks named after prefix and their height.
+func (c *testChain) extend(prefix string, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for idx := 0; idx < n; idx++ {
+		c.main = append(c.main, fmt.Sprintf("%v%v", prefix, len(c.main)))
+	}
+}
+
+// reorg replaces the blocks from height onwards with n blocks named after prefix.
+func (c *testChain) reorg(height int, prefix string, n int) {
+	c.mu.Lock()
+	for h := height; h < len(c.main); h++ {
+		c.stale[c.main[h]] = h
+	}
+	c.main = c.main[:height]
+	c.mu.Unlock()
+
+	c.extend(prefix, n)
+}
+
+// count returns the number of requests for method.
+func (c *testChain) count(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[method]
+}
+
+// header returns the verbose header of hash.
+func (c *testChain) header(hash string) (map[string]any, *RPCError) {
+	for height, main := range c.main {
+		if main == hash {
+			header := map[string]any{"hash": hash, "height": height, "confirmations": len(c.main) - height}
+			if height > 0 {
+				header["previousblockhash"] = c.main[height-1]
+			}
+
+			return header, nil
+		}
+	}
+
+	if height, ok := c.stale[hash]; ok {
+		return map[string]any{"hash": hash, "height": height, "confirmations": -1}, nil
+	}
+
+	return nil, &RPCError{Code: -5, Message: "Block not found"}
+}
+
+// client returns a client connected to the chain. Transactions are named tx-<blockhash>.
+func (c *testChain) client(t *testing.T) *Client {
+	return newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		c.mu.Lock()
+		gate, requested := c.tipGate, c.tipRequested
+		c.mu.Unlock()
+
+		if req.Method == "getblockchaininfo" && gate != nil {
+			close(requested)
+			<-gate
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.calls[req.Method]++
+
+		switch req.Method {
+		case "getblockchaininfo":
+			return map[string]any{"blocks": len(c.main) - 1, "bestblockhash": c.main[len(c.main)-1]}, nil
+		case "getblockheader", "getblock":
+			return c.header(req.Params[0].(string))
+		case "getrawtransaction":
+			txid := req.Params[0].(string)
+			header, err := c.header(txid[len("tx-"):])
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]any{"txid": txid, "blockhash": header["hash"], "confirmations": header["confirmations"]}, nil
+		}
+
+		return nil, &RPCError{Code: -32601, Message: "Method not found"}
+	})
+}
+
+func TestCachingClient(t *testing.T) {
+	chain := newTestChain("a", 10)
+	c := NewCachingClient(&CacheConfig{Client: chain.client(t), TipInterval: 1})
+
+	for idx := 0; idx < 3; idx++ {
+		block, err := c.GetBlockVerbose("a2")
+		require.NoError(t, err)
+		assert.Equal(t, "a2", block.Hash)
+
+		header, err := c.GetBlockHeaderVerbose("a9")
+		require.NoError(t, err)
+		assert.Equal(t, 2, header.Confirmations)
+
+		tx, err := c.GetRawTransactionVerbose("tx-a4", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "a4", tx.Blockhash)
+	}
+
+	assert.Equal(t, 1, chain.count("getblock"))
+	assert.Equal(t, 3, chain.count("getblockheader"))
+	assert.Equal(t, 1, chain.count("getrawtransaction"))
+	assert.Equal(t, CacheStats{Hits: 4, Misses: 5}, c.Stats())
+
+	_, err := c.GetBlock("a2")
+	assert.Error(t, err)
+	assert.Equal(t, CacheStats{Hits: 4, Misses: 5}, c.Stats())
+}
+
+func TestCachingClient_Confirmations(t *testing.T) {
+	chain := newTestChain("a", 10)
+	c := NewCachingClient(&CacheConfig{Client: chain.client(t), TipInterval: 1})
+
+	block, err := c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+	assert.Equal(t, 9, block.Confirmations)
+
+	tx, err := c.GetRawTransactionVerbose("tx-a4", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 7, tx.Confirmations)
+
+	// The cached results get the confirmations of the new tip.
+	chain.extend("a", 20)
+
+	block, err = c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+	assert.Equal(t, 29, block.Confirmations)
+
+	tx, err = c.GetRawTransactionVerbose("tx-a4", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 27, tx.Confirmations)
+
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2}, c.Stats())
+}
+
+func TestCachingClient_RefreshTip(t *testing.T) {
+	chain := newTestChain("a", 10)
+	c := NewCachingClient(&CacheConfig{Client: chain.client(t), TipInterval: 1})
+
+	_, err := c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+
+	chain.mu.Lock()
+	chain.tipGate, chain.tipRequested = make(chan struct{}), make(chan struct{})
+	chain.mu.Unlock()
+
+	done := make(chan error)
+	go func() {
+		_, err := c.GetBlockVerbose("a2")
+		done <- err
+	}()
+
+	<-chain.tipRequested
+
+	// While the tip is refreshed, cached results are served with the previous tip.
+	block, err := c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+	assert.Equal(t, 9, block.Confirmations)
+
+	close(chain.tipGate)
+	require.NoError(t, <-done)
+	assert.Equal(t, 2, chain.count("getblockchaininfo"))
+}
+
+func TestCachingClient_Reorg(t *testing.T) {
+	chain := newTestChain("a", 10)
+	c := NewCachingClient(&CacheConfig{Client: chain.client(t), TipInterval: 1, MinConfirmations: 3})
+
+	_, err := c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+	_, err = c.GetRawTransactionVerbose("tx-a6", nil)
+	require.NoError(t, err)
+
+	chain.extend("a", 1)
+	_, err = c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, chain.count("getblockheader"), "an extension of the tip must not check the cached blocks")
+
+	chain.reorg(5, "b", 8)
+	_, err = c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), c.Stats().Invalidations)
+
+	tx, err := c.GetRawTransactionVerbose("tx-a6", nil)
+	require.NoError(t, err)
+	assert.Equal(t, -1, tx.Confirmations)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 3, Invalidations: 1}, c.Stats())
+}
+
+func TestCachingClient_DiskStore(t *testing.T) {
+	dir := t.TempDir()
+	chain := newTestChain("a", 10)
+
+	store, err := NewDiskStore(dir)
+	require.NoError(t, err)
+
+	c := NewCachingClient(&CacheConfig{Client: chain.client(t), Store: store})
+	_, err = c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+	_, err = c.GetBlockVerbose("a3")
+	require.NoError(t, err)
+
+	chain.reorg(3, "b", 8)
+
+	store, err = NewDiskStore(dir)
+	require.NoError(t, err)
+
+	c = NewCachingClient(&CacheConfig{Client: chain.client(t), Store: store})
+	_, err = c.GetBlockVerbose("a2")
+	require.NoError(t, err)
+	_, err = c.GetBlockVerbose("a3")
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Invalidations: 1}, c.Stats())
+	assert.Equal(t, 3, chain.count("getblock"))
+}
+
+func TestLRUStore(t *testing.T) {
+	store := NewLRUStore(2)
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("2"))
+
+	_, ok := store.Get("a")
+	assert.True(t, ok)
+
+	store.Set("c", []byte("3"))
+	_, ok = store.Get("b")
+	assert.False(t, ok)
+
+	value, ok := store.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	store.Delete("a")
+	_, ok = store.Get("a")
+	assert.False(t, ok)
+}
@@ -2,13 +2,13 @@ package rpcclient
 
 import (
 	"bytes"
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"net/url"
+	"time"
 
 	"github.com/omarhachach/rpcclient-core/types"
 )
@@ -101,10 +101,11 @@ type IClient interface {
 	// GetMiningInfo returns mining-related information.
 	GetMiningInfo() (*types.MiningInfo, error)
 	// GetNetworkHashPS returns the estimated network hashes per second based on the latest nblocks.
-	GetNetworkHashPS(nblocks, height int) (int, error)
+	GetNetworkHashPS(nblocks, height int) (float64, error)
 	// PrioritiseTransaction accepts the transaction into mined blocks at a higher (or lower) priority.
 	PrioritiseTransaction(txid string, feeDelate int) (bool, error)
-	// SubmitBlock submits a new block to the network.
+	// SubmitBlock submits a new block to the network. If the node doesn't accept the block as its new tip, a
+	// *SubmitBlockError is returned.
 	SubmitBlock(hexdata string) error
 	// SubmitHeader decodes the hexdata as a header and submits it as a candidate chain tip if valid.
 	SubmitHeader(hexdata string) error
@@ -120,7 +121,7 @@ type IClient interface {
 	// GetTxOutSetInfo returns statistics about the unspect transaction output set.
 	GetTxOutSetInfo() (*types.TransactionOutSetInfo, error)
 	// ScanTxOutSet is experimental. Please read the docs https://developer.bitcoin.org/reference/rpc/scantxoutset.html.
-	ScanTxOutSet(action types.ScanTxOutSetObject, scanObjects ...*types.ScanTxOutSetObject) (*types.ScanTxOutSetDetails, error)
+	ScanTxOutSet(action types.ScanTxOutSetAction, scanObjects ...*types.ScanTxOutSetObject) (*types.ScanTxOutSetDetails, error)
 	// VerifyTxOutProof verifies the proof points to a transaction in a block.
 	VerifyTxOutProof(proof string) ([]string, error)
 	// AnalyzePSBT analyzes and provides information about the current status of a AnalyzePSBTResult and its inputs.
@@ -163,6 +164,9 @@ type IClient interface {
 	// TestMempoolAccept returns the result of mempool acceptance tsts indicating if raw transaction would be accepted by
 	// the mempool.
 	TestMempoolAccept(rawtxs []string, maxfeeRate *float64) ([]*types.TestMempoolAcceptResult, error)
+	// SubmitPackage submits a child transaction with its unconfirmed parents to the mempool, after sorting and
+	// validating the package. If maxFeeRate or maxBurnAmount are nil, will use default.
+	SubmitPackage(rawtxs []string, maxFeeRate, maxBurnAmount *float64) (*types.SubmitPackageResult, error)
 	// UtxoUpdatePSBT updates all segwit inputs and outputs in a PSBT with data from output descriptors, the UTXO set or the
 	// mempool.
 	UtxoUpdatePSBT(psbt string, scanObjects ...*types.ScanTxOutSetObject) (string, error)
@@ -170,6 +174,14 @@ type IClient interface {
 	// EstimateSmartFee estimates the approximate fee per kilobyte needed for a transaction for a transaction to begin
 	// within confTarget blocks. If estimateMode is nil, will use default.
 	EstimateSmartFee(confTarget int, estimateMode *types.EstimateMode) (*types.EstimateSmartFeeResult, error)
+	// EstimateRawFee returns the raw estimates of each horizon of the fee estimator for confTarget blocks. If threshold
+	// is nil, will use default.
+	EstimateRawFee(confTarget int, threshold *float64) (*types.EstimateRawFeeResult, error)
+	// GetDescriptorInfo analyses a descriptor, and returns it in canonical form with its checksum.
+	GetDescriptorInfo(descriptor string) (*types.DescriptorInfo, error)
+	// DeriveAddresses derives the addresses of a descriptor. For ranged descriptors, descRange is the end or the
+	// [begin, end] of the range.
+	DeriveAddresses(descriptor string, descRange []int) ([]string, error)
 }
 
 // Client represents an RPC Client which helps interacting with either a Bitcoin or Litecoin RPC server.
@@ -182,8 +194,26 @@ type Client struct {
 	retryCount int
 
 	config *Config
+
+	// ctx is the context used by SendReq, and thereby by all of the RPC methods. See WithContext.
+	ctx context.Context
+
+	// limiter queues the requests, if Config.MaxConcurrentRequests or Config.RateLimit is set.
+	limiter *limiter
+
+	// send sends the requests of SendReqContext. It is sendHTTP for clients created with New.
+	send SendFunc
+
+	// sendBatch sends the batches of SendBatch. It is nil for clients created with NewWithSendFunc.
+	sendBatch BatchSendFunc
+
+	// flights holds the requests in flight, if Config.DeduplicateRequests is set.
+	flights *flightGroup
 }
 
+// SendFunc sends a request for method with params to the RPC server, and decodes the result into result.
+type SendFunc func(ctx context.Context, method string, result any, params ...any) error
+
 // Config are the options for connecting to the RPC server.
 type Config struct {
 	// Host is the IP and port or FQDN of the RPC server you want to connect to.
@@ -202,14 +232,147 @@ type Config struct {
 	// It is ignored if DisableTLS is true.
 	Certificates []byte
 
+	// CertificatesFile is the path to a PEM-encoded certificate chain used for the TLS connection, in addition to
+	// Certificates. The file is reloaded when it changes, so rotated certificates are picked up without a restart.
+	CertificatesFile string
+
+	// ClientCertificate and ClientKey are the PEM-encoded certificate and private key presented to the server, for
+	// servers or TLS-terminating proxies which require client authentication.
+	ClientCertificate []byte
+	ClientKey         []byte
+
+	// ClientCertificateFile and ClientKeyFile are like ClientCertificate and ClientKey, but are read from disk and
+	// reloaded when they change. They are ignored if ClientCertificate is set.
+	ClientCertificateFile string
+	ClientKeyFile         string
+
+	// ServerName overrides the name used for SNI and to verify the server's certificate, which otherwise is the
+	// hostname in Host.
+	ServerName string
+
+	// PinnedPublicKeys are base64-encoded SHA-256 hashes of the SubjectPublicKeyInfo of trusted certificates.
+	// If set, the server's chain must contain a certificate with one of the keys. See PublicKeyPin.
+	PinnedPublicKeys []string
+
+	// MinTLSVersion is the minimum TLS version to accept, eg tls.VersionTLS13. Defaults to TLS 1.2.
+	MinTLSVersion uint16
+
 	// Proxy, ProxyUser and ProxyPass are for configuring the proxy http.Client should use.
 	// ProxyUser and ProxyPass are ignored if Proxy is empty.
+	// Proxy is a URL with the scheme http or https for an HTTP proxy, or socks5 or socks5h for a SOCKS5 proxy such as
+	// Tor. With socks5h hostnames are resolved by the proxy, which is required to reach .onion hosts.
 	Proxy     string
 	ProxyUser string
 	ProxyPass string
 
+	// ProxyIsolation makes every connection through a SOCKS5 proxy use unique credentials, so Tor routes it over its own
+	// circuit. ProxyPass is replaced by a random password, so only use this with proxies that accept any password.
+	ProxyIsolation bool
+
 	// DisableAutoReconnect specifies whether the client should try to reconnect when the server has been disconnected.
+	// If set, MaxRetries is ignored.
 	DisableAutoReconnect bool
+
+	// MaxRetries is the number of times a read-only request is retried when the server can't be reached, is busy or
+	// is still starting up. Requests which change state are never retried. Zero means no retries.
+	MaxRetries int
+
+	// RetryBackoff is the time to wait before the first retry, which doubles for every retry up to 5 seconds.
+	// Defaults to 100 milliseconds.
+	RetryBackoff time.Duration
+
+	// LongPollTimeout is the maximum amount of time a long-poll of SubscribeBlockTemplates is held open, after which it
+	// is sent again. Defaults to 10 minutes.
+	LongPollTimeout time.Duration
+
+	// DialTimeout is the maximum amount of time a dial will wait for a connect to complete. Defaults to 30 seconds.
+	DialTimeout time.Duration
+
+	// KeepAlive is the interval between TCP keep-alive probes. Defaults to 30 seconds, keep-alive probes are disabled if
+	// negative.
+	KeepAlive time.Duration
+
+	// TLSHandshakeTimeout is the maximum amount of time to wait for a TLS handshake. Defaults to 10 seconds.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout is the maximum amount of time to wait for the response headers after the request has been
+	// written. This includes the time the server spends executing the RPC. Zero means no timeout.
+	ResponseHeaderTimeout time.Duration
+
+	// Timeout is the maximum amount of time a request may take, including reading the response body.
+	// Zero means no timeout. See WithRequestTimeout to override it for a single request.
+	Timeout time.Duration
+
+	// MaxConnsPerHost limits the total number of connections to the RPC server. Zero means no limit.
+	MaxConnsPerHost int
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept open to the RPC server. Defaults to 4, which
+	// is the default of bitcoind's rpcthreads.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept open. Defaults to 90 seconds.
+	IdleConnTimeout time.Duration
+
+	// DisableKeepAlives disables HTTP keep-alives, so every request uses a new connection.
+	DisableKeepAlives bool
+
+	// MaxConcurrentRequests limits the number of requests in flight at once. Requests beyond the limit wait locally for
+	// a slot, instead of filling up bitcoind's work queue (rpcworkqueue) and failing with "Work queue depth exceeded".
+	// Zero means no limit.
+	MaxConcurrentRequests int
+
+	// RateLimit is the number of requests per second sent to the RPC server, using a token bucket. Requests beyond the
+	// limit wait locally, in order of priority. Zero means no limit.
+	RateLimit float64
+
+	// RateBurst is the number of requests which may be sent at once, after the client has been idle. Defaults to
+	// RateLimit, rounded up.
+	RateBurst int
+
+	// MethodCosts are the number of requests a request for each method counts as for RateLimit, so heavy methods can be
+	// limited more than cheap ones. Requests which cost more than RateBurst count as RateBurst requests. Methods which
+	// aren't in the map cost 1, except for a few heavy methods, like scantxoutset and getblockstats.
+	MethodCosts map[string]float64
+
+	// MethodPriorities are the priorities of the requests for each method, when they wait for MaxConcurrentRequests or
+	// RateLimit. Methods which aren't in the map have PriorityNormal, except for latency-sensitive methods, like
+	// sendrawtransaction and estimatesmartfee, which have PriorityHigh. See WithPriority to set the priority of a
+	// single request.
+	MethodPriorities map[string]Priority
+
+	// MaxResponseSize is the maximum size of a response body in bytes. Larger responses fail with ErrResponseTooLarge.
+	// Zero means no limit.
+	MaxResponseSize int64
+
+	// MaxResponseSizes overrides MaxResponseSize for the methods in the map, eg to allow larger responses for getblock
+	// than for other methods. Zero means no limit.
+	MaxResponseSizes map[string]int64
+
+	// DeduplicateRequests makes concurrent identical read-only requests, with the same method, params, request headers,
+	// timeout and priority, share a single request to the server. Methods which change state are never deduplicated.
+	DeduplicateRequests bool
+
+	// Interceptors are called around every request, in order, so the first interceptor is the outermost. See
+	// Interceptor.
+	Interceptors []Interceptor
+
+	// BatchInterceptors are called around every batch sent with SendBatch, in order. See BatchInterceptor.
+	BatchInterceptors []BatchInterceptor
+
+	// Logger logs every request if set, after the Interceptors. The requests of batches are logged one by one, after
+	// the BatchInterceptors. See NewLoggingInterceptor and NewBatchLoggingInterceptor.
+	Logger *slog.Logger
+
+	// LogLevels are the levels at which the requests for each method are logged. Methods which aren't in the map are
+	// logged at slog.LevelDebug.
+	LogLevels map[string]slog.Level
+
+	// HTTPClient is used for the requests if set. All of the transport, TLS and proxy options are ignored.
+	HTTPClient *http.Client
+
+	// Transport is used as the round tripper for the requests if set. All of the transport, TLS and proxy options are
+	// ignored, but Timeout still applies.
+	Transport http.RoundTripper
 }
 
 // New creates a new *Client based on the provided config.
@@ -222,11 +385,56 @@ func New(config *Config) (*Client, error) {
 	client := &Client{
 		config:     config,
 		httpClient: httpClient,
+		ctx:        context.Background(),
+	}
+
+	client.limiter = newLimiter(config)
+
+	client.send = client.sendHTTP
+	if config.DeduplicateRequests {
+		client.flights = &flightGroup{}
+		client.send = client.sendDeduplicated
+	}
+
+	interceptors := config.Interceptors
+	if config.Logger != nil {
+		interceptors = append(interceptors[:len(interceptors):len(interceptors)], NewLoggingInterceptor(config.Logger, config.LogLevels))
 	}
 
+	batchInterceptors := config.BatchInterceptors
+	if config.Logger != nil {
+		batchInterceptors = append(batchInterceptors[:len(batchInterceptors):len(batchInterceptors)],
+			NewBatchLoggingInterceptor(config.Logger, config.LogLevels))
+	}
+
+	client.send = chainInterceptors(client.send, interceptors)
+	client.sendBatch = chainBatchInterceptors(client.sendBatchHTTP, batchInterceptors)
+
 	return client, nil
 }
 
+// NewWithSendFunc creates a new *Client which uses send for all of its requests, instead of sending them to a server
+// over HTTP. This is used to build clients on top of other clients, like MultiClient.
+func NewWithSendFunc(send SendFunc) *Client {
+	return &Client{
+		config: &Config{},
+		ctx:    context.Background(),
+		send:   send,
+	}
+}
+
+// WithContext returns a shallow copy of the client which uses ctx for all of its requests. The copy shares the
+// connections and concurrency limit with the original.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c2 := *c
+	c2.ctx = ctx
+
+	return &c2
+}
+
+// maxDrainSize is the maximum number of bytes read from a response body after the response has been decoded.
+const maxDrainSize = 4 << 10
+
 // Request is a request to the JSON RPC server.
 type Request struct {
 	Method string        `json:"method"`
@@ -246,8 +454,90 @@ type RPCError struct {
 	Message string `json:"message"`
 }
 
+// Error implements the error interface, so an *RPCError can be returned from SendReq and inspected with errors.As.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc response: code %v: %#v", e.Code, e.Message)
+}
+
 // SendReq sends an HTTP POST request to the RPC server.
 func (c *Client) SendReq(method string, result any, params ...any) error {
+	return c.SendReqContext(c.ctx, method, result, params...)
+}
+
+// SendReqContext is like SendReq, but uses ctx for the request instead of the client's context.
+func (c *Client) SendReqContext(ctx context.Context, method string, result any, params ...any) error {
+	return c.send(ctx, method, result, params...)
+}
+
+// sendHTTP sends the request to the RPC server in Config.Host.
+func (c *Client) sendHTTP(ctx context.Context, method string, result any, params ...any) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	// Streamed results may have been passed on partially, and other requests may have been executed already.
+	_, stream := result.(StreamResult)
+
+	return c.retry(ctx, !stream && isReadOnly(method), func() error {
+		return c.sendOnce(ctx, method, result, params)
+	})
+}
+
+// timeoutKey is the context key for the timeout added with WithRequestTimeout.
+type timeoutKey struct{}
+
+// WithRequestTimeout returns a copy of ctx with which requests use timeout instead of Config.Timeout, eg to hold a
+// long-poll open for longer than other requests. Zero means no timeout. Config.ResponseHeaderTimeout still applies, as
+// it is an option of the transport.
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, timeout)
+}
+
+// withTimeout returns a copy of ctx which is cancelled after the timeout of the request.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := c.config.Timeout
+	if override, ok := ctx.Value(timeoutKey{}).(time.Duration); ok {
+		timeout = override
+	}
+
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// retry calls send until it succeeds, fails with an error which isn't retryable, or has been retried
+// Config.MaxRetries times. Requests which aren't retryable are sent once.
+func (c *Client) retry(ctx context.Context, retryable bool, send func() error) error {
+	maxRetries := c.config.MaxRetries
+	if !retryable || c.config.DisableAutoReconnect {
+		maxRetries = 0
+	}
+
+	backoff := c.retryBackoff()
+
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil || attempt >= maxRetries || !isRetryable(err) {
+			return err
+		}
+
+		if info := CallInfoFromContext(ctx); info != nil {
+			info.Retries++
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// sendOnce sends the request to the RPC server in Config.Host, without retrying it.
+func (c *Client) sendOnce(ctx context.Context, method string, result any, params []any) error {
 	rawReq := &Request{
 		Method: method,
 		Params: make([]interface{}, 0, len(params)),
@@ -260,7 +550,40 @@ func (c *Client) SendReq(method string, result any, params ...any) error {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", c.config.Host, bytes.NewBuffer(reqBody))
+	return c.post(ctx, c.priority(ctx, method), c.cost(method), reqBody, c.maxResponseSize(method), func(dec *json.Decoder) error {
+		if stream, ok := result.(StreamResult); ok {
+			return decodeStreamResponse(dec, stream)
+		}
+
+		rawResp := &Response{
+			Result: result,
+		}
+
+		err := dec.Decode(&rawResp)
+		if err != nil {
+			return err
+		}
+
+		if rawResp.Error != nil {
+			return rawResp.Error
+		}
+
+		return nil
+	})
+}
+
+// post sends reqBody to the RPC server in Config.Host, once the limiter admits it with priority and cost, and calls
+// decode with a decoder for the response body, which may be at most limit bytes if limit is greater than 0.
+func (c *Client) post(ctx context.Context, priority Priority, cost float64, reqBody []byte, limit int64, decode func(dec *json.Decoder) error) error {
+	if c.limiter != nil {
+		if err := c.limiter.acquire(ctx, priority, cost); err != nil {
+			return err
+		}
+
+		defer c.limiter.release()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.Host, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return err
 	}
@@ -268,64 +591,41 @@ func (c *Client) SendReq(method string, result any, params ...any) error {
 	req.SetBasicAuth(c.config.User, c.config.Pass)
 
 	req.Header.Set("Content-Type", "application/json")
+	setRequestHeaders(ctx, req)
 
 	res, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 
-	defer res.Body.Close()
+	defer func() {
+		// Drain the rest of the body, like the trailing newline, so the connection can be reused. Bodies that weren't
+		// read because decoding stopped early are not drained, closing the connection instead.
+		_, _ = io.CopyN(io.Discard, res.Body, maxDrainSize)
+		res.Body.Close()
+	}()
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return err
-	}
+	var body io.Reader = res.Body
+	if info := CallInfoFromContext(ctx); info != nil {
+		counter := &countingReader{r: res.Body}
+		body = counter
 
-	rawResp := &Response{
-		Result: result,
+		info.Host, info.StatusCode = c.config.Host, res.StatusCode
+		defer func() { info.ResponseSize = counter.n }()
 	}
 
-	err = json.Unmarshal(body, &rawResp)
-	if err != nil {
+	if err := checkResponse(res); err != nil {
 		return err
 	}
 
-	if rawResp.Error != nil {
-		return fmt.Errorf("rpc response: code %v: %#v", rawResp.Error.Code, rawResp.Error.Message)
-	}
-
-	return nil
-}
-
-// newHTTPClient creates a new http.Client that is configured with the proxy and TLS settings in the Config.
-func newHTTPClient(config *Config) (*http.Client, error) {
-	var proxyFunc func(*http.Request) (*url.URL, error)
-	if config.Proxy != "" {
-		proxyUrl, err := url.Parse(config.Proxy)
-		if err != nil {
-			return nil, err
-		}
-
-		proxyFunc = http.ProxyURL(proxyUrl)
-	}
-
-	var tlsConfig *tls.Config
-	if !config.DisableTLS {
-		if len(config.Certificates) > 0 {
-			pool := x509.NewCertPool()
-			pool.AppendCertsFromPEM(config.Certificates)
-			tlsConfig = &tls.Config{
-				RootCAs: pool,
-			}
+	if limit > 0 {
+		if res.ContentLength > limit {
+			return ErrResponseTooLarge
 		}
-	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy:           proxyFunc,
-			TLSClientConfig: tlsConfig,
-		},
+		body = &limitedReader{r: body, n: limit}
 	}
 
-	return client, nil
+	// The response is decoded straight from the body, so large results aren't held in memory twice.
+	return decode(json.NewDecoder(body))
 }
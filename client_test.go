@@ -0,0 +1,337 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client connected to a server which answers every request with handler.
+func newTestClient(t *testing.T, config *Config, handler func(req *Request) (any, *RPCError)) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &Request{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		result, rpcErr := handler(req)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": result, "error": rpcErr, "id": nil})
+	}))
+	t.Cleanup(server.Close)
+
+	if config == nil {
+		config = &Config{}
+	}
+
+	config.Host = server.URL
+	config.DisableTLS = true
+
+	client, err := New(config)
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestClient_SendReq(t *testing.T) {
+	client := newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		if req.Method == "getblockhash" {
+			return "00ff", nil
+		}
+
+		return nil, &RPCError{Code: -32601, Message: "Method not found"}
+	})
+
+	hash, err := client.GetBlockHash(1)
+	require.NoError(t, err)
+	assert.Equal(t, "00ff", hash)
+
+	_, err = client.GetBlockCount()
+
+	var rpcErr *RPCError
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -32601, rpcErr.Code)
+}
+
+func TestClient_MaxConcurrentRequests(t *testing.T) {
+	var inFlight, maxInFlight int32
+	client := newTestClient(t, &Config{MaxConcurrentRequests: 2}, func(req *Request) (any, *RPCError) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		return 1, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetBlockCount()
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(2), maxInFlight)
+}
+
+func TestClient_Timeout(t *testing.T) {
+	client := newTestClient(t, &Config{Timeout: 20 * time.Millisecond}, func(req *Request) (any, *RPCError) {
+		time.Sleep(200 * time.Millisecond)
+		return 1, nil
+	})
+
+	_, err := client.GetBlockCount()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client.config.Timeout = 0
+	_, err = client.WithContext(ctx).GetBlockCount()
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClient_DeduplicateRequests(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	client := newTestClient(t, &Config{DeduplicateRequests: true}, func(req *Request) (any, *RPCError) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+
+		return map[string]any{"hash": req.Params[0], "tx": []string{"aa"}}, nil
+	})
+
+	waiters := func() int {
+		client.flights.mu.Lock()
+		defer client.flights.mu.Unlock()
+
+		total := 0
+		for _, f := range client.flights.flights {
+			total += f.waiters
+		}
+
+		return total
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := client.WithContext(ctx).GetBlockVerbose("00ff")
+		cancelled <- err
+	}()
+
+	blocks := make([]*types.Block, 5)
+	var wg sync.WaitGroup
+	for i := range blocks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var err error
+			blocks[i], err = client.GetBlockVerbose("00ff")
+			assert.NoError(t, err)
+		}(i)
+	}
+
+	require.Eventually(t, func() bool { return waiters() == 6 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	for _, block := range blocks[1:] {
+		assert.Equal(t, blocks[0], block)
+	}
+
+	blocks[0].Tx[0] = "modified"
+	assert.Equal(t, "aa", blocks[1].Tx[0])
+
+	var wg2 sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg2.Add(1)
+		go func() {
+			defer wg2.Done()
+			_, _ = client.SendRawTransaction("00", nil)
+		}()
+	}
+	wg2.Wait()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestClient_DeduplicateRequests_Context(t *testing.T) {
+	var mu sync.Mutex
+	var callers []string
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		callers = append(callers, r.Header.Get("X-Caller"))
+		mu.Unlock()
+
+		<-release
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": 1})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(&Config{Host: server.URL, DisableTLS: true, DeduplicateRequests: true})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for _, caller := range []string{"a", "a", "b"} {
+		wg.Add(1)
+		go func(caller string) {
+			defer wg.Done()
+
+			ctx := WithCallInfo(WithRequestHeader(context.Background(), "X-Caller", caller), &CallInfo{})
+			_, err := client.WithContext(ctx).GetBlockCount()
+			assert.NoError(t, err)
+		}(caller)
+	}
+
+	require.Eventually(t, func() bool {
+		client.flights.mu.Lock()
+		defer client.flights.mu.Unlock()
+
+		total := 0
+		for _, f := range client.flights.flights {
+			total += f.waiters
+		}
+
+		return total == 3
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	// Callers with different headers don't share a request.
+	assert.ElementsMatch(t, []string{"a", "b"}, callers)
+
+	// The shared request doesn't carry the values of the caller which started it.
+	base, key := flightContext(WithCallInfo(WithPriority(context.Background(), PriorityHigh), &CallInfo{}))
+	assert.Nil(t, CallInfoFromContext(base))
+	assert.Equal(t, fmt.Sprintf("|priority=%v", PriorityHigh), key)
+}
+
+func TestClient_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/unauthorized" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<html><body>502 Bad Gateway</body></html>" + strings.Repeat(" ", 1000) + "tail"))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(&Config{Host: server.URL, DisableTLS: true})
+	require.NoError(t, err)
+
+	_, err = client.GetBlockCount()
+	var httpErr *HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", httpErr.ContentType)
+	assert.Equal(t, "<html><body>502 Bad Gateway</body></html>", httpErr.Body)
+	assert.Contains(t, err.Error(), "502 Bad Gateway (text/html; charset=utf-8)")
+
+	client, err = New(&Config{Host: server.URL + "/unauthorized", DisableTLS: true})
+	require.NoError(t, err)
+
+	_, err = client.GetBlockCount()
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+	assert.Empty(t, httpErr.Body)
+}
+
+func TestClient_MaxResponseSize(t *testing.T) {
+	client := newTestClient(t, &Config{
+		MaxResponseSize:  64,
+		MaxResponseSizes: map[string]int64{"getblock": 1 << 20},
+	}, func(req *Request) (any, *RPCError) {
+		if req.Method == "getblockcount" {
+			return 1, nil
+		}
+
+		return strings.Repeat("ff", 100), nil
+	})
+
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = client.GetBlockHash(1)
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+
+	block, err := client.GetBlock("00ff")
+	require.NoError(t, err)
+	assert.Len(t, block, 200)
+}
+
+func TestLimitedReader(t *testing.T) {
+	data, err := io.ReadAll(&limitedReader{r: strings.NewReader("abcd"), n: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(data))
+
+	_, err = io.ReadAll(&limitedReader{r: strings.NewReader("abcde"), n: 4})
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestClient_Retries(t *testing.T) {
+	var attempts int32
+	client := newTestClient(t, &Config{MaxRetries: 3, RetryBackoff: time.Millisecond}, func(req *Request) (any, *RPCError) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return nil, &RPCError{Code: rpcInWarmup, Message: "Loading block index..."}
+		}
+
+		return 5, nil
+	})
+
+	info := &CallInfo{}
+	count, err := client.WithContext(WithCallInfo(context.Background(), info)).GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+	assert.Equal(t, 2, info.Retries)
+
+	atomic.StoreInt32(&attempts, 0)
+	_, err = client.SendRawTransaction("00", nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts), "state changing requests are never retried")
+
+	atomic.StoreInt32(&attempts, 0)
+	client.config.DisableAutoReconnect = true
+	_, err = client.GetBlockCount()
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
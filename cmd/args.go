@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/omarhachach/rpcclient-core"
+)
+
+// errUsage is returned when a command is called with invalid arguments.
+var errUsage = errors.New("invalid usage")
+
+// typedValue is a flag.Value which parses its argument into a parameter's type.
+type typedValue struct {
+	typ   reflect.Type
+	value reflect.Value
+	raw   string
+	set   bool
+}
+
+// String implements flag.Value.
+func (v *typedValue) String() string {
+	if v == nil {
+		return ""
+	}
+
+	return v.raw
+}
+
+// Set implements flag.Value.
+func (v *typedValue) Set(s string) error {
+	value, err := parseValue(v.typ, s)
+	if err != nil {
+		return err
+	}
+
+	v.value = value
+	v.raw = s
+	v.set = true
+
+	return nil
+}
+
+// IsBoolFlag allows boolean parameters to be passed as -name instead of -name=true.
+func (v *typedValue) IsBoolFlag() bool {
+	typ := v.typ
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	return typ.Kind() == reflect.Bool
+}
+
+// parseValue parses s into a value of typ. Scalars are parsed directly, string slices can be comma-separated, and
+// everything else is decoded as JSON.
+func parseValue(typ reflect.Type, s string) (reflect.Value, error) {
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(s).Convert(typ), nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected an integer: %q", s)
+		}
+
+		return reflect.ValueOf(n).Convert(typ), nil
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected a number: %q", s)
+		}
+
+		return reflect.ValueOf(f).Convert(typ), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected a boolean: %q", s)
+		}
+
+		return reflect.ValueOf(b).Convert(typ), nil
+	case reflect.Ptr:
+		if typ.Elem().Kind() != reflect.Struct {
+			elem, err := parseValue(typ.Elem(), s)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			ptr := reflect.New(typ.Elem())
+			ptr.Elem().Set(elem)
+
+			return ptr, nil
+		}
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(s), "[") {
+			return reflect.ValueOf(strings.Split(s, ",")).Convert(typ), nil
+		}
+	}
+
+	ptr := reflect.New(typ)
+	if err := json.Unmarshal([]byte(s), ptr.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("expected JSON for %v: %v", typ, err)
+	}
+
+	return ptr.Elem(), nil
+}
+
+// parseArgs parses the flags and positional arguments of a command into the arguments for its method.
+func parseArgs(cmd *command, args []string) ([]reflect.Value, error) {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() { printCommandUsage(fs.Output(), cmd) }
+
+	values := make([]*typedValue, len(cmd.params))
+	for idx, p := range cmd.params {
+		values[idx] = &typedValue{typ: p.typ}
+		fs.Var(values[idx], p.name, p.usage)
+	}
+
+	// Flags and positional arguments can be interleaved, so the flags are parsed up to each positional argument. Tokens
+	// after "--" and negative numbers are always positional.
+	var positional []string
+	for len(args) > 0 {
+		switch {
+		case args[0] == "--":
+			positional = append(positional, args[1:]...)
+			args = nil
+		case !isFlag(args[0]):
+			positional = append(positional, args[0])
+			args = args[1:]
+		default:
+			end := flagsEnd(fs, args)
+			if err := fs.Parse(args[:end]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil, err
+				}
+
+				return nil, errUsage
+			}
+
+			args = args[end:]
+		}
+	}
+
+	for _, v := range values {
+		if v.set {
+			continue
+		}
+
+		if len(positional) == 0 {
+			break
+		}
+
+		if err := v.Set(positional[0]); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+
+		positional = positional[1:]
+	}
+
+	if len(positional) > 0 {
+		return nil, fmt.Errorf("%w: too many arguments", errUsage)
+	}
+
+	in := make([]reflect.Value, len(cmd.params))
+	for idx, p := range cmd.params {
+		switch {
+		case values[idx].set:
+			in[idx] = values[idx].value
+		case p.optional:
+			in[idx] = reflect.Zero(p.typ)
+		default:
+			return nil, fmt.Errorf("%w: missing required argument %q", errUsage, p.name)
+		}
+	}
+
+	return in, nil
+}
+
+// call invokes the method of cmd on client with args, and returns its result. The result is nil for methods that only
+// return an error.
+func call(client rpcclient.IClient, cmd *command, args []string) (any, error) {
+	in, err := parseArgs(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+
+	method := reflect.ValueOf(client).MethodByName(cmd.method)
+
+	var out []reflect.Value
+	if method.Type().IsVariadic() {
+		out = method.CallSlice(in)
+	} else {
+		out = method.Call(in)
+	}
+
+	if errVal := out[len(out)-1]; !errVal.IsNil() {
+		return nil, errVal.Interface().(error)
+	}
+
+	if len(out) == 1 {
+		return nil, nil
+	}
+
+	return out[0].Interface(), nil
+}
+
+// callRaw sends method directly to the server, for methods that have no IClient counterpart. Arguments are decoded as
+// JSON where possible and passed as strings otherwise.
+func callRaw(client *rpcclient.Client, method string, args []string) (any, error) {
+	params := make([]any, len(args))
+	for idx, arg := range args {
+		var value any
+		if err := json.Unmarshal([]byte(arg), &value); err != nil {
+			value = arg
+		}
+
+		params[idx] = value
+	}
+
+	var result any
+	if err := client.SendReq(method, &result, params...); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// isFlag reports whether arg is a flag, rather than a positional argument like a negative number.
+func isFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && (arg[1] < '0' || arg[1] > '9')
+}
+
+// flagsEnd returns the number of leading args which are flags of fs or their values. The value of a flag may be a
+// negative number.
+func flagsEnd(fs *flag.FlagSet, args []string) int {
+	idx := 0
+	for idx < len(args) && args[idx] != "--" && isFlag(args[idx]) {
+		name := strings.TrimLeft(args[idx], "-")
+		idx++
+
+		if strings.Contains(name, "=") {
+			continue
+		}
+
+		if f := fs.Lookup(name); f != nil && idx < len(args) {
+			if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !boolFlag.IsBoolFlag() {
+				idx++
+			}
+		}
+	}
+
+	return idx
+}
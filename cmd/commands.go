@@ -0,0 +1,162 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/omarhachach/rpcclient-core"
+)
+
+// command describes a subcommand which calls an IClient method.
+type command struct {
+	// name is the subcommand name, the lowercased method name.
+	name string
+	// method is the name of the IClient method.
+	method string
+	// usage is a one-line description of the command.
+	usage string
+	// params are the method parameters, in order.
+	params []*param
+}
+
+// param describes a parameter of an IClient method. It is exposed as both a flag and a positional argument.
+type param struct {
+	name     string
+	usage    string
+	optional bool
+	typ      reflect.Type
+}
+
+// req returns a required parameter.
+func req(name, usage string) *param {
+	return &param{name: name, usage: usage}
+}
+
+// opt returns an optional parameter. Pointer and variadic parameters are always optional.
+func opt(name, usage string) *param {
+	return &param{name: name, usage: usage, optional: true}
+}
+
+// iclientType is the reflected IClient interface, used to look up the parameter types of each method.
+var iclientType = reflect.TypeOf((*rpcclient.IClient)(nil)).Elem()
+
+// commandList holds every IClient method, with parameter names matching the RPC documentation.
+var commandList = []*command{
+	{method: "GetBlock", usage: "Returns hex-encoded block data.", params: []*param{req("hash", "block hash")}},
+	{method: "GetBlockVerbose", usage: "Returns a decoded block.", params: []*param{req("hash", "block hash")}},
+	{method: "GetBlockVerboseTx", usage: "Returns a decoded block with decoded transactions.", params: []*param{req("hash", "block hash")}},
+	{method: "GetBlockHash", usage: "Returns the hash of the block at height in the best chain.", params: []*param{req("height", "block height")}},
+	{method: "GetBlockHeader", usage: "Returns a hex-encoded block header.", params: []*param{req("hash", "block hash")}},
+	{method: "GetBlockHeaderVerbose", usage: "Returns a decoded block header.", params: []*param{req("hash", "block hash")}},
+	{method: "GetBlockStats", usage: "Returns per block statistics for a block.", params: []*param{req("hash", "block hash")}},
+	{method: "GetBlockStatsHeight", usage: "Returns per block statistics for the block at height.", params: []*param{req("height", "block height")}},
+	{method: "PreciousBlock", usage: "Treats a block as if it were received before others with the same work.", params: []*param{req("hash", "block hash")}},
+
+	{method: "GetBestBlockHash", usage: "Returns the hash of the best (tip) block."},
+	{method: "GetBlockChainInfo", usage: "Returns state info regarding blockchain processing."},
+	{method: "GetBlockCount", usage: "Returns the number of blocks in the longest chain."},
+	{method: "GetBlockFilter", usage: "Returns the BIP 157 content filter for a block.", params: []*param{req("blockhash", "block hash"), req("filtertype", "filter type, eg basic")}},
+	{method: "GetChainTips", usage: "Returns information about all known tips in the block tree."},
+	{method: "GetChainTxStats", usage: "Returns statistics about the total number and rate of transactions.", params: []*param{req("nblocks", "size of the window in blocks"), req("blockhash", "hash of the block that ends the window")}},
+	{method: "GetDifficulty", usage: "Returns the proof-of-work difficulty."},
+	{method: "PruneBlockchain", usage: "Prunes the blockchain up to height.", params: []*param{req("height", "block height")}},
+	{method: "VerifyChain", usage: "Verifies the blockchain database.", params: []*param{req("level", "how thorough the verification is, 0-4")}},
+	{method: "GetMemoryInfo", usage: "Returns information about memory usage."},
+	{method: "GetMemoryInfoMalloc", usage: "Returns an XML string describing low-level heap state."},
+	{method: "GetRPCInfo", usage: "Returns details about the RPC server."},
+
+	{method: "GenerateBlock", usage: "Mines a block with a set of ordered transactions.", params: []*param{req("output", "address or descriptor to send the reward to"), opt("txs", "raw transactions or mempool txids")}},
+	{method: "GenerateToAddress", usage: "Mines blocks to an address.", params: []*param{req("nblocks", "number of blocks"), req("address", "address to send the rewards to"), opt("maxtries", "maximum iterations to try")}},
+	{method: "GenerateToDescriptor", usage: "Mines blocks to a descriptor.", params: []*param{req("nblocks", "number of blocks"), req("descriptor", "descriptor to send the rewards to"), opt("maxtries", "maximum iterations to try")}},
+
+	{method: "GetMempoolAncestors", usage: "Returns the txids of the in-mempool ancestors of a transaction.", params: []*param{req("txid", "transaction id")}},
+	{method: "GetMempoolAncestorsVerbose", usage: "Returns the in-mempool ancestors of a transaction.", params: []*param{req("txid", "transaction id")}},
+	{method: "GetMempoolDescendants", usage: "Returns the txids of the in-mempool descendants of a transaction.", params: []*param{req("txid", "transaction id")}},
+	{method: "GetMempoolDescendantsVerbose", usage: "Returns the in-mempool descendants of a transaction.", params: []*param{req("txid", "transaction id")}},
+	{method: "GetMempoolEntry", usage: "Returns mempool data for a transaction.", params: []*param{req("txid", "transaction id")}},
+	{method: "GetMempoolInfo", usage: "Returns details on the active state of the mempool."},
+	{method: "GetRawMempool", usage: "Returns the txids in the mempool."},
+	{method: "GetRawMempoolVerbose", usage: "Returns the transactions in the mempool."},
+	{method: "SaveMempool", usage: "Dumps the mempool to disk."},
+	{method: "Uptime", usage: "Returns the uptime of the server in seconds."},
+	{method: "Stop", usage: "Requests a graceful stop of the node."},
+
+	{method: "GetBlockTemplate", usage: "Returns data needed to construct a block.", params: []*param{opt("template", "template request as JSON")}},
+	{method: "GetMiningInfo", usage: "Returns mining-related information."},
+	{method: "GetNetworkHashPS", usage: "Returns the estimated network hashes per second.", params: []*param{opt("nblocks", "number of blocks to average over"), opt("height", "estimate at the time of this height")}},
+	{method: "PrioritiseTransaction", usage: "Changes the priority of a transaction for mining.", params: []*param{req("txid", "transaction id"), req("feedelta", "fee delta in satoshis")}},
+	{method: "SubmitBlock", usage: "Submits a new block to the network.", params: []*param{req("hexdata", "hex-encoded block")}},
+	{method: "SubmitHeader", usage: "Submits a block header as a candidate chain tip.", params: []*param{req("hexdata", "hex-encoded block header")}},
+
+	{method: "GetTxOut", usage: "Returns details about an unspent transaction output.", params: []*param{req("txid", "transaction id"), req("vout", "output index"), opt("includemempool", "include the mempool")}},
+	{method: "GetTxOutProof", usage: "Returns a proof that transactions were included in a block.", params: []*param{req("txids", "transaction ids")}},
+	{method: "GetTxOutProofInBlock", usage: "Returns a proof that transactions were included in a given block.", params: []*param{req("txids", "transaction ids"), req("blockhash", "block hash")}},
+	{method: "GetTxOutSetInfo", usage: "Returns statistics about the unspent transaction output set."},
+	{method: "ScanTxOutSet", usage: "Scans the unspent transaction output set for descriptors.", params: []*param{req("action", "start, abort or status"), opt("scanobjects", "scan objects as JSON")}},
+	{method: "VerifyTxOutProof", usage: "Verifies that a proof points to a transaction in a block.", params: []*param{req("proof", "hex-encoded proof")}},
+	{method: "AnalyzePSBT", usage: "Analyzes a PSBT and its inputs.", params: []*param{req("psbt", "base64 PSBT")}},
+	{method: "CombinePSBT", usage: "Combines multiple PSBTs into one.", params: []*param{req("psbts", "base64 PSBTs")}},
+	{method: "CombineRawTransaction", usage: "Combines partially signed transactions into one.", params: []*param{req("txs", "hex-encoded transactions")}},
+	{method: "ConvertToPSBT", usage: "Converts a transaction to a PSBT.", params: []*param{req("hex", "hex-encoded transaction"), opt("permitsigdata", "discard signature data"), opt("iswitness", "whether the transaction is serialized as witness")}},
+	{method: "CreatePSBT", usage: "Creates a PSBT.", params: []*param{req("inputs", "inputs as JSON"), req("outputs", "outputs as JSON"), opt("locktime", "locktime"), opt("replaceable", "signal BIP125 replaceability")}},
+	{method: "CreateRawTransaction", usage: "Creates a raw transaction.", params: []*param{req("inputs", "inputs as JSON"), req("outputs", "outputs as JSON"), opt("locktime", "locktime"), opt("replaceable", "signal BIP125 replaceability")}},
+	{method: "DecodePSBT", usage: "Decodes a base64 PSBT.", params: []*param{req("psbt", "base64 PSBT")}},
+	{method: "DecodeRawTransaction", usage: "Decodes a hex-encoded transaction.", params: []*param{req("hex", "hex-encoded transaction"), opt("iswitness", "whether the transaction is serialized as witness")}},
+	{method: "DecodeScript", usage: "Decodes a hex-encoded script.", params: []*param{req("hex", "hex-encoded script")}},
+	{method: "FinalizePSBT", usage: "Finalizes the inputs of a PSBT.", params: []*param{req("psbt", "base64 PSBT"), opt("extract", "extract the final transaction")}},
+	{method: "FundRawTransaction", usage: "Selects inputs to meet the outputs of a transaction.", params: []*param{req("hex", "hex-encoded transaction"), opt("options", "options as JSON"), opt("iswitness", "whether the transaction is serialized as witness")}},
+	{method: "GetRawTransaction", usage: "Returns a hex-encoded transaction.", params: []*param{req("txid", "transaction id"), opt("blockhash", "block to look for the transaction in")}},
+	{method: "GetRawTransactionVerbose", usage: "Returns a decoded transaction.", params: []*param{req("txid", "transaction id"), opt("blockhash", "block to look for the transaction in")}},
+	{method: "JoinPSBTs", usage: "Joins distinct PSBTs into one.", params: []*param{req("psbts", "base64 PSBTs")}},
+	{method: "SendRawTransaction", usage: "Sends a transaction to the node and network.", params: []*param{req("hex", "hex-encoded transaction"), opt("maxfeerate", "maximum fee rate in BTC/kvB")}},
+	{method: "SignRawTransactionWithKey", usage: "Signs a transaction with the provided keys.", params: []*param{req("hex", "hex-encoded transaction"), req("privkeys", "base58-encoded private keys"), opt("prevtxs", "previous outputs as JSON"), opt("sighashtype", "signature hash type")}},
+	{method: "TestMempoolAccept", usage: "Tests whether transactions would be accepted by the mempool.", params: []*param{req("rawtxs", "hex-encoded transactions"), opt("maxfeerate", "maximum fee rate in BTC/kvB")}},
+	{method: "SubmitPackage", usage: "Submits a child transaction with its unconfirmed parents to the mempool.", params: []*param{req("package", "hex-encoded transactions"), opt("maxfeerate", "maximum fee rate in BTC/kvB"), opt("maxburnamount", "maximum value in BTC of unspendable outputs")}},
+	{method: "UtxoUpdatePSBT", usage: "Updates a PSBT with data from descriptors, the UTXO set or the mempool.", params: []*param{req("psbt", "base64 PSBT"), opt("scanobjects", "scan objects as JSON")}},
+
+	{method: "EstimateSmartFee", usage: "Estimates the fee rate needed to confirm within a number of blocks.", params: []*param{req("conftarget", "confirmation target in blocks"), opt("estimatemode", "unset, economical or conservative")}},
+	{method: "EstimateRawFee", usage: "Returns the raw fee estimates of each horizon for a number of blocks.", params: []*param{req("conftarget", "confirmation target in blocks"), opt("threshold", "fraction of transactions confirmed within the target")}},
+	{method: "GetDescriptorInfo", usage: "Analyses a descriptor and adds its checksum.", params: []*param{req("descriptor", "output descriptor")}},
+	{method: "DeriveAddresses", usage: "Derives the addresses of a descriptor.", params: []*param{req("descriptor", "output descriptor with checksum"), opt("range", "[end] or [begin,end] of the range of a ranged descriptor")}},
+}
+
+// commands maps subcommand names to their commands.
+var commands = map[string]*command{}
+
+func init() {
+	for _, cmd := range commandList {
+		cmd.name = strings.ToLower(cmd.method)
+
+		method, ok := iclientType.MethodByName(cmd.method)
+		if !ok {
+			panic("cli: IClient has no method " + cmd.method)
+		}
+
+		if method.Type.NumIn() != len(cmd.params) {
+			panic("cli: parameter count mismatch for " + cmd.method)
+		}
+
+		for idx, p := range cmd.params {
+			p.typ = method.Type.In(idx)
+
+			if p.typ.Kind() == reflect.Ptr || (method.Type.IsVariadic() && idx == len(cmd.params)-1) {
+				p.optional = true
+			}
+		}
+
+		commands[cmd.name] = cmd
+	}
+}
+
+// commandNames returns the sorted names of every subcommand.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
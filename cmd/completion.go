@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completionShells are the shells completion scripts can be generated for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// printCompletion writes the completion script for shell to w. prog is the name the binary is invoked as.
+func printCompletion(w io.Writer, shell, prog string) error {
+	switch shell {
+	case "bash":
+		return printBashCompletion(w, prog)
+	case "zsh":
+		fmt.Fprintf(w, "#compdef %v\n\nautoload -U bashcompinit\nbashcompinit\n\n", prog)
+		return printBashCompletion(w, prog)
+	case "fish":
+		return printFishCompletion(w, prog)
+	}
+
+	return fmt.Errorf("unsupported shell %q, expected one of %v", shell, strings.Join(completionShells, ", "))
+}
+
+// printBashCompletion writes a bash completion script, which zsh can also load through bashcompinit. Every global
+// flag takes a value, so the word after a flag is skipped when looking for the subcommand.
+func printBashCompletion(w io.Writer, prog string) error {
+	fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(prog) + "_complete"
+
+	fmt.Fprintf(w, "%v() {\n", fn)
+	fmt.Fprintln(w, `    local cur cmd i`)
+	fmt.Fprintln(w, `    cur="${COMP_WORDS[COMP_CWORD]}"`)
+	fmt.Fprintln(w, `    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `        case "${COMP_WORDS[i]}" in`)
+	fmt.Fprintln(w, `            -*=*) ;;`)
+	fmt.Fprintln(w, `            -*) ((i++)) ;;`)
+	fmt.Fprintln(w, `            *) cmd="${COMP_WORDS[i]}"; break ;;`)
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w, `    case "$cmd" in`)
+	fmt.Fprintf(w, "        \"\") COMPREPLY=($(compgen -W \"%v %v\" -- \"$cur\")) ;;\n", strings.Join(globalFlagNames(), " "), strings.Join(topLevelNames(), " "))
+	for _, name := range commandNames() {
+		fmt.Fprintf(w, "        %v) COMPREPLY=($(compgen -W \"%v\" -- \"$cur\")) ;;\n", name, strings.Join(commandFlagNames(commands[name]), " "))
+	}
+	fmt.Fprintf(w, "        completion) COMPREPLY=($(compgen -W \"%v\" -- \"$cur\")) ;;\n", strings.Join(completionShells, " "))
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, `}`)
+	_, err := fmt.Fprintf(w, "complete -F %v %v\n", fn, prog)
+
+	return err
+}
+
+// printFishCompletion writes a fish completion script.
+func printFishCompletion(w io.Writer, prog string) error {
+	fmt.Fprintf(w, "complete -c %v -f\n", prog)
+
+	newGlobalFlagSet(prog, &globalOptions{}).VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(w, "complete -c %v -n __fish_use_subcommand -o %v -d %q\n", prog, f.Name, f.Usage)
+	})
+
+	for _, name := range topLevelNames() {
+		usage := "command"
+		if cmd, ok := commands[name]; ok {
+			usage = cmd.usage
+		}
+
+		fmt.Fprintf(w, "complete -c %v -n __fish_use_subcommand -a %v -d %q\n", prog, name, usage)
+	}
+
+	for _, name := range commandNames() {
+		for _, p := range commands[name].params {
+			fmt.Fprintf(w, "complete -c %v -n '__fish_seen_subcommand_from %v' -o %v -d %q\n", prog, name, p.name, p.usage)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "complete -c %v -n '__fish_seen_subcommand_from completion' -a '%v'\n", prog, strings.Join(completionShells, " "))
+
+	return err
+}
+
+// commandFlagNames returns the flags of cmd, including the leading dash.
+func commandFlagNames(cmd *command) []string {
+	names := make([]string, len(cmd.params))
+	for idx, p := range cmd.params {
+		names[idx] = "-" + p.name
+	}
+
+	return names
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/omarhachach/rpcclient-core"
+)
+
+// envPrefix is the prefix of the environment variables that can be used instead of the connection flags.
+const envPrefix = "RPCCLIENT_"
+
+// chainParams holds the defaults that differ between chains.
+type chainParams struct {
+	// port is the default RPC port.
+	port string
+	// dir is the sub-directory of the data directory used by the chain.
+	dir string
+}
+
+var chains = map[string]chainParams{
+	"main":    {port: "8332", dir: ""},
+	"test":    {port: "18332", dir: "testnet3"},
+	"signet":  {port: "38332", dir: "signet"},
+	"regtest": {port: "18443", dir: "regtest"},
+}
+
+// connOptions are the connection settings, which can be set with flags, environment variables or bitcoin.conf.
+type connOptions struct {
+	Host    string
+	User    string
+	Pass    string
+	Cookie  string
+	Conf    string
+	DataDir string
+	Chain   string
+	Proxy   string
+	TLSCert string
+	TLSKey  string
+	TLSCA   string
+}
+
+// connFlag describes a connection setting.
+type connFlag struct {
+	name  string
+	usage string
+	value func(o *connOptions) *string
+}
+
+var connFlags = []*connFlag{
+	{"host", "URL of the RPC server, eg http://127.0.0.1:8332", func(o *connOptions) *string { return &o.Host }},
+	{"user", "username for the RPC server", func(o *connOptions) *string { return &o.User }},
+	{"pass", "password for the RPC server", func(o *connOptions) *string { return &o.Pass }},
+	{"cookie", "path to the RPC cookie file", func(o *connOptions) *string { return &o.Cookie }},
+	{"conf", "path to bitcoin.conf", func(o *connOptions) *string { return &o.Conf }},
+	{"datadir", "node data directory, used to find bitcoin.conf and the cookie file", func(o *connOptions) *string { return &o.DataDir }},
+	{"chain", "chain to connect to: main, test, signet or regtest", func(o *connOptions) *string { return &o.Chain }},
+	{"proxy", "URL of the HTTP or SOCKS5 proxy to connect through, eg socks5h://127.0.0.1:9050 for Tor", func(o *connOptions) *string { return &o.Proxy }},
+	{"tlsca", "path to a PEM-encoded certificate chain to trust for TLS", func(o *connOptions) *string { return &o.TLSCA }},
+	{"tlscert", "path to a PEM-encoded client certificate for TLS", func(o *connOptions) *string { return &o.TLSCert }},
+	{"tlskey", "path to the PEM-encoded private key of the client certificate", func(o *connOptions) *string { return &o.TLSKey }},
+}
+
+// registerConnFlags registers every connection setting on fs, storing the values in opts.
+func registerConnFlags(fs *flag.FlagSet, opts *connOptions) {
+	for _, f := range connFlags {
+		fs.StringVar(f.value(opts), f.name, "", f.usage+" (env "+envName(f.name)+")")
+	}
+}
+
+// envName returns the environment variable for a connection flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(flagName)
+}
+
+// resolve builds the client config. Flags take precedence over environment variables, which take precedence over
+// bitcoin.conf.
+func (o *connOptions) resolve() (*rpcclient.Config, error) {
+	for _, f := range connFlags {
+		if value := f.value(o); *value == "" {
+			*value = os.Getenv(envName(f.name))
+		}
+	}
+
+	if o.DataDir == "" {
+		o.DataDir = defaultDataDir()
+	}
+
+	conf, err := o.readConf()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Chain == "" {
+		o.Chain = conf.chain()
+	}
+
+	params, ok := chains[o.Chain]
+	if !ok {
+		return nil, fmt.Errorf("unknown chain %q", o.Chain)
+	}
+
+	if o.Host == "" {
+		host := conf.get(o.Chain, "rpcconnect")
+		if host == "" {
+			host = "127.0.0.1"
+		}
+
+		port := conf.get(o.Chain, "rpcport")
+		if port == "" {
+			port = params.port
+		}
+
+		o.Host = "http://" + host + ":" + port
+	}
+
+	if o.User == "" && o.Pass == "" {
+		o.User = conf.get(o.Chain, "rpcuser")
+		o.Pass = conf.get(o.Chain, "rpcpassword")
+	}
+
+	if o.User == "" && o.Pass == "" {
+		if err := o.readCookie(conf, params); err != nil {
+			return nil, err
+		}
+	}
+
+	config := &rpcclient.Config{
+		Host:                  o.Host,
+		User:                  o.User,
+		Pass:                  o.Pass,
+		Proxy:                 o.Proxy,
+		CertificatesFile:      o.TLSCA,
+		ClientCertificateFile: o.TLSCert,
+		ClientKeyFile:         o.TLSKey,
+	}
+
+	if strings.HasPrefix(o.Host, "http://") {
+		config.DisableTLS = true
+	}
+
+	return config, nil
+}
+
+// readConf reads bitcoin.conf. A missing file is only an error if it was set explicitly.
+func (o *connOptions) readConf() (bitcoinConf, error) {
+	path := o.Conf
+	if path == "" {
+		path = filepath.Join(o.DataDir, "bitcoin.conf")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if o.Conf == "" && errors.Is(err, fs.ErrNotExist) {
+			return bitcoinConf{}, nil
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseConf(f)
+}
+
+// readCookie sets User and Pass from the cookie file.
+func (o *connOptions) readCookie(conf bitcoinConf, params chainParams) error {
+	path := o.Cookie
+	if path == "" {
+		path = conf.get(o.Chain, "rpccookiefile")
+	}
+
+	if path == "" {
+		path = filepath.Join(o.DataDir, params.dir, ".cookie")
+	} else if !filepath.IsAbs(path) {
+		path = filepath.Join(o.DataDir, params.dir, path)
+	}
+
+	cookie, err := os.ReadFile(path)
+	if err != nil {
+		if o.Cookie == "" && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	user, pass, ok := strings.Cut(strings.TrimSpace(string(cookie)), ":")
+	if !ok {
+		return fmt.Errorf("malformed cookie file %v", path)
+	}
+
+	o.User, o.Pass = user, pass
+
+	return nil
+}
+
+// bitcoinConf holds the settings of a bitcoin.conf file, keyed by section. Settings outside of a section are stored
+// under "".
+type bitcoinConf map[string]map[string]string
+
+// parseConf parses a bitcoin.conf file. Later values override earlier ones.
+func parseConf(r io.Reader) (bitcoinConf, error) {
+	conf := bitcoinConf{"": {}}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if conf[section] == nil {
+				conf[section] = map[string]string{}
+			}
+
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, "=")
+		conf[section][strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return conf, scanner.Err()
+}
+
+// get returns the value of key in the section for chain, falling back to the top-level value.
+func (c bitcoinConf) get(chain, key string) string {
+	if value, ok := c[chain][key]; ok {
+		return value
+	}
+
+	return c[""][key]
+}
+
+// chain returns the chain selected in the file, defaulting to main.
+func (c bitcoinConf) chain() string {
+	top := c[""]
+
+	switch {
+	case top["chain"] != "":
+		return top["chain"]
+	case top["regtest"] == "1":
+		return "regtest"
+	case top["testnet"] == "1":
+		return "test"
+	case top["signet"] == "1":
+		return "signet"
+	}
+
+	return "main"
+}
+
+// defaultDataDir returns the default bitcoind data directory for the platform.
+func defaultDataDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(os.Getenv("APPDATA"), "Bitcoin")
+	case "darwin":
+		return filepath.Join(home, "Library", "Application Support", "Bitcoin")
+	}
+
+	return filepath.Join(home, ".bitcoin")
+}
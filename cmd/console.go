@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/omarhachach/rpcclient-core"
+	"golang.org/x/term"
+)
+
+// lastVar is the variable that holds the result of the previous command.
+const lastVar = "last"
+
+// console is an interactive session. Every line is a pipeline of commands separated by "|", where the result of each
+// command is passed as the first argument of the next. A line of the form "name = pipeline" stores the result in
+// $name, and the result of every line is stored in $last.
+type console struct {
+	client *rpcclient.Client
+	format string
+	out    io.Writer
+	errOut io.Writer
+
+	// vars holds the generic JSON representation of stored results.
+	vars map[string]any
+	// history holds every line entered in the session.
+	history []string
+	// nodeCommands are the commands listed by the node's help output.
+	nodeCommands []string
+}
+
+// runConsole runs an interactive console reading from in. Line editing, history and completion are only available when
+// in is a terminal.
+func runConsole(client *rpcclient.Client, format string, in io.Reader, out, errOut io.Writer) int {
+	c := &console{
+		client: client,
+		format: format,
+		out:    out,
+		errOut: errOut,
+		vars:   map[string]any{},
+	}
+
+	c.loadNodeCommands()
+
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return c.runTerminal(f, out)
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		if c.handle(scanner.Text()) {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(errOut, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// runTerminal runs the console on a terminal in raw mode.
+func (c *console) runTerminal(in *os.File, out io.Writer) int {
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		fmt.Fprintln(c.errOut, err)
+		return exitError
+	}
+	defer term.Restore(int(in.Fd()), state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, "> ")
+	t.AutoCompleteCallback = c.complete
+
+	if width, height, err := term.GetSize(int(in.Fd())); err == nil {
+		_ = t.SetSize(width, height)
+	}
+
+	c.out, c.errOut = t, t
+
+	for {
+		line, err := t.ReadLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return exitOK
+			}
+
+			fmt.Fprintln(c.errOut, err)
+
+			return exitError
+		}
+
+		if c.handle(line) {
+			return exitOK
+		}
+	}
+}
+
+// handle runs a single line, printing its result or error. Returns true if the console should exit.
+func (c *console) handle(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return false
+	}
+
+	c.history = append(c.history, line)
+
+	switch line {
+	case "exit", "quit":
+		return true
+	case "history":
+		for idx, entry := range c.history[:len(c.history)-1] {
+			fmt.Fprintf(c.out, "%5d  %v\n", idx+1, entry)
+		}
+
+		return false
+	case "vars":
+		for _, name := range c.varNames() {
+			fmt.Fprintf(c.out, "$%v = %v\n", name, cell(c.vars[name]))
+		}
+
+		return false
+	}
+
+	result, err := c.exec(line)
+	if err != nil {
+		fmt.Fprintln(c.errOut, "error:", err)
+		return false
+	}
+
+	if err := printResult(c.out, c.format, result); err != nil {
+		fmt.Fprintln(c.errOut, "error:", err)
+	}
+
+	return false
+}
+
+// exec runs a line and stores its result.
+func (c *console) exec(line string) (any, error) {
+	target := ""
+	if name, rest, ok := strings.Cut(line, "="); ok && isVarName(strings.TrimSpace(name)) {
+		target, line = strings.TrimSpace(name), rest
+	}
+
+	stages, err := splitPipeline(line)
+	if err != nil {
+		return nil, err
+	}
+
+	var result any
+	for idx, args := range stages {
+		if len(args) == 0 {
+			return nil, errors.New("empty command in pipeline")
+		}
+
+		for argIdx, arg := range args {
+			if args[argIdx], err = c.expand(arg); err != nil {
+				return nil, err
+			}
+		}
+
+		name, cmdArgs := args[0], args[1:]
+		if idx > 0 {
+			piped, err := argString(result)
+			if err != nil {
+				return nil, err
+			}
+
+			cmdArgs = append([]string{piped}, cmdArgs...)
+		}
+
+		if name == "help" {
+			result, err = c.help(cmdArgs)
+		} else if cmd, ok := commands[name]; ok {
+			result, err = call(c.client, cmd, cmdArgs)
+		} else {
+			result, err = callRaw(c.client, name, cmdArgs)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	generic, err := toGeneric(result)
+	if err != nil {
+		return nil, err
+	}
+
+	c.vars[lastVar] = generic
+	if target != "" {
+		c.vars[target] = generic
+	}
+
+	return result, nil
+}
+
+// help returns the usage of a command, falling back to the node's help for commands it does not know.
+func (c *console) help(args []string) (any, error) {
+	if len(args) == 1 {
+		if cmd, ok := commands[args[0]]; ok {
+			var b strings.Builder
+			printCommandUsage(&b, cmd)
+
+			return b.String(), nil
+		}
+	}
+
+	return callRaw(c.client, "help", args)
+}
+
+// expand replaces an argument of the form $name or $name.path with the value of the variable. Paths are separated by
+// dots, and array elements are selected by index, eg $last.tx.0.txid.
+func (c *console) expand(arg string) (string, error) {
+	if !strings.HasPrefix(arg, "$") {
+		return arg, nil
+	}
+
+	path := strings.Split(arg[1:], ".")
+
+	value, ok := c.vars[path[0]]
+	if !ok {
+		return "", fmt.Errorf("undefined variable $%v", path[0])
+	}
+
+	for _, key := range path[1:] {
+		switch v := value.(type) {
+		case map[string]any:
+			if value, ok = v[key]; !ok {
+				return "", fmt.Errorf("%v: no field %q", arg, key)
+			}
+		case []any:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return "", fmt.Errorf("%v: invalid index %q", arg, key)
+			}
+
+			value = v[idx]
+		default:
+			return "", fmt.Errorf("%v: cannot select %q from a scalar", arg, key)
+		}
+	}
+
+	return argString(value)
+}
+
+// argString converts a result to a command argument. Strings are passed as is, everything else as JSON.
+func argString(value any) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	out, err := json.Marshal(value)
+
+	return string(out), err
+}
+
+// complete is the terminal's AutoCompleteCallback. It completes command names, flags and variables on tab.
+func (c *console) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	prefix := line[:pos]
+	start := strings.LastIndexAny(prefix, " \t|=") + 1
+	word := prefix[start:]
+	before := strings.TrimRight(prefix[:start], " \t")
+
+	var candidates []string
+	switch {
+	case strings.HasPrefix(word, "$"):
+		for _, name := range c.varNames() {
+			candidates = append(candidates, "$"+name)
+		}
+	case strings.HasPrefix(word, "-"):
+		segment := prefix[strings.LastIndex(prefix, "|")+1:]
+		if fields := strings.Fields(segment); len(fields) > 0 {
+			if cmd, ok := commands[fields[0]]; ok {
+				candidates = commandFlagNames(cmd)
+			}
+		}
+	case before == "" || strings.HasSuffix(before, "|") || isVarName(strings.TrimSpace(strings.TrimSuffix(before, "="))):
+		candidates = c.commandNames()
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	completion := commonPrefix(matches)
+	if len(matches) == 1 {
+		completion += " "
+	}
+
+	newLine := prefix[:start] + completion + line[pos:]
+
+	return newLine, start + len(completion), true
+}
+
+// commandNames returns every command that can be completed: the IClient commands, the node's commands and the
+// console builtins.
+func (c *console) commandNames() []string {
+	seen := map[string]bool{}
+
+	var names []string
+	for _, name := range append(append(commandNames(), c.nodeCommands...), "help", "history", "vars", "exit", "quit") {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// varNames returns the sorted names of every variable.
+func (c *console) varNames() []string {
+	names := make([]string, 0, len(c.vars))
+	for name := range c.vars {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// loadNodeCommands reads the command names from the node's help output. Errors are ignored, as completion of the
+// IClient commands works without it.
+func (c *console) loadNodeCommands() {
+	var help string
+	if err := c.client.SendReq("help", &help); err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(help, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "==") {
+			continue
+		}
+
+		c.nodeCommands = append(c.nodeCommands, fields[0])
+	}
+}
+
+// splitPipeline splits a line into commands separated by "|", and each command into arguments separated by whitespace.
+// Single and double quotes group arguments containing whitespace or "|".
+func splitPipeline(line string) ([][]string, error) {
+	var (
+		stages  [][]string
+		args    []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+
+	flush := func() {
+		if inArg {
+			args = append(args, current.String())
+			current.Reset()
+			inArg = false
+		}
+	}
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '|':
+			flush()
+			stages = append(stages, args)
+			args = nil
+		case r == ' ' || r == '\t':
+			flush()
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+
+	flush()
+
+	return append(stages, args), nil
+}
+
+// isVarName reports whether name is a valid variable name.
+func isVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for idx, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (idx > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// commonPrefix returns the longest common prefix of values.
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
@@ -1,29 +1,234 @@
+// Command cmd is a bitcoin-cli style client which exposes every IClient method as a subcommand.
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/omarhachach/rpcclient-core"
 )
 
+// Exit codes which are not derived from an RPC error.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// globalOptions are the options that come before the subcommand.
+type globalOptions struct {
+	conn     connOptions
+	format   string
+	logLevel string
+}
+
 func main() {
-	// TODO: build cli
-	client, err := rpcclient.New(&rpcclient.Config{
-		Host:                 "http://localhost:8333",
-		User:                 "bitcoind",
-		Pass:                 "bitcoind",
-		DisableAutoReconnect: false,
-	})
+	os.Exit(run(filepath.Base(os.Args[0]), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the CLI with args and returns the exit code.
+func run(prog string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts := &globalOptions{}
+
+	fs := newGlobalFlagSet(prog, opts)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { printUsage(stderr, prog, fs) }
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	name, cmdArgs := fs.Arg(0), fs.Args()[1:]
+
+	switch name {
+	case "help":
+		if len(cmdArgs) == 0 {
+			printUsage(stdout, prog, fs)
+			return exitOK
+		}
+
+		cmd, ok := commands[cmdArgs[0]]
+		if !ok {
+			fmt.Fprintf(stderr, "unknown command %q\n", cmdArgs[0])
+			return exitUsage
+		}
+
+		printCommandUsage(stdout, cmd)
+
+		return exitOK
+	case "completion":
+		if len(cmdArgs) != 1 {
+			fmt.Fprintf(stderr, "usage: %v completion <%v>\n", prog, joinOr(completionShells))
+			return exitUsage
+		}
+
+		if err := printCompletion(stdout, cmdArgs[0], prog); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+
+		return exitOK
+	}
+
+	config, err := opts.conn.resolve()
 	if err != nil {
-		panic(err)
-		return
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if opts.logLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(opts.logLevel)); err != nil {
+			fmt.Fprintln(stderr, "invalid -log level:", err)
+			return exitUsage
+		}
+
+		config.Logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+	}
+
+	client, err := rpcclient.New(config)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if name == "console" {
+		return runConsole(client, opts.format, stdin, stdout, stderr)
+	}
+
+	var result any
+	if cmd, ok := commands[name]; ok {
+		result, err = call(client, cmd, cmdArgs)
+	} else {
+		result, err = callRaw(client, name, cmdArgs)
 	}
 
-	count, err := client.GetBlockChainInfo()
 	if err != nil {
-		panic(err)
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+
+		fmt.Fprintln(stderr, err)
+
+		return exitCode(err)
+	}
+
+	if err := printResult(stdout, opts.format, result); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// newGlobalFlagSet returns a flag set with every global flag registered, storing the values in opts.
+func newGlobalFlagSet(prog string, opts *globalOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(prog, flag.ContinueOnError)
+	registerConnFlags(fs, &opts.conn)
+	fs.StringVar(&opts.format, "format", formatJSON, "output format: json, raw or table")
+	fs.StringVar(&opts.logLevel, "log", "", "log RPC calls to stderr at this level and above: debug, info, warn or error")
+
+	return fs
+}
+
+// exitCode returns the exit code for err. Like bitcoin-cli, RPC errors exit with the absolute value of their code.
+func exitCode(err error) int {
+	if errors.Is(err, errUsage) {
+		return exitUsage
+	}
+
+	var rpcErr *rpcclient.RPCError
+	if errors.As(err, &rpcErr) {
+		code := rpcErr.Code
+		if code < 0 {
+			code = -code
+		}
+
+		if code == 0 || code > 255 {
+			return exitError
+		}
+
+		return code
+	}
+
+	return exitError
+}
+
+// printUsage writes the usage of the CLI, listing every global flag and command.
+func printUsage(w io.Writer, prog string, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %v [flags] <command> [arguments]\n\nFlags:\n", prog)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+
+	fmt.Fprintln(w, "\nCommands:")
+	for _, name := range commandNames() {
+		fmt.Fprintf(w, "  %-30v %v\n", name, commands[name].usage)
+	}
+
+	fmt.Fprintf(w, "  %-30v %v\n", "console", "Starts an interactive console.")
+	fmt.Fprintf(w, "  %-30v %v\n", "help <command>", "Shows the usage of a command.")
+	fmt.Fprintf(w, "  %-30v %v\n", "completion <shell>", "Prints a completion script for "+joinOr(completionShells)+".")
+	fmt.Fprintln(w, "\nAny other command is sent to the server as is, with its arguments decoded as JSON where possible.")
+}
+
+// printCommandUsage writes the usage of cmd.
+func printCommandUsage(w io.Writer, cmd *command) {
+	fmt.Fprintf(w, "Usage: %v", cmd.name)
+	for _, p := range cmd.params {
+		if p.optional {
+			fmt.Fprintf(w, " [%v]", p.name)
+		} else {
+			fmt.Fprintf(w, " <%v>", p.name)
+		}
+	}
+
+	fmt.Fprintf(w, "\n\n%v\n", cmd.usage)
+
+	if len(cmd.params) == 0 {
 		return
 	}
 
-	fmt.Printf("Info: %#v\n", count)
+	fmt.Fprintln(w, "\nArguments can be passed in order or as flags:")
+	for _, p := range cmd.params {
+		fmt.Fprintf(w, "  -%-20v %v (%v)\n", p.name, p.usage, p.typ)
+	}
+}
+
+// topLevelNames returns every subcommand name, including the builtin commands.
+func topLevelNames() []string {
+	return append(commandNames(), "console", "help", "completion")
+}
+
+// globalFlagNames returns every global flag, including the leading dash.
+func globalFlagNames() []string {
+	var names []string
+	newGlobalFlagSet("", &globalOptions{}).VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+
+	return names
+}
+
+// joinOr joins values with commas and a final "or".
+func joinOr(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer returns a server which answers every request with handler's result, recording the last request.
+func newTestServer(t *testing.T, handler func(method string, params []any) (any, map[string]any)) (*httptest.Server, *[]any) {
+	var lastParams []any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		lastParams = req.Params
+
+		result, rpcErr := handler(req.Method, req.Params)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": result, "error": rpcErr})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &lastParams
+}
+
+func TestCommandsCoverIClient(t *testing.T) {
+	for idx := 0; idx < iclientType.NumMethod(); idx++ {
+		method := iclientType.Method(idx)
+		_, ok := commands[strings.ToLower(method.Name)]
+		assert.True(t, ok, "no command for %v", method.Name)
+	}
+}
+
+func TestRun(t *testing.T) {
+	server, params := newTestServer(t, func(method string, params []any) (any, map[string]any) {
+		switch method {
+		case "getblockhash":
+			return "00ff", nil
+		case "getmempoolinfo":
+			return map[string]any{"size": 2, "loaded": true}, nil
+		case "getrawtransaction":
+			return nil, map[string]any{"code": -5, "message": "No such mempool or blockchain transaction."}
+		}
+
+		return []string{method}, nil
+	})
+
+	var stdout, stderr bytes.Buffer
+	code := run("cli", []string{"-host", server.URL, "-user", "u", "-pass", "p", "-format", "raw", "getblockhash", "100"}, nil, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.Equal(t, "00ff\n", stdout.String())
+	assert.Equal(t, []any{float64(100)}, *params)
+
+	stdout.Reset()
+	code = run("cli", []string{"-host", server.URL, "-user", "u", "-format", "table", "getmempoolinfo"}, nil, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.Contains(t, stdout.String(), "loaded            true\n")
+	assert.Contains(t, stdout.String(), "size              2\n")
+
+	code = run("cli", []string{"-host", server.URL, "-user", "u", "getrawtransaction", "-blockhash", "00ff", "abcd"}, nil, &stdout, &stderr)
+	assert.Equal(t, 5, code)
+	assert.Equal(t, []any{"abcd", false, "00ff"}, *params)
+
+	code = run("cli", []string{"-host", server.URL, "-user", "u", "getblockhash", "notanumber"}, nil, &stdout, &stderr)
+	assert.Equal(t, exitUsage, code)
+
+	stderr.Reset()
+	code = run("cli", []string{"-host", server.URL, "-user", "u", "-log", "debug", "getblockhash", "100"}, nil, &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stderr.String(), "msg=\"rpc call\" method=getblockhash params=[100]")
+
+	stdout.Reset()
+	code = run("cli", []string{"-host", server.URL, "-user", "u", "-format", "raw", "getnetworkinfo", "true", "x"}, nil, &stdout, &stderr)
+	assert.Equal(t, exitOK, code, stderr.String())
+	assert.Equal(t, "[\"getnetworkinfo\"]\n", stdout.String())
+	assert.Equal(t, []any{true, "x"}, *params)
+}
+
+func TestParseArgs(t *testing.T) {
+	in, err := parseArgs(commands["estimaterawfee"], []string{"-1", "-threshold", "-0.5"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), in[0].Int())
+	assert.Equal(t, -0.5, in[1].Elem().Float())
+
+	// Tokens after "--" are positional, even if they look like flags.
+	in, err = parseArgs(commands["getrawtransaction"], []string{"-blockhash", "00ff", "--", "-txid"})
+	require.NoError(t, err)
+	assert.Equal(t, "-txid", in[0].String())
+	assert.Equal(t, "00ff", in[1].Elem().String())
+
+	_, err = parseArgs(commands["getrawtransaction"], []string{"-unknown", "abcd"})
+	assert.ErrorIs(t, err, errUsage)
+}
+
+func TestParseConf(t *testing.T) {
+	conf, err := parseConf(strings.NewReader(`
+# comment
+regtest=1
+rpcuser=top
+[regtest]
+rpcport=1234 # inline comment
+rpcpassword=secret
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "regtest", conf.chain())
+	assert.Equal(t, "top", conf.get("regtest", "rpcuser"))
+	assert.Equal(t, "1234", conf.get("regtest", "rpcport"))
+	assert.Equal(t, "secret", conf.get("regtest", "rpcpassword"))
+	assert.Equal(t, "", conf.get("main", "rpcpassword"))
+}
+
+func TestConsole(t *testing.T) {
+	var calls [][]any
+	server, _ := newTestServer(t, func(method string, params []any) (any, map[string]any) {
+		calls = append(calls, append([]any{method}, params...))
+
+		switch method {
+		case "help":
+			return "== Network ==\ngetnetworkinfo\n", nil
+		case "getblockhash":
+			return "00ff", nil
+		case "getblockchaininfo":
+			return map[string]any{"bestblockhash": "00aa", "blocks": 10}, nil
+
+		case "getblock":
+			return map[string]any{"hash": params[0]}, nil
+		}
+
+		return params[0], nil
+	})
+
+	input := strings.Join([]string{
+		"getblockhash 100 | getblockverbose",
+		"info = getblockchaininfo",
+		"getblockheader $info.bestblockhash",
+		"getrawtransaction -blockhash $last $info.bestblockhash",
+		"undefined $nope",
+		"exit",
+		"getblockcount",
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run("cli", []string{"-host", server.URL, "-user", "u", "console"}, strings.NewReader(input), &stdout, &stderr)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "error: undefined variable $nope\n", stderr.String())
+
+	assert.Equal(t, [][]any{
+		{"help"},
+		{"getblockhash", float64(100)},
+		{"getblock", "00ff", float64(1)},
+		{"getblockchaininfo"},
+		{"getblockheader", "00aa", false},
+		{"getrawtransaction", "00aa", false, "00aa"},
+	}, calls)
+}
+
+func TestConsole_Complete(t *testing.T) {
+	c := &console{vars: map[string]any{"last": "x", "info": map[string]any{}}, nodeCommands: []string{"getnetworkinfo"}}
+
+	line, pos, ok := c.complete("getnetwork", 10, '\t')
+	assert.True(t, ok)
+	assert.Equal(t, "getnetwork", line[:pos])
+
+	line, _, ok = c.complete("getnetworki", 11, '\t')
+	assert.True(t, ok)
+	assert.Equal(t, "getnetworkinfo ", line)
+
+	line, _, ok = c.complete("getblockhash 1 | getblockver", 28, '\t')
+	assert.True(t, ok)
+	assert.Equal(t, "getblockhash 1 | getblockverbose", line)
+
+	line, _, ok = c.complete("getrawtransaction -bl", 21, '\t')
+	assert.True(t, ok)
+	assert.Equal(t, "getrawtransaction -blockhash ", line)
+
+	line, _, ok = c.complete("getblock $in", 12, '\t')
+	assert.True(t, ok)
+	assert.Equal(t, "getblock $info ", line)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats.
+const (
+	formatJSON  = "json"
+	formatRaw   = "raw"
+	formatTable = "table"
+)
+
+// printResult writes result to w in the given format. Nil results are not printed.
+func printResult(w io.Writer, format string, result any) error {
+	if result == nil {
+		return nil
+	}
+
+	switch format {
+	case formatJSON:
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w, string(out))
+
+		return err
+	case formatRaw:
+		if s, ok := result.(string); ok {
+			_, err := fmt.Fprintln(w, s)
+			return err
+		}
+
+		out, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w, string(out))
+
+		return err
+	case formatTable:
+		return printTable(w, result)
+	}
+
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// printTable writes result as a table. Objects are printed as key/value rows, arrays of objects as one row per object
+// with a column per key, and other arrays as one value per line.
+func printTable(w io.Writer, result any) error {
+	generic, err := toGeneric(result)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	switch value := generic.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(value) {
+			fmt.Fprintf(tw, "%v\t%v\n", key, cell(value[key]))
+		}
+	case []any:
+		columns := objectColumns(value)
+		if columns == nil {
+			for _, elem := range value {
+				fmt.Fprintln(tw, cell(elem))
+			}
+
+			break
+		}
+
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, elem := range value {
+			obj := elem.(map[string]any)
+
+			row := make([]string, len(columns))
+			for idx, column := range columns {
+				row[idx] = cell(obj[column])
+			}
+
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	default:
+		fmt.Fprintln(tw, cell(value))
+	}
+
+	return tw.Flush()
+}
+
+// toGeneric converts result to the generic representation of its JSON encoding.
+func toGeneric(result any) (any, error) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	return generic, nil
+}
+
+// objectColumns returns the sorted union of keys if every element of arr is an object, and nil otherwise.
+func objectColumns(arr []any) []string {
+	if len(arr) == 0 {
+		return nil
+	}
+
+	keys := map[string]any{}
+	for _, elem := range arr {
+		obj, ok := elem.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		for key := range obj {
+			keys[key] = nil
+		}
+	}
+
+	return sortedKeys(keys)
+}
+
+// cell formats a single table value. Nested objects and arrays are written as compact JSON.
+func cell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		return v
+	case map[string]any, []any:
+		out, _ := json.Marshal(v)
+		return string(out)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
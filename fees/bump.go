@@ -0,0 +1,442 @@
+package fees
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// DefaultIncrementalFeeRate is the fee rate in sat/vB a replacement has to pay for its own size on top of the fees of
+// the transactions it replaces, if BumpOptions.IncrementalFeeRate is not set. It is the default of the
+// -incrementalrelayfee option of Bitcoin Core.
+const DefaultIncrementalFeeRate = 1.0
+
+// DefaultDustLimit is the smallest value in satoshis of the output paying for a bump, if BumpOptions.DustLimit is not
+// set. It is the dust limit of P2PKH outputs, the highest of the standard output types.
+const DefaultDustLimit = 546
+
+// maxReplacements is the maximum number of transactions a replacement may evict from the mempool, BIP125 rule 5.
+const maxReplacements = 100
+
+// maxSignAttempts is the number of times a bump is signed before giving up on its fee covering its size, which is only
+// known after signing.
+const maxSignAttempts = 3
+
+// maxSequence is the highest sequence number which signals replaceability, BIP125 rule 1.
+const maxSequence = 0xfffffffd
+
+var (
+	// ErrNoFeeRate is returned when BumpOptions.FeeRate is not set.
+	ErrNoFeeRate = errors.New("fees: no fee rate")
+
+	// ErrNoSigner is returned when BumpOptions.Signer is not set.
+	ErrNoSigner = errors.New("fees: no signer")
+
+	// ErrTooManyReplacements is returned when a replacement would evict more transactions than BIP125 allows.
+	ErrTooManyReplacements = errors.New("fees: replacement would evict too many transactions")
+
+	// ErrInsufficientValue is returned when the output paying for a bump would be left below the dust limit.
+	ErrInsufficientValue = errors.New("fees: output value too low to pay the fee")
+
+	// ErrOutputSpent is returned when the output a child should spend doesn't exist or is spent.
+	ErrOutputSpent = errors.New("fees: output is spent or doesn't exist")
+
+	// ErrSizeChanged is returned when the size of a signed bump keeps growing beyond what its fee pays for.
+	ErrSizeChanged = errors.New("fees: size of the signed transaction keeps changing")
+)
+
+// RejectedError is returned when testmempoolaccept rejects a bump.
+type RejectedError struct {
+	// Reason is the reject-reason of testmempoolaccept, eg "insufficient fee".
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *RejectedError) Error() string {
+	return "fees: transaction rejected: " + e.Reason
+}
+
+// Signer signs the raw transaction in hex, which spends the outputs in prevTxs, and returns the signed transaction.
+type Signer func(hex string, prevTxs []*types.PreviousTransaction) (string, error)
+
+// KeySigner returns a Signer which signs with signrawtransactionwithkey, using the WIF encoded privKeys.
+func KeySigner(client rpcclient.IClient, privKeys []string) Signer {
+	return func(hex string, prevTxs []*types.PreviousTransaction) (string, error) {
+		res, err := client.SignRawTransactionWithKey(hex, privKeys, prevTxs, types.SigHashTypeAll)
+		if err != nil {
+			return "", err
+		}
+
+		if !res.Complete {
+			reason := "missing signatures"
+			if len(res.Errors) > 0 {
+				reason = res.Errors[0].Error
+			}
+
+			return "", fmt.Errorf("fees: signing: %v", reason)
+		}
+
+		return res.Hex, nil
+	}
+}
+
+// BumpOptions are the options for Replace and CPFP.
+type BumpOptions struct {
+	// FeeRate is the target fee rate in sat/vB.
+	FeeRate float64
+
+	// Signer signs the bump.
+	Signer Signer
+
+	// IncrementalFeeRate is the -incrementalrelayfee of the node in sat/vB. Defaults to DefaultIncrementalFeeRate.
+	IncrementalFeeRate float64
+
+	// DustLimit is the smallest value in satoshis the output paying for the bump may be left with. Defaults to
+	// DefaultDustLimit.
+	DustLimit int64
+}
+
+// Bump is a fee bumping transaction which was accepted by testmempoolaccept.
+type Bump struct {
+	// Hex is the signed transaction, ready for sendrawtransaction.
+	Hex string
+
+	// Txid is the id of the transaction.
+	Txid string
+
+	// Vsize is the virtual size of the transaction.
+	Vsize int
+
+	// Fee is the fee of the transaction in satoshis.
+	Fee int64
+
+	// FeeRate is the fee rate in sat/vB of the transaction for a replacement, and of the package of the child and its
+	// unconfirmed ancestors for CPFP.
+	FeeRate float64
+
+	// Replaced are the transactions a replacement evicts from the mempool: the original and its descendants.
+	Replaced []string
+}
+
+// Replace builds a replacement for the transaction txid paying opts.FeeRate, which spends the same inputs and pays the
+// same outputs except for changeOutput, which pays the additional fee.
+//
+// The fee satisfies rules 3 to 6 of BIP125 as implemented by Bitcoin Core: it is at least the fees of the original
+// and its descendants, which are evicted, plus the incremental relay fee for the size of the replacement, and its fee
+// rate is higher than the one of the original. At most 100 transactions may be evicted. The replacement is checked
+// with testmempoolaccept before it is returned.
+//
+// Signing needs the outputs spent by the original, which are looked up with getrawtransaction. If they are confirmed,
+// the node needs -txindex.
+func Replace(client rpcclient.IClient, txid string, changeOutput int, opts *BumpOptions) (*Bump, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	entry, err := client.GetMempoolEntry(txid)
+	if err != nil {
+		return nil, err
+	} else if entry.Fees == nil {
+		return nil, fmt.Errorf("fees: mempool entry of %v has no fees", txid)
+	}
+
+	tx, err := client.GetRawTransactionVerbose(txid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	descendants, err := client.GetMempoolDescendants(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	if 1+len(descendants) > maxReplacements {
+		return nil, ErrTooManyReplacements
+	}
+
+	if changeOutput < 0 || changeOutput >= len(tx.Vout) {
+		return nil, fmt.Errorf("fees: transaction %v has no output %v", txid, changeOutput)
+	}
+
+	inputs := make([]*types.CreateTxInput, len(tx.Vin))
+	prevTxs := make([]*types.PreviousTransaction, len(tx.Vin))
+
+	for idx, vin := range tx.Vin {
+		inputs[idx] = &types.CreateTxInput{Txid: vin.Txid, Vout: vin.Vout, Sequence: min(vin.Sequence, maxSequence)}
+
+		prevTxs[idx], err = previousTransaction(client, vin.Txid, vin.Vout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outputs := make([]map[string]string, len(tx.Vout))
+	for idx, vout := range tx.Vout {
+		outputs[idx], err = output(vout, sats(vout.Value))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		baseFee      = sats(entry.Fees.Base)
+		modifiedFee  = sats(entry.Fees.Modified)
+		replacedFees = sats(entry.Fees.Descendant)
+		change       = sats(tx.Vout[changeOutput].Value)
+	)
+
+	requiredFee := func(vsize int) int64 {
+		return max(
+			feeAt(opts.FeeRate, vsize),
+			// Rules 3 and 4: pay for everything evicted, and for relaying the replacement.
+			replacedFees+feeAt(opts.incrementalFeeRate(), vsize),
+			// Rule 6: a higher fee rate than the original.
+			modifiedFee*int64(vsize)/int64(max(entry.Vsize, 1))+1,
+		)
+	}
+
+	create := func(fee int64) (string, error) {
+		value := change - (fee - baseFee)
+		if value < opts.dustLimit() {
+			return "", ErrInsufficientValue
+		}
+
+		changeOut, err := output(tx.Vout[changeOutput], value)
+		if err != nil {
+			return "", err
+		}
+
+		outputs[changeOutput] = changeOut
+
+		return client.CreateRawTransaction(inputs, outputs, tx.Locktime, true)
+	}
+
+	bump, err := finish(client, opts, entry.Vsize, requiredFee, create, prevTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	bump.FeeRate = float64(bump.Fee) / float64(bump.Vsize)
+	bump.Replaced = append([]string{txid}, descendants...)
+
+	return bump, nil
+}
+
+// CPFP builds a child of the transaction txid, which spends its output vout to address and lifts the fee rate of the
+// package of the child and its unconfirmed ancestors to opts.FeeRate. The child is checked with testmempoolaccept
+// before it is returned.
+func CPFP(client rpcclient.IClient, txid string, vout int, address string, opts *BumpOptions) (*Bump, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	entry, err := client.GetMempoolEntry(txid)
+	if err != nil {
+		return nil, err
+	} else if entry.Fees == nil {
+		return nil, fmt.Errorf("fees: mempool entry of %v has no fees", txid)
+	}
+
+	out, err := client.GetTxOut(txid, vout, true)
+	if err != nil {
+		return nil, err
+	} else if out == nil {
+		return nil, ErrOutputSpent
+	}
+
+	prevTxs := []*types.PreviousTransaction{{
+		Txid:         txid,
+		Vout:         vout,
+		ScriptPubKey: scriptHex(out.ScriptPubKey),
+		Amount:       out.Value,
+	}}
+
+	var (
+		ancestorFees = sats(entry.Fees.Ancestor)
+		ancestorSize = entry.AncestorSize
+		value        = sats(out.Value)
+	)
+
+	requiredFee := func(vsize int) int64 {
+		// The child pays at least the fee rate on its own, in case the ancestors already do.
+		return max(feeAt(opts.FeeRate, ancestorSize+vsize)-ancestorFees, feeAt(opts.FeeRate, vsize))
+	}
+
+	create := func(fee int64) (string, error) {
+		if value-fee < opts.dustLimit() {
+			return "", ErrInsufficientValue
+		}
+
+		inputs := []*types.CreateTxInput{{Txid: txid, Vout: vout}}
+		outputs := []map[string]string{{address: btc(value - fee)}}
+
+		return client.CreateRawTransaction(inputs, outputs, 0, true)
+	}
+
+	bump, err := finish(client, opts, 0, requiredFee, create, prevTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	bump.FeeRate = float64(ancestorFees+bump.Fee) / float64(ancestorSize+bump.Vsize)
+
+	return bump, nil
+}
+
+// finish creates and signs the bump with the fee required for its size, starting from an estimate of vsize, and checks
+// it with testmempoolaccept.
+func finish(client rpcclient.IClient, opts *BumpOptions, vsize int, requiredFee func(vsize int) int64,
+	create func(fee int64) (string, error), prevTxs []*types.PreviousTransaction,
+) (*Bump, error) {
+	for attempt := 0; attempt < maxSignAttempts; attempt++ {
+		fee := requiredFee(vsize)
+
+		unsigned, err := create(fee)
+		if err != nil {
+			return nil, err
+		}
+
+		signed, err := opts.Signer(unsigned, prevTxs)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded, err := client.DecodeRawTransaction(signed, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// The size is only known after signing, as signatures vary in size.
+		if requiredFee(decoded.Vsize) > fee {
+			vsize = max(vsize, decoded.Vsize)
+			continue
+		}
+
+		res, err := client.TestMempoolAccept([]string{signed}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(res) == 0 || !res[0].Allowed {
+			reason := "unknown"
+			if len(res) > 0 {
+				reason = res[0].RejectReason
+			}
+
+			return nil, &RejectedError{Reason: reason}
+		}
+
+		return &Bump{Hex: signed, Txid: decoded.Txid, Vsize: decoded.Vsize, Fee: fee}, nil
+	}
+
+	return nil, ErrSizeChanged
+}
+
+// previousTransaction returns the output vout of txid for signing.
+func previousTransaction(client rpcclient.IClient, txid string, vout int) (*types.PreviousTransaction, error) {
+	tx, err := client.GetRawTransactionVerbose(txid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if vout < 0 || vout >= len(tx.Vout) {
+		return nil, fmt.Errorf("fees: transaction %v has no output %v", txid, vout)
+	}
+
+	return &types.PreviousTransaction{
+		Txid:         txid,
+		Vout:         vout,
+		ScriptPubKey: scriptHex(tx.Vout[vout].ScriptPubKey),
+		Amount:       tx.Vout[vout].Value,
+	}, nil
+}
+
+// output returns vout paying value satoshis in the format of createrawtransaction.
+func output(vout *types.Vout, value int64) (map[string]string, error) {
+	if vout.ScriptPubKey != nil {
+		if address := vout.ScriptPubKey.GetAddress(); address != "" {
+			return map[string]string{address: btc(value)}, nil
+		}
+
+		if data, ok := nullData(scriptHex(vout.ScriptPubKey)); ok && value == 0 {
+			return map[string]string{"data": data}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("fees: output %v has no address", vout.N)
+}
+
+// nullData returns the data pushed by an OP_RETURN script in hex.
+func nullData(script string) (string, bool) {
+	b, err := hex.DecodeString(script)
+	if err != nil || len(b) < 1 || b[0] != 0x6a {
+		return "", false
+	}
+
+	switch {
+	case len(b) == 1:
+		return "", true
+	case b[1] <= 0x4b && len(b) == 2+int(b[1]):
+		return hex.EncodeToString(b[2:]), true
+	case b[1] == 0x4c && len(b) > 2 && len(b) == 3+int(b[2]):
+		return hex.EncodeToString(b[3:]), true
+	}
+
+	return "", false
+}
+
+// scriptHex returns the script of spk in hex.
+func scriptHex(spk *types.ScriptPubKey) string {
+	if spk == nil || spk.RedeemScript == nil || spk.RedeemScript.ScriptSig == nil {
+		return ""
+	}
+
+	return spk.Hex
+}
+
+// feeAt returns the fee in satoshis for vsize at feeRate in sat/vB, rounded up.
+func feeAt(feeRate float64, vsize int) int64 {
+	return int64(math.Ceil(math.Round(feeRate*float64(vsize)*1e3) / 1e3))
+}
+
+// sats converts an amount in BTC to satoshis.
+func sats(amount float64) int64 {
+	return int64(math.Round(amount * 1e8))
+}
+
+// btc formats an amount in satoshis in BTC for createrawtransaction.
+func btc(amount int64) string {
+	return strconv.FormatFloat(float64(amount)/1e8, 'f', 8, 64)
+}
+
+func (o *BumpOptions) validate() error {
+	switch {
+	case o == nil || o.FeeRate <= 0:
+		return ErrNoFeeRate
+	case o.Signer == nil:
+		return ErrNoSigner
+	}
+
+	return nil
+}
+
+func (o *BumpOptions) incrementalFeeRate() float64 {
+	if o.IncrementalFeeRate > 0 {
+		return o.IncrementalFeeRate
+	}
+
+	return DefaultIncrementalFeeRate
+}
+
+func (o *BumpOptions) dustLimit() int64 {
+	if o.DustLimit > 0 {
+		return o.DustLimit
+	}
+
+	return DefaultDustLimit
+}
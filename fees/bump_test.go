@@ -0,0 +1,259 @@
+package fees
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// created is a transaction made by createrawtransaction of bumpNode, which encodes its params as the raw transaction.
+type created struct {
+	Inputs      []*types.CreateTxInput `json:"inputs"`
+	Outputs     []map[string]string    `json:"outputs"`
+	Locktime    int                    `json:"locktime"`
+	Replaceable bool                   `json:"replaceable"`
+}
+
+// bumpNode serves the calls needed to bump a transaction "orig" with 2 inputs, paying 2000 sat at 10 sat/vB.
+type bumpNode struct {
+	// noFees leaves out the fees of the mempool entry, like nodes before version 0.19.
+	noFees bool
+	// descendants are the txids of the descendants of orig, which pay descendantFee sat in total.
+	descendants   []string
+	descendantFee int64
+
+	// vsizes are the sizes of the signed transactions, in the order they are decoded. The last one is repeated.
+	vsizes []int
+	// rejectReason makes testmempoolaccept reject the bump.
+	rejectReason string
+	spent        bool
+
+	decoded []*created
+	prevTxs []*types.PreviousTransaction
+}
+
+// script returns an output script in hex.
+func script(hexScript string) *types.ScriptPubKey {
+	return &types.ScriptPubKey{RedeemScript: &types.RedeemScript{ScriptSig: &types.ScriptSig{Hex: hexScript}}}
+}
+
+func (n *bumpNode) client(t *testing.T) *rpcclient.Client {
+	return rpcclient.NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		var res any
+
+		switch method {
+		case "getmempoolentry":
+			res = &types.MempoolTransaction{
+				Vsize:        200,
+				AncestorSize: 200,
+				Fees:         &types.MempoolTransactionFees{Base: 0.00002, Modified: 0.00002, Ancestor: 0.00002, Descendant: float64(2000+n.descendantFee) / 1e8},
+			}
+			if n.noFees {
+				res.(*types.MempoolTransaction).Fees = nil
+			}
+		case "getmempooldescendants":
+			res = append([]string{}, n.descendants...)
+		case "getrawtransaction":
+			switch params[0] {
+			case "orig":
+				opReturn := script("6a0401020304")
+				opReturn.Type = "nulldata"
+
+				res = &types.Transaction{
+					Txid:     "orig",
+					Locktime: 100,
+					Vin:      []*types.Vin{{Txid: "prev1", Vout: 0, Sequence: 0xffffffff}, {Txid: "prev2", Vout: 1, Sequence: 0xfffffffd}},
+					Vout: []*types.Vout{
+						{Value: 0.5, N: 0, ScriptPubKey: &types.ScriptPubKey{Address: "bcrt1dest"}},
+						{Value: 0.2, N: 1, ScriptPubKey: &types.ScriptPubKey{Address: "bcrt1change"}},
+						{Value: 0, N: 2, ScriptPubKey: opReturn},
+					},
+				}
+			default:
+				res = &types.Transaction{Vout: []*types.Vout{{Value: 0.4, ScriptPubKey: script("0014aa")}, {Value: 0.3, ScriptPubKey: script("0014bb")}}}
+			}
+		case "gettxout":
+			if !n.spent {
+				res = &types.TransactionOut{Value: 0.2, ScriptPubKey: script("0014cc")}
+			}
+		case "createrawtransaction":
+			b, err := json.Marshal(&created{params[0].([]*types.CreateTxInput), params[1].([]map[string]string), params[2].(int), params[3].(bool)})
+			require.NoError(t, err)
+
+			res = hex.EncodeToString(b)
+		case "decoderawtransaction":
+			b, err := hex.DecodeString(params[0].(string))
+			require.NoError(t, err)
+
+			tx := &created{}
+			require.NoError(t, json.Unmarshal(b, tx))
+
+			vsize := n.vsizes[min(len(n.decoded), len(n.vsizes)-1)]
+			n.decoded = append(n.decoded, tx)
+			res = &types.Transaction{Txid: "bump", Vsize: vsize}
+		case "testmempoolaccept":
+			res = []*types.TestMempoolAcceptResult{{Txid: "bump", Allowed: n.rejectReason == "", RejectReason: n.rejectReason}}
+		default:
+			return &rpcclient.RPCError{Code: -32601, Message: "Method not found"}
+		}
+
+		data, err := json.Marshal(res)
+		require.NoError(t, err)
+
+		return json.Unmarshal(data, result)
+	})
+}
+
+func (n *bumpNode) signer(unsigned string, prevTxs []*types.PreviousTransaction) (string, error) {
+	n.prevTxs = prevTxs
+
+	return unsigned, nil
+}
+
+func TestReplace(t *testing.T) {
+	node := &bumpNode{descendants: []string{"child"}, descendantFee: 1000, vsizes: []int{210}}
+
+	bump, err := Replace(node.client(t), "orig", 1, &BumpOptions{FeeRate: 20, Signer: node.signer})
+	require.NoError(t, err)
+
+	// The signed transaction is larger than the original, so the fee is raised once.
+	assert.Equal(t, &Bump{Hex: bump.Hex, Txid: "bump", Vsize: 210, Fee: 4200, FeeRate: 20, Replaced: []string{"orig", "child"}}, bump)
+	require.Len(t, node.decoded, 2)
+
+	assert.Equal(t, &created{
+		Inputs:      []*types.CreateTxInput{{Txid: "prev1", Vout: 0, Sequence: 0xfffffffd}, {Txid: "prev2", Vout: 1, Sequence: 0xfffffffd}},
+		Outputs:     []map[string]string{{"bcrt1dest": "0.50000000"}, {"bcrt1change": "0.19997800"}, {"data": "01020304"}},
+		Locktime:    100,
+		Replaceable: true,
+	}, node.decoded[1])
+
+	assert.Equal(t, []*types.PreviousTransaction{
+		{Txid: "prev1", Vout: 0, ScriptPubKey: "0014aa", Amount: 0.4},
+		{Txid: "prev2", Vout: 1, ScriptPubKey: "0014bb", Amount: 0.3},
+	}, node.prevTxs)
+
+	// Evicting descendants paying a high fee makes rules 3 and 4 decide the fee.
+	node = &bumpNode{descendants: []string{"child"}, descendantFee: 10000, vsizes: []int{200}}
+
+	bump, err = Replace(node.client(t), "orig", 1, &BumpOptions{FeeRate: 11, Signer: node.signer})
+	require.NoError(t, err)
+	assert.Equal(t, int64(12000+200), bump.Fee)
+	assert.Equal(t, "0.19989800", node.decoded[0].Outputs[1]["bcrt1change"])
+
+	// Rule 6: the fee rate is higher than the one of the original, even at a lower target.
+	node = &bumpNode{vsizes: []int{200}}
+
+	bump, err = Replace(node.client(t), "orig", 1, &BumpOptions{FeeRate: 1, IncrementalFeeRate: 0.0001, Signer: node.signer})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2001), bump.Fee)
+}
+
+func TestReplace_Errors(t *testing.T) {
+	node := &bumpNode{vsizes: []int{200}}
+	client := node.client(t)
+
+	_, err := Replace(client, "orig", 1, &BumpOptions{Signer: node.signer})
+	assert.ErrorIs(t, err, ErrNoFeeRate)
+
+	_, err = Replace(client, "orig", 1, &BumpOptions{FeeRate: 20})
+	assert.ErrorIs(t, err, ErrNoSigner)
+
+	_, err = Replace(client, "orig", 3, &BumpOptions{FeeRate: 20, Signer: node.signer})
+	assert.EqualError(t, err, "fees: transaction orig has no output 3")
+
+	_, err = Replace(client, "orig", 1, &BumpOptions{FeeRate: 200000, Signer: node.signer})
+	assert.ErrorIs(t, err, ErrInsufficientValue)
+
+	for idx := 0; idx < maxReplacements; idx++ {
+		node.descendants = append(node.descendants, fmt.Sprint(idx))
+	}
+
+	_, err = Replace(client, "orig", 1, &BumpOptions{FeeRate: 20, Signer: node.signer})
+	assert.ErrorIs(t, err, ErrTooManyReplacements)
+
+	node.descendants = nil
+	node.rejectReason = "insufficient fee"
+
+	_, err = Replace(client, "orig", 1, &BumpOptions{FeeRate: 20, Signer: node.signer})
+	assert.Equal(t, &RejectedError{Reason: "insufficient fee"}, err)
+
+	// The size keeps growing beyond the fee.
+	node = &bumpNode{vsizes: []int{210, 220, 230, 240}}
+
+	_, err = Replace(node.client(t), "orig", 1, &BumpOptions{FeeRate: 20, Signer: node.signer})
+	assert.ErrorIs(t, err, ErrSizeChanged)
+
+	node = &bumpNode{vsizes: []int{200}, noFees: true}
+
+	_, err = Replace(node.client(t), "orig", 1, &BumpOptions{FeeRate: 20, Signer: node.signer})
+	assert.EqualError(t, err, "fees: mempool entry of orig has no fees")
+
+	_, err = CPFP(node.client(t), "orig", 1, "bcrt1new", &BumpOptions{FeeRate: 20, Signer: node.signer})
+	assert.EqualError(t, err, "fees: mempool entry of orig has no fees")
+}
+
+func TestCPFP(t *testing.T) {
+	node := &bumpNode{vsizes: []int{110}}
+
+	bump, err := CPFP(node.client(t), "orig", 1, "bcrt1new", &BumpOptions{FeeRate: 20, Signer: node.signer})
+	require.NoError(t, err)
+
+	// The package of 200 + 110 vB pays 2000 + 4200 sat.
+	assert.Equal(t, &Bump{Hex: bump.Hex, Txid: "bump", Vsize: 110, Fee: 4200, FeeRate: 20}, bump)
+	require.Len(t, node.decoded, 2)
+
+	assert.Equal(t, &created{
+		Inputs:      []*types.CreateTxInput{{Txid: "orig", Vout: 1}},
+		Outputs:     []map[string]string{{"bcrt1new": "0.19995800"}},
+		Replaceable: true,
+	}, node.decoded[1])
+	assert.Equal(t, []*types.PreviousTransaction{{Txid: "orig", Vout: 1, ScriptPubKey: "0014cc", Amount: 0.2}}, node.prevTxs)
+
+	// When the parent already pays the target, the child pays the target for itself.
+	bump, err = CPFP(node.client(t), "orig", 1, "bcrt1new", &BumpOptions{FeeRate: 5, Signer: node.signer})
+	require.NoError(t, err)
+	assert.Equal(t, int64(550), bump.Fee)
+
+	node.spent = true
+
+	_, err = CPFP(node.client(t), "orig", 1, "bcrt1new", &BumpOptions{FeeRate: 20, Signer: node.signer})
+	assert.ErrorIs(t, err, ErrOutputSpent)
+}
+
+func TestKeySigner(t *testing.T) {
+	var complete bool
+
+	client := rpcclient.NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		assert.Equal(t, "signrawtransactionwithkey", method)
+		assert.Equal(t, []string{"key"}, params[1])
+
+		res := &types.SignRawTransactionResult{Hex: "signed", Complete: complete}
+		if !complete {
+			res.Errors = []*types.SignRawTransactionResultError{{Error: "Unable to sign input"}}
+		}
+
+		data, err := json.Marshal(res)
+		require.NoError(t, err)
+
+		return json.Unmarshal(data, result)
+	})
+
+	signer := KeySigner(client, []string{"key"})
+	prevTxs := []*types.PreviousTransaction{{Txid: "orig"}}
+
+	_, err := signer("unsigned", prevTxs)
+	assert.EqualError(t, err, "fees: signing: Unable to sign input")
+
+	complete = true
+
+	signed, err := signer("unsigned", prevTxs)
+	require.NoError(t, err)
+	assert.Equal(t, "signed", signed)
+}
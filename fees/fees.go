@@ -0,0 +1,402 @@
+// Package fees recommends fee rates for named urgency levels, combining the fee estimator of the node with the state of
+// its mempool and the fee rates of recent blocks.
+//
+//	rec, err := fees.Recommend(client, nil)
+//	fast := rec.Get(fees.Fast)
+//	fmt.Printf("%.1f sat/vB (%v confidence)\n", fast.FeeRate, fast.Confidence)
+//
+// The estimator of the node needs to see transactions confirm before it has data, so shortly after startup
+// estimatesmartfee has no estimates. Recommend then falls back to projecting blocks from the mempool and to the fee
+// rates of recent blocks, with a low confidence.
+//
+// Stuck transactions can be bumped with Replace, which builds a BIP125 replacement, and CPFP, which builds a child
+// paying for its ancestors.
+package fees
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// DefaultBlocks is the number of recent blocks whose fee rates are used, if Options.Blocks is not set.
+const DefaultBlocks = 6
+
+// blockVsize is the maximum virtual size of a block, used to project blocks from the mempool.
+const blockVsize = 1_000_000
+
+// btcPerKvBToSatPerVB converts a fee rate in BTC/kvB to sat/vB.
+const btcPerKvBToSatPerVB = 1e8 / 1e3
+
+// minRawSamples is the number of confirmed transactions in the passing bucket of estimaterawfee below which the
+// estimate isn't trusted with a high confidence.
+const minRawSamples = 10
+
+// Level is a named urgency level.
+type Level int
+
+// The urgency levels, from the most to the least urgent.
+const (
+	// Fastest aims for the next block.
+	Fastest Level = iota
+	// Fast aims for confirmation within 3 blocks, about half an hour.
+	Fast
+	// Normal aims for confirmation within 6 blocks, about an hour.
+	Normal
+	// Slow aims for confirmation within 24 blocks, about four hours.
+	Slow
+	// Economy aims for confirmation within 144 blocks, about a day.
+	Economy
+)
+
+// levels are all urgency levels, from the most to the least urgent.
+var levels = []Level{Fastest, Fast, Normal, Slow, Economy}
+
+// levelTargets are the default confirmation targets of the urgency levels.
+var levelTargets = map[Level]int{
+	Fastest: 1,
+	Fast:    3,
+	Normal:  6,
+	Slow:    24,
+	Economy: 144,
+}
+
+// levelPercentiles are the indexes into BlockStats.FeeratePercentiles used for each urgency level when only recent
+// blocks are known.
+var levelPercentiles = map[Level]int{
+	Fastest: 3,
+	Fast:    2,
+	Normal:  1,
+	Slow:    0,
+	Economy: 0,
+}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case Fastest:
+		return "fastest"
+	case Fast:
+		return "fast"
+	case Normal:
+		return "normal"
+	case Slow:
+		return "slow"
+	case Economy:
+		return "economy"
+	}
+
+	return "unknown"
+}
+
+// Confidence indicates how much a recommended fee rate can be relied on.
+type Confidence int
+
+const (
+	// ConfidenceLow means the node's estimator had no data, and the fee rate is derived from the mempool or recent
+	// blocks only.
+	ConfidenceLow Confidence = iota
+	// ConfidenceMedium means the estimator only had data for a longer confirmation target, or too few samples.
+	ConfidenceMedium
+	// ConfidenceHigh means the estimator had enough data for the confirmation target.
+	ConfidenceHigh
+)
+
+// String returns the name of the confidence.
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	}
+
+	return "unknown"
+}
+
+// Source is the data a recommended fee rate is based on.
+type Source string
+
+const (
+	// SourceEstimator is the fee estimator of the node, estimatesmartfee.
+	SourceEstimator Source = "estimator"
+	// SourceMempool is the projection of the next blocks from the mempool.
+	SourceMempool Source = "mempool"
+	// SourceBlocks are the fee rate percentiles of recent blocks.
+	SourceBlocks Source = "blocks"
+	// SourceMinimum is the minimum fee rate accepted by the mempool.
+	SourceMinimum Source = "minimum"
+)
+
+// Options are the options for Recommend.
+type Options struct {
+	// Blocks is the number of recent blocks whose fee rate percentiles are used. Defaults to DefaultBlocks.
+	Blocks int
+
+	// Targets overrides the confirmation targets in blocks of the urgency levels.
+	Targets map[Level]int
+}
+
+// Estimate is the recommended fee rate for an urgency level.
+type Estimate struct {
+	// Level is the urgency level.
+	Level Level
+
+	// Target is the confirmation target of the level in blocks.
+	Target int
+
+	// FeeRate is the recommended fee rate in sat/vB.
+	FeeRate float64
+
+	// Confidence indicates how much FeeRate can be relied on.
+	Confidence Confidence
+
+	// Source is the data FeeRate is based on.
+	Source Source
+}
+
+// Recommendation holds the recommended fee rates of all urgency levels. A more urgent level never has a lower fee rate
+// than a less urgent one.
+type Recommendation struct {
+	// Estimates are the estimates of each urgency level, from the most to the least urgent.
+	Estimates []*Estimate
+
+	// MinFeeRate is the minimum fee rate in sat/vB for a transaction to be accepted by the mempool.
+	MinFeeRate float64
+
+	// Histogram is the fee rate histogram of the mempool.
+	Histogram Histogram
+
+	// BlockFeeRates are the fee rate percentiles of recent blocks in sat/vB, from the newest block. See
+	// types.BlockStats.FeeratePercentiles.
+	BlockFeeRates [][]int
+}
+
+// Get returns the estimate for level, or nil if there is none.
+func (r *Recommendation) Get(level Level) *Estimate {
+	for _, estimate := range r.Estimates {
+		if estimate.Level == level {
+			return estimate
+		}
+	}
+
+	return nil
+}
+
+// Recommend returns the recommended fee rates for every urgency level.
+//
+// The fee rate of a level is the estimate of estimatesmartfee, in conservative mode for Fastest and Fast and economical
+// mode otherwise. It is raised to the fee rate needed to get into the projected blocks of the mempool when that is
+// higher, as the estimator only learns about a sudden rise in fee rates when blocks confirm. Without an estimate, the
+// fee rate is taken from the mempool projection, and from the fee rates of recent blocks if the mempool is empty.
+// Fee rates are never below the minimum fee rate of the mempool.
+func Recommend(client rpcclient.IClient, opts *Options) (*Recommendation, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	info, err := client.GetMempoolInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	mempool, err := client.GetRawMempoolVerbose()
+	if err != nil {
+		return nil, err
+	}
+
+	blockFeeRates, err := recentFeeRates(client, opts.blocks())
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &Recommendation{
+		MinFeeRate:    roundUp(max(info.MempoolMinFee, info.MinRelayTxFee) * btcPerKvBToSatPerVB),
+		Histogram:     NewHistogram(mempool),
+		BlockFeeRates: blockFeeRates,
+	}
+
+	for _, level := range levels {
+		estimate, err := rec.estimate(client, level, opts.target(level))
+		if err != nil {
+			return nil, err
+		}
+
+		rec.Estimates = append(rec.Estimates, estimate)
+	}
+
+	// Make sure paying for more urgency never gets a lower fee rate.
+	for idx := len(rec.Estimates) - 2; idx >= 0; idx-- {
+		rec.Estimates[idx].FeeRate = max(rec.Estimates[idx].FeeRate, rec.Estimates[idx+1].FeeRate)
+	}
+
+	return rec, nil
+}
+
+// estimate returns the estimate for level with a confirmation target of target blocks.
+func (r *Recommendation) estimate(client rpcclient.IClient, level Level, target int) (*Estimate, error) {
+	estimate := &Estimate{Level: level, Target: target}
+
+	smart, blocks, err := smartFeeRate(client, level, target)
+	if err != nil {
+		return nil, err
+	}
+
+	projected := r.Histogram.FeeRateAt(target * blockVsize)
+
+	switch {
+	case smart > 0:
+		estimate.FeeRate, estimate.Source = max(smart, projected), SourceEstimator
+		estimate.Confidence = ConfidenceMedium
+
+		// The estimator falls back to a longer target when it has no data for target, and its shortest target is 2.
+		if blocks <= max(target, 2) {
+			estimate.Confidence, err = confidence(client, target)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case r.Histogram.Vsize() > 0:
+		estimate.FeeRate, estimate.Source = projected, SourceMempool
+	case len(r.BlockFeeRates) > 0:
+		estimate.FeeRate, estimate.Source = r.blockFeeRate(level), SourceBlocks
+	}
+
+	if estimate.FeeRate <= r.MinFeeRate {
+		estimate.FeeRate = r.MinFeeRate
+
+		if estimate.Source != SourceEstimator {
+			estimate.Source = SourceMinimum
+		}
+	}
+
+	estimate.FeeRate = roundUp(estimate.FeeRate)
+
+	return estimate, nil
+}
+
+// blockFeeRate returns the median over recent blocks of the fee rate percentile of level.
+func (r *Recommendation) blockFeeRate(level Level) float64 {
+	var rates []float64
+
+	for _, percentiles := range r.BlockFeeRates {
+		if idx := levelPercentiles[level]; idx < len(percentiles) {
+			rates = append(rates, float64(percentiles[idx]))
+		}
+	}
+
+	if len(rates) == 0 {
+		return 0
+	}
+
+	sort.Float64s(rates)
+
+	if len(rates)%2 == 0 {
+		return (rates[len(rates)/2-1] + rates[len(rates)/2]) / 2
+	}
+
+	return rates[len(rates)/2]
+}
+
+// roundUp rounds a fee rate in sat/vB up to the precision of the mempool, sat/kvB. It is rounded to the nearest
+// sat/MvB first, so the error of the conversion from BTC/kvB doesn't round it up.
+func roundUp(feeRate float64) float64 {
+	return math.Ceil(math.Round(feeRate*1e6)/1e3) / 1e3
+}
+
+// smartFeeRate returns the fee rate in sat/vB of estimatesmartfee for level and the target it is for, or 0 if there is
+// no estimate.
+func smartFeeRate(client rpcclient.IClient, level Level, target int) (float64, int, error) {
+	mode := types.EstimateModeEconomical
+	if level <= Fast {
+		mode = types.EstimateModeConservative
+	}
+
+	res, err := client.EstimateSmartFee(target, &mode)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return res.FeeRate * btcPerKvBToSatPerVB, res.Blocks, nil
+}
+
+// confidence returns the confidence of the estimator for target, based on the statistics of estimaterawfee at the
+// shortest horizon covering target.
+func confidence(client rpcclient.IClient, target int) (Confidence, error) {
+	res, err := client.EstimateRawFee(target, nil)
+
+	var rpcErr *rpcclient.RPCError
+	if errors.As(err, &rpcErr) {
+		// Nodes which don't support estimaterawfee, or have it disabled.
+		return ConfidenceMedium, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var raw *types.RawFeeEstimate
+
+	for _, horizon := range []*types.RawFeeEstimate{res.Short, res.Medium, res.Long} {
+		if horizon != nil {
+			raw = horizon
+			break
+		}
+	}
+
+	if raw == nil || raw.FeeRate <= 0 || raw.Pass == nil || raw.Pass.TotalConfirmed < minRawSamples {
+		return ConfidenceMedium, nil
+	}
+
+	return ConfidenceHigh, nil
+}
+
+// recentFeeRates returns the fee rate percentiles of the last n blocks, from the newest. Blocks whose stats can't be
+// retrieved, eg because they were pruned, are skipped.
+func recentFeeRates(client rpcclient.IClient, n int) ([][]int, error) {
+	height, err := client.GetBlockCount()
+	if err != nil {
+		return nil, err
+	}
+
+	var rates [][]int
+
+	for h := height; h > height-int64(n) && h > 0; h-- {
+		stats, err := client.GetBlockStatsHeight(int(h))
+
+		var rpcErr *rpcclient.RPCError
+		if errors.As(err, &rpcErr) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Blocks without transactions besides the coinbase have all percentiles at 0.
+		if len(stats.FeeratePercentiles) > 0 && stats.FeeratePercentiles[len(stats.FeeratePercentiles)-1] > 0 {
+			rates = append(rates, stats.FeeratePercentiles)
+		}
+	}
+
+	return rates, nil
+}
+
+// blocks returns the number of recent blocks whose fee rates are used.
+func (o *Options) blocks() int {
+	if o.Blocks > 0 {
+		return o.Blocks
+	}
+
+	return DefaultBlocks
+}
+
+// target returns the confirmation target of level, which defaults to levelTargets.
+func (o *Options) target(level Level) int {
+	if target, ok := o.Targets[level]; ok && target > 0 {
+		return target
+	}
+
+	return levelTargets[level]
+}
@@ -0,0 +1,217 @@
+package fees
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNode serves the fee related calls of a node.
+type testNode struct {
+	// smart maps confirmation targets to the result of estimatesmartfee. Targets without one have no data.
+	smart map[int]*types.EstimateSmartFeeResult
+	// rawSamples is the number of transactions confirmed in the passing bucket of estimaterawfee.
+	rawSamples float64
+	mempool    map[string]*types.MempoolTransaction
+	blocks     []*types.BlockStats
+	minFee     float64
+
+	modes map[int]types.EstimateMode
+}
+
+func (n *testNode) client(t *testing.T) *rpcclient.Client {
+	n.modes = map[int]types.EstimateMode{}
+
+	return rpcclient.NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		var res any
+
+		switch method {
+		case "getmempoolinfo":
+			res = &types.MempoolInfo{Loaded: true, MempoolMinFee: n.minFee, MinRelayTxFee: 0.00001}
+		case "getrawmempool":
+			res = n.mempool
+		case "getblockcount":
+			res = len(n.blocks)
+		case "getblockstats":
+			height := params[0].(int)
+			if height < len(n.blocks)-2 {
+				return &rpcclient.RPCError{Code: -1, Message: "Block not available (pruned data)"}
+			}
+
+			res = n.blocks[height-1]
+		case "estimatesmartfee":
+			target := params[0].(int)
+			n.modes[target] = params[1].(types.EstimateMode)
+
+			res = &types.EstimateSmartFeeResult{Errors: []string{"Insufficient data or no feerate found"}, Blocks: target}
+			if smart, ok := n.smart[target]; ok {
+				res = smart
+			}
+		case "estimaterawfee":
+			res = &types.EstimateRawFeeResult{Short: &types.RawFeeEstimate{
+				FeeRate: 0.0001,
+				Pass:    &types.FeeRateBucket{TotalConfirmed: n.rawSamples},
+			}}
+		default:
+			return &rpcclient.RPCError{Code: -32601, Message: "Method not found"}
+		}
+
+		data, err := json.Marshal(res)
+		require.NoError(t, err)
+
+		return json.Unmarshal(data, result)
+	})
+}
+
+// mempoolTxs adds count transactions of 1000 vB at feeRate sat/vB, without ancestors.
+func mempoolTxs(mempool map[string]*types.MempoolTransaction, count int, feeRate float64) {
+	for idx := 0; idx < count; idx++ {
+		fee := feeRate * 1000 / 1e8
+		mempool[fmt.Sprintf("%v-%v", feeRate, idx)] = &types.MempoolTransaction{
+			Vsize:        1000,
+			AncestorSize: 1000,
+			Fees:         &types.MempoolTransactionFees{Base: fee, Modified: fee, Ancestor: fee, Descendant: fee},
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	// A backlog of 1.5 blocks at 50 sat/vB, which the estimator hasn't caught up with yet.
+	mempool := map[string]*types.MempoolTransaction{}
+	mempoolTxs(mempool, 1500, 50)
+	mempoolTxs(mempool, 500, 2)
+
+	node := &testNode{
+		smart: map[int]*types.EstimateSmartFeeResult{
+			1: {FeeRate: 0.0002, Blocks: 2},
+			3: {FeeRate: 0.00015, Blocks: 3},
+			// The estimator only has data for a longer target.
+			6: {FeeRate: 0.00008, Blocks: 12},
+			// Estimates may be lower for a shorter target.
+			24:  {FeeRate: 0.0001, Blocks: 24},
+			144: {FeeRate: 0.00001, Blocks: 144},
+		},
+		rawSamples: 100,
+		mempool:    mempool,
+		blocks:     []*types.BlockStats{{FeeratePercentiles: []int{1, 2, 3, 4, 5}}},
+		minFee:     0.00002,
+	}
+
+	rec, err := Recommend(node.client(t), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2.0, rec.MinFeeRate)
+	assert.Equal(t, 2000000, rec.Histogram.Vsize())
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5}}, rec.BlockFeeRates)
+
+	assert.Equal(t, []*Estimate{
+		{Level: Fastest, Target: 1, FeeRate: 50, Confidence: ConfidenceHigh, Source: SourceEstimator},
+		{Level: Fast, Target: 3, FeeRate: 15, Confidence: ConfidenceHigh, Source: SourceEstimator},
+		{Level: Normal, Target: 6, FeeRate: 10, Confidence: ConfidenceMedium, Source: SourceEstimator},
+		{Level: Slow, Target: 24, FeeRate: 10, Confidence: ConfidenceHigh, Source: SourceEstimator},
+		{Level: Economy, Target: 144, FeeRate: 2, Confidence: ConfidenceHigh, Source: SourceEstimator},
+	}, rec.Estimates)
+
+	assert.Equal(t, types.EstimateModeConservative, node.modes[1])
+	assert.Equal(t, types.EstimateModeConservative, node.modes[3])
+	assert.Equal(t, types.EstimateModeEconomical, node.modes[6])
+
+	// Too few samples lower the confidence, and targets can be changed.
+	node.rawSamples = 1
+
+	rec, err = Recommend(node.client(t), &Options{Targets: map[Level]int{Economy: 24}})
+	require.NoError(t, err)
+	assert.Equal(t, ConfidenceMedium, rec.Get(Fast).Confidence)
+	assert.Equal(t, &Estimate{Level: Economy, Target: 24, FeeRate: 10, Confidence: ConfidenceMedium, Source: SourceEstimator}, rec.Get(Economy))
+}
+
+func TestRecommend_NoEstimates(t *testing.T) {
+	// After startup, the estimator has no data yet.
+	mempool := map[string]*types.MempoolTransaction{}
+	mempoolTxs(mempool, 1200, 20)
+
+	node := &testNode{
+		mempool: mempool,
+		blocks: []*types.BlockStats{
+			{FeeratePercentiles: []int{1, 5, 8, 12, 30}},
+			{FeeratePercentiles: []int{2, 3, 6, 10, 20}},
+			{FeeratePercentiles: []int{3, 4, 7, 11, 25}},
+			{FeeratePercentiles: []int{0, 0, 0, 0, 0}},
+			{FeeratePercentiles: []int{1, 2, 5, 9, 15}},
+		},
+		minFee: 0.00001,
+	}
+
+	rec, err := Recommend(node.client(t), nil)
+	require.NoError(t, err)
+
+	// Only the stats of the last 3 blocks are available, and empty blocks are skipped.
+	assert.Equal(t, [][]int{{1, 2, 5, 9, 15}, {3, 4, 7, 11, 25}}, rec.BlockFeeRates)
+
+	assert.Equal(t, []*Estimate{
+		{Level: Fastest, Target: 1, FeeRate: 20, Confidence: ConfidenceLow, Source: SourceMempool},
+		{Level: Fast, Target: 3, FeeRate: 1, Confidence: ConfidenceLow, Source: SourceMinimum},
+		{Level: Normal, Target: 6, FeeRate: 1, Confidence: ConfidenceLow, Source: SourceMinimum},
+		{Level: Slow, Target: 24, FeeRate: 1, Confidence: ConfidenceLow, Source: SourceMinimum},
+		{Level: Economy, Target: 144, FeeRate: 1, Confidence: ConfidenceLow, Source: SourceMinimum},
+	}, rec.Estimates)
+
+	// Without a mempool, the fee rates of recent blocks are used.
+	node.mempool = nil
+
+	rec, err = Recommend(node.client(t), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Estimate{
+		{Level: Fastest, Target: 1, FeeRate: 10, Confidence: ConfidenceLow, Source: SourceBlocks},
+		{Level: Fast, Target: 3, FeeRate: 6, Confidence: ConfidenceLow, Source: SourceBlocks},
+		{Level: Normal, Target: 6, FeeRate: 3, Confidence: ConfidenceLow, Source: SourceBlocks},
+		{Level: Slow, Target: 24, FeeRate: 2, Confidence: ConfidenceLow, Source: SourceBlocks},
+		{Level: Economy, Target: 144, FeeRate: 2, Confidence: ConfidenceLow, Source: SourceBlocks},
+	}, rec.Estimates)
+
+	// Without any data, the minimum fee rate is used.
+	node.blocks = nil
+
+	rec, err = Recommend(node.client(t), nil)
+	require.NoError(t, err)
+
+	for _, estimate := range rec.Estimates {
+		assert.Equal(t, 1.0, estimate.FeeRate)
+		assert.Equal(t, SourceMinimum, estimate.Source)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	mempool := map[string]*types.MempoolTransaction{}
+	mempoolTxs(mempool, 3, 1.5)
+	mempoolTxs(mempool, 2, 12)
+	mempoolTxs(mempool, 1, 5000)
+
+	// A child paying a high fee rate is limited by the low fee rate of its parent.
+	mempool["child"] = &types.MempoolTransaction{
+		Vsize:        200,
+		AncestorSize: 1200,
+		Fees:         &types.MempoolTransactionFees{Modified: 0.0001, Ancestor: 0.000115},
+	}
+
+	h := NewHistogram(mempool)
+	assert.Equal(t, Histogram{
+		{FeeRate: 2000, Vsize: 1000, Count: 1},
+		{FeeRate: 12, Vsize: 2000, Count: 2},
+		{FeeRate: 8, Vsize: 200, Count: 1},
+		{FeeRate: 1, Vsize: 3000, Count: 3},
+	}, h)
+
+	assert.Equal(t, 6200, h.Vsize())
+	assert.Equal(t, 2000.0, h.FeeRateAt(500))
+	assert.Equal(t, 12.0, h.FeeRateAt(3000))
+	assert.Equal(t, 1.0, h.FeeRateAt(6200))
+	assert.Zero(t, h.FeeRateAt(6201))
+}
@@ -0,0 +1,101 @@
+package fees
+
+import (
+	"sort"
+
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// histogramBounds are the lower bounds in sat/vB of the buckets of a Histogram.
+var histogramBounds = []float64{
+	0, 1, 2, 3, 4, 5, 6, 8, 10, 12, 15, 20, 25, 30, 40, 50, 60, 70, 80, 90, 100, 125, 150, 175, 200, 250, 300, 400, 500,
+	600, 700, 800, 900, 1000, 1500, 2000,
+}
+
+// HistogramBucket is a range of fee rates in a Histogram.
+type HistogramBucket struct {
+	// FeeRate is the lowest fee rate of the bucket in sat/vB. The bucket ends at the FeeRate of the next higher one.
+	FeeRate float64
+
+	// Vsize is the total virtual size of the transactions in the bucket.
+	Vsize int
+
+	// Count is the number of transactions in the bucket.
+	Count int
+}
+
+// Histogram is the distribution of the virtual size of the transactions in the mempool over their fee rates, from the
+// highest fee rate. Only buckets holding transactions are included.
+type Histogram []*HistogramBucket
+
+// NewHistogram builds the fee rate histogram of mempool, the result of getrawmempool with verbose set.
+//
+// The fee rate of a transaction is the lower of its own fee rate and the fee rate of it with its ancestors, since it
+// can't be mined before them. This ignores children paying for their parents.
+func NewHistogram(mempool map[string]*types.MempoolTransaction) Histogram {
+	buckets := make([]*HistogramBucket, len(histogramBounds))
+
+	for _, tx := range mempool {
+		if tx.Fees == nil || tx.Vsize <= 0 {
+			continue
+		}
+
+		feeRate := tx.Fees.Modified * 1e8 / float64(tx.Vsize)
+		if tx.AncestorSize > 0 {
+			feeRate = min(feeRate, tx.Fees.Ancestor*1e8/float64(tx.AncestorSize))
+		}
+
+		// Modified fees are negative when a transaction was deprioritised with prioritisetransaction.
+		feeRate = max(feeRate, 0)
+
+		idx := sort.SearchFloat64s(histogramBounds, feeRate)
+		if idx == len(histogramBounds) || histogramBounds[idx] > feeRate {
+			idx--
+		}
+
+		if buckets[idx] == nil {
+			buckets[idx] = &HistogramBucket{FeeRate: histogramBounds[idx]}
+		}
+
+		buckets[idx].Vsize += tx.Vsize
+		buckets[idx].Count++
+	}
+
+	var h Histogram
+
+	for idx := len(buckets) - 1; idx >= 0; idx-- {
+		if buckets[idx] != nil {
+			h = append(h, buckets[idx])
+		}
+	}
+
+	return h
+}
+
+// Vsize returns the total virtual size of the transactions in the histogram.
+func (h Histogram) Vsize() int {
+	var vsize int
+
+	for _, bucket := range h {
+		vsize += bucket.Vsize
+	}
+
+	return vsize
+}
+
+// FeeRateAt returns the lowest fee rate in sat/vB of the transactions within the first vsize virtual bytes of the
+// mempool, ordered by fee rate. It is the fee rate needed to be mined within vsize virtual bytes if no transactions
+// arrive, or 0 if the mempool is smaller than that.
+func (h Histogram) FeeRateAt(vsize int) float64 {
+	var total int
+
+	for _, bucket := range h {
+		total += bucket.Vsize
+
+		if total >= vsize {
+			return bucket.FeeRate
+		}
+	}
+
+	return 0
+}
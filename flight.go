@@ -0,0 +1,137 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flightGroup deduplicates concurrent identical requests, so only one of them is sent to the server.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is a request in flight, shared by every caller waiting for it.
+type flight struct {
+	done chan struct{}
+	raw  json.RawMessage
+	err  error
+
+	// waiters is the number of callers still waiting. The request is cancelled when all of them have given up.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do calls send with base for key, unless a call for key is already in flight, in which case it waits for that call
+// instead. The call is only cancelled once every caller has given up, so base must not be cancelled by a single caller.
+func (g *flightGroup) do(ctx, base context.Context, key string, send func(ctx context.Context) (json.RawMessage, error)) (json.RawMessage, error) {
+	g.mu.Lock()
+	f, ok := g.flights[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+
+		var flightCtx context.Context
+		flightCtx, f.cancel = context.WithCancel(base)
+
+		if g.flights == nil {
+			g.flights = map[string]*flight{}
+		}
+		g.flights[key] = f
+
+		go func() {
+			f.raw, f.err = send(flightCtx)
+			f.cancel()
+
+			g.mu.Lock()
+			g.forget(key, f)
+			g.mu.Unlock()
+
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.raw, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Later callers must not join the cancelled request.
+			g.forget(key, f)
+			f.cancel()
+		}
+		g.mu.Unlock()
+
+		return nil, ctx.Err()
+	}
+}
+
+// forget removes f from the flights in progress, unless it has been replaced already. g.mu must be held.
+func (g *flightGroup) forget(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// sendDeduplicated is the SendFunc of clients with Config.DeduplicateRequests set. Concurrent identical read-only
+// requests share a single request to the server, see flightContext. Each caller decodes its own copy of the result, so
+// callers can modify their results without affecting each other. Streamed results are never shared, as that would
+// buffer them.
+func (c *Client) sendDeduplicated(ctx context.Context, method string, result any, params ...any) error {
+	if _, ok := result.(StreamResult); ok || !isReadOnly(method) {
+		return c.sendHTTP(ctx, method, result, params...)
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	base, baseKey := flightContext(ctx)
+
+	raw, err := c.flights.do(ctx, base, method+string(paramsJSON)+baseKey, func(ctx context.Context) (json.RawMessage, error) {
+		var raw json.RawMessage
+		err := c.sendHTTP(ctx, method, &raw, params...)
+
+		return raw, err
+	})
+	if err != nil || result == nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, result)
+}
+
+// flightContext returns the context a shared request is sent with, and the part of its key which comes from ctx. Only
+// callers with the same request headers, timeout and priority share a request, so the context carries just those.
+// Other values of ctx, like its CallInfo or trace span, belong to a single caller and are not passed on.
+func flightContext(ctx context.Context) (context.Context, string) {
+	base := context.Background()
+
+	var key strings.Builder
+
+	if header, ok := ctx.Value(headerKey{}).(http.Header); ok {
+		base = context.WithValue(base, headerKey{}, header)
+		fmt.Fprintf(&key, "|header=%v", header)
+	}
+
+	if timeout, ok := ctx.Value(timeoutKey{}).(time.Duration); ok {
+		base = WithRequestTimeout(base, timeout)
+		fmt.Fprintf(&key, "|timeout=%v", timeout)
+	}
+
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		base = WithPriority(base, priority)
+		fmt.Fprintf(&key, "|priority=%v", priority)
+	}
+
+	return base, key.String()
+}
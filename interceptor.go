@@ -0,0 +1,58 @@
+package rpcclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// Interceptor intercepts the requests of a Client, like a gRPC unary interceptor. It is called with the method,
+// result pointer and params of every request, and next, which sends the request to the next interceptor or the
+// server. An interceptor may change the request before calling next, inspect the result or error after it, or
+// short-circuit the request by not calling next at all and filling in result itself.
+type Interceptor func(ctx context.Context, method string, result any, params []any, next SendFunc) error
+
+// headerKey is the context key for the headers added with WithRequestHeader.
+type headerKey struct{}
+
+// chainInterceptors returns a SendFunc which calls the interceptors around send. The first interceptor is the
+// outermost, so it is called first and sees the final result last.
+func chainInterceptors(send SendFunc, interceptors []Interceptor) SendFunc {
+	for idx := len(interceptors) - 1; idx >= 0; idx-- {
+		interceptor, next := interceptors[idx], send
+		send = func(ctx context.Context, method string, result any, params ...any) error {
+			return interceptor(ctx, method, result, params, next)
+		}
+	}
+
+	return send
+}
+
+// WithInterceptors returns a shallow copy of the client which calls interceptors around its requests, outside of any
+// interceptors the client already has. This also works for clients created with NewWithSendFunc, like MultiClient.
+func (c *Client) WithInterceptors(interceptors ...Interceptor) *Client {
+	c2 := *c
+	c2.send = chainInterceptors(c.send, interceptors)
+
+	return &c2
+}
+
+// WithRequestHeader returns a copy of ctx which adds the header key with value to the HTTP requests sent with it. This
+// allows interceptors to add authentication tokens or signatures to requests.
+func WithRequestHeader(ctx context.Context, key, value string) context.Context {
+	header := http.Header{}
+	if parent, ok := ctx.Value(headerKey{}).(http.Header); ok {
+		header = parent.Clone()
+	}
+
+	header.Add(key, value)
+
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+// setRequestHeaders sets the headers added to ctx with WithRequestHeader on req.
+func setRequestHeaders(ctx context.Context, req *http.Request) {
+	header, _ := ctx.Value(headerKey{}).(http.Header)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+}
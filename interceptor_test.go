@@ -0,0 +1,118 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Interceptors(t *testing.T) {
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, method string, result any, params []any, next SendFunc) error {
+			calls = append(calls, name+" "+method)
+			err := next(ctx, method, result, params...)
+			calls = append(calls, name+" done")
+
+			return err
+		}
+	}
+
+	var sent [][]any
+	client := newTestClient(t, &Config{
+		Interceptors: []Interceptor{
+			record("outer"),
+			record("inner"),
+			// Mock getblockcount, and rewrite getblockhash to always ask for height 1.
+			func(ctx context.Context, method string, result any, params []any, next SendFunc) error {
+				switch method {
+				case "getblockcount":
+					*result.(*int64) = 42
+					return nil
+				case "getblockhash":
+					return next(ctx, method, result, 1)
+				}
+
+				return next(ctx, method, result, params...)
+			},
+		},
+	}, func(req *Request) (any, *RPCError) {
+		sent = append(sent, append([]any{req.Method}, req.Params...))
+		return "00ff", nil
+	})
+
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), count)
+	assert.Empty(t, sent)
+
+	hash, err := client.GetBlockHash(100)
+	require.NoError(t, err)
+	assert.Equal(t, "00ff", hash)
+	assert.Equal(t, [][]any{{"getblockhash", float64(1)}}, sent)
+
+	assert.Equal(t, []string{
+		"outer getblockcount", "inner getblockcount", "inner done", "outer done",
+		"outer getblockhash", "inner getblockhash", "inner done", "outer done",
+	}, calls)
+}
+
+func TestClient_InterceptorTokenRefresh(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"result":7,"error":null}`))
+	}))
+	t.Cleanup(server.Close)
+
+	token := "expired"
+	client, err := New(&Config{
+		Host:       server.URL,
+		DisableTLS: true,
+		Interceptors: []Interceptor{func(ctx context.Context, method string, result any, params []any, next SendFunc) error {
+			err := next(WithRequestHeader(ctx, "Authorization", "Bearer "+token), method, result, params...)
+
+			var httpErr *HTTPError
+			if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized {
+				token = "fresh"
+				err = next(WithRequestHeader(ctx, "Authorization", "Bearer "+token), method, result, params...)
+			}
+
+			return err
+		}},
+	})
+	require.NoError(t, err)
+
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), count)
+	assert.Equal(t, []string{"Bearer expired", "Bearer fresh"}, seen)
+}
+
+func TestClient_WithInterceptors(t *testing.T) {
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{downClient(t)}})
+	require.NoError(t, err)
+
+	client := m.WithInterceptors(func(ctx context.Context, method string, result any, params []any, next SendFunc) error {
+		*result.(*string) = "mocked"
+		return nil
+	})
+
+	hash, err := client.GetBestBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, "mocked", hash)
+
+	_, err = m.GetBestBlockHash()
+	assert.Error(t, err)
+}
@@ -0,0 +1,499 @@
+// Package wire serializes transactions and block headers in the Bitcoin wire format, and computes their hashes.
+package wire
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Hash is a double SHA-256 hash in internal byte order, which is the reverse of the hex strings returned by the RPC
+// server.
+type Hash [32]byte
+
+// DoubleHash returns the double SHA-256 hash of b.
+func DoubleHash(b []byte) Hash {
+	first := sha256.Sum256(b)
+	return sha256.Sum256(first[:])
+}
+
+// HashFromHex parses a hash from its hex string as returned by the RPC server.
+func HashFromHex(s string) (Hash, error) {
+	var hash Hash
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return hash, err
+	}
+
+	if len(b) != len(hash) {
+		return hash, fmt.Errorf("wire: hash has %v bytes instead of %v", len(b), len(hash))
+	}
+
+	for idx := range b {
+		hash[idx] = b[len(b)-1-idx]
+	}
+
+	return hash, nil
+}
+
+// String returns the hex string of the hash as used by the RPC server.
+func (h Hash) String() string {
+	reversed := h
+	for idx := range reversed {
+		reversed[idx] = h[len(h)-1-idx]
+	}
+
+	return hex.EncodeToString(reversed[:])
+}
+
+// Big returns the hash as a number, to compare it to a target.
+func (h Hash) Big() *big.Int {
+	reversed := h
+	for idx := range reversed {
+		reversed[idx] = h[len(h)-1-idx]
+	}
+
+	return new(big.Int).SetBytes(reversed[:])
+}
+
+// CompactToBig returns the target encoded in the compact "bits" form of a block header.
+func CompactToBig(bits uint32) *big.Int {
+	mantissa := int64(bits & 0x007fffff)
+	exponent := uint(bits >> 24)
+
+	var target *big.Int
+	if exponent <= 3 {
+		target = big.NewInt(mantissa >> (8 * (3 - exponent)))
+	} else {
+		target = new(big.Int).Lsh(big.NewInt(mantissa), 8*(exponent-3))
+	}
+
+	if bits&0x00800000 != 0 {
+		target.Neg(target)
+	}
+
+	return target
+}
+
+// AppendVarInt appends n to b as a variable length integer (CompactSize).
+func AppendVarInt(b []byte, n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return append(b, byte(n))
+	case n <= 0xffff:
+		return binary.LittleEndian.AppendUint16(append(b, 0xfd), uint16(n))
+	case n <= 0xffffffff:
+		return binary.LittleEndian.AppendUint32(append(b, 0xfe), uint32(n))
+	}
+
+	return binary.LittleEndian.AppendUint64(append(b, 0xff), n)
+}
+
+// AppendVarBytes appends data to b, prefixed with its length as a variable length integer.
+func AppendVarBytes(b, data []byte) []byte {
+	return append(AppendVarInt(b, uint64(len(data))), data...)
+}
+
+// Script opcodes used by AppendPushData and AppendPushInt.
+const (
+	opFalse     = 0x00
+	opPushData1 = 0x4c
+	opPushData2 = 0x4d
+	op1Negate   = 0x4f
+	op1         = 0x51
+)
+
+// AppendPushData appends a script operation which pushes data to b.
+func AppendPushData(b, data []byte) []byte {
+	switch {
+	case len(data) < opPushData1:
+		b = append(b, byte(len(data)))
+	case len(data) <= 0xff:
+		b = append(b, opPushData1, byte(len(data)))
+	default:
+		b = binary.LittleEndian.AppendUint16(append(b, opPushData2), uint16(len(data)))
+	}
+
+	return append(b, data...)
+}
+
+// AppendPushInt appends a script operation which pushes n to b, encoded like bitcoind's CScript << n. Small numbers use
+// the OP_0 to OP_16 opcodes, which is required for the height in the coinbase (BIP34).
+func AppendPushInt(b []byte, n int64) []byte {
+	switch {
+	case n == 0:
+		return append(b, opFalse)
+	case n == -1:
+		return append(b, op1Negate)
+	case n >= 1 && n <= 16:
+		return append(b, byte(op1+n-1))
+	}
+
+	return AppendPushData(b, ScriptNum(n))
+}
+
+// ScriptNum returns n in the minimal little-endian sign-magnitude encoding of numbers in scripts.
+func ScriptNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+
+	abs := uint64(n)
+	if negative {
+		abs = uint64(-n)
+	}
+
+	var b []byte
+	for abs > 0 {
+		b = append(b, byte(abs))
+		abs >>= 8
+	}
+
+	// The most significant bit is the sign, so add a byte if it is already used by the number.
+	switch {
+	case b[len(b)-1]&0x80 != 0 && negative:
+		b = append(b, 0x80)
+	case b[len(b)-1]&0x80 != 0:
+		b = append(b, 0x00)
+	case negative:
+		b[len(b)-1] |= 0x80
+	}
+
+	return b
+}
+
+// MerkleRoot returns the merkle root of hashes, which are the txids of a block in order. It returns the zero hash if
+// there are no hashes.
+func MerkleRoot(hashes []Hash) Hash {
+	if len(hashes) == 0 {
+		return Hash{}
+	}
+
+	level := append([]Hash(nil), hashes...)
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+
+		next := level[:0]
+		for idx := 0; idx < len(level); idx += 2 {
+			next = append(next, hashPair(level[idx], level[idx+1]))
+		}
+
+		level = next
+	}
+
+	return level[0]
+}
+
+// MerkleBranch returns the hashes needed to compute the merkle root of a block from the hash of its first transaction,
+// the coinbase, where hashes are the txids of the other transactions. See MerkleRootFromBranch.
+func MerkleBranch(hashes []Hash) []Hash {
+	var branch []Hash
+
+	// The first hash of every level is a placeholder for the hash which depends on the coinbase.
+	level := append([]Hash{{}}, hashes...)
+	for len(level) > 1 {
+		branch = append(branch, level[1])
+
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+
+		next := []Hash{{}}
+		for idx := 2; idx < len(level); idx += 2 {
+			next = append(next, hashPair(level[idx], level[idx+1]))
+		}
+
+		level = next
+	}
+
+	return branch
+}
+
+// MerkleRootFromBranch returns the merkle root of a block with a coinbase with the txid coinbase, and the branch
+// returned by MerkleBranch.
+func MerkleRootFromBranch(coinbase Hash, branch []Hash) Hash {
+	root := coinbase
+	for _, hash := range branch {
+		root = hashPair(root, hash)
+	}
+
+	return root
+}
+
+// hashPair returns the double SHA-256 hash of left and right concatenated.
+func hashPair(left, right Hash) Hash {
+	var b [64]byte
+	copy(b[:32], left[:])
+	copy(b[32:], right[:])
+
+	return DoubleHash(b[:])
+}
+
+// HeaderSize is the size of a serialized block header.
+const HeaderSize = 80
+
+// ErrHeaderSize is returned when parsing a block header which isn't HeaderSize bytes.
+var ErrHeaderSize = errors.New("wire: block header must be 80 bytes")
+
+// Header is a block header.
+type Header struct {
+	Version    int32
+	PrevBlock  Hash
+	MerkleRoot Hash
+	Time       uint32
+	Bits       uint32
+	Nonce      uint32
+}
+
+// Bytes returns the serialized header.
+func (h *Header) Bytes() []byte {
+	b := make([]byte, 0, HeaderSize)
+	b = binary.LittleEndian.AppendUint32(b, uint32(h.Version))
+	b = append(b, h.PrevBlock[:]...)
+	b = append(b, h.MerkleRoot[:]...)
+	b = binary.LittleEndian.AppendUint32(b, h.Time)
+	b = binary.LittleEndian.AppendUint32(b, h.Bits)
+
+	return binary.LittleEndian.AppendUint32(b, h.Nonce)
+}
+
+// Hash returns the hash of the header, which is the hash of the block.
+func (h *Header) Hash() Hash {
+	return DoubleHash(h.Bytes())
+}
+
+// ParseHeader parses a serialized block header.
+func ParseHeader(b []byte) (*Header, error) {
+	if len(b) != HeaderSize {
+		return nil, ErrHeaderSize
+	}
+
+	h := &Header{
+		Version: int32(binary.LittleEndian.Uint32(b[0:4])),
+		Time:    binary.LittleEndian.Uint32(b[68:72]),
+		Bits:    binary.LittleEndian.Uint32(b[72:76]),
+		Nonce:   binary.LittleEndian.Uint32(b[76:80]),
+	}
+	copy(h.PrevBlock[:], b[4:36])
+	copy(h.MerkleRoot[:], b[36:68])
+
+	return h, nil
+}
+
+// TxIn is an input of a transaction.
+type TxIn struct {
+	PrevHash  Hash
+	PrevIndex uint32
+	Script    []byte
+	Sequence  uint32
+	Witness   [][]byte
+}
+
+// TxOut is an output of a transaction.
+type TxOut struct {
+	Value  int64
+	Script []byte
+}
+
+// Tx is a transaction.
+type Tx struct {
+	Version  int32
+	Inputs   []*TxIn
+	Outputs  []*TxOut
+	LockTime uint32
+}
+
+// HasWitness reports whether any of the inputs has a witness.
+func (tx *Tx) HasWitness() bool {
+	for _, in := range tx.Inputs {
+		if len(in.Witness) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bytes returns the serialized transaction, with the witnesses if witness is set and the transaction has any.
+func (tx *Tx) Bytes(witness bool) []byte {
+	witness = witness && tx.HasWitness()
+
+	b := binary.LittleEndian.AppendUint32(nil, uint32(tx.Version))
+	if witness {
+		// The marker and flag of the witness serialization (BIP144).
+		b = append(b, 0x00, 0x01)
+	}
+
+	b = AppendVarInt(b, uint64(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		b = append(b, in.PrevHash[:]...)
+		b = binary.LittleEndian.AppendUint32(b, in.PrevIndex)
+		b = AppendVarBytes(b, in.Script)
+		b = binary.LittleEndian.AppendUint32(b, in.Sequence)
+	}
+
+	b = AppendVarInt(b, uint64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		b = binary.LittleEndian.AppendUint64(b, uint64(out.Value))
+		b = AppendVarBytes(b, out.Script)
+	}
+
+	if witness {
+		for _, in := range tx.Inputs {
+			b = AppendVarInt(b, uint64(len(in.Witness)))
+			for _, item := range in.Witness {
+				b = AppendVarBytes(b, item)
+			}
+		}
+	}
+
+	return binary.LittleEndian.AppendUint32(b, tx.LockTime)
+}
+
+// TxID returns the hash of the transaction without witnesses.
+func (tx *Tx) TxID() Hash {
+	return DoubleHash(tx.Bytes(false))
+}
+
+// WTxID returns the hash of the transaction with witnesses (BIP141).
+func (tx *Tx) WTxID() Hash {
+	return DoubleHash(tx.Bytes(true))
+}
+
+// Weight returns the weight of the transaction (BIP141).
+func (tx *Tx) Weight() int {
+	return 3*len(tx.Bytes(false)) + len(tx.Bytes(true))
+}
+
+// ErrMalformedTx is returned when parsing a transaction which isn't validly serialized.
+var ErrMalformedTx = errors.New("wire: malformed transaction")
+
+// ParseTx parses a serialized transaction, with or without witnesses.
+func ParseTx(b []byte) (*Tx, error) {
+	r := &reader{b: b}
+	tx := &Tx{Version: int32(r.uint32())}
+
+	// The marker and flag of the witness serialization, as a transaction without inputs isn't valid.
+	witness := len(r.b) >= 2 && r.b[0] == 0x00 && r.b[1] == 0x01
+	if witness {
+		r.b = r.b[2:]
+	}
+
+	// Every input and output takes at least one byte, which bounds the allocations for malformed counts.
+	tx.Inputs = make([]*TxIn, r.count())
+	for idx := range tx.Inputs {
+		in := &TxIn{}
+		copy(in.PrevHash[:], r.bytes(len(in.PrevHash)))
+		in.PrevIndex = r.uint32()
+		in.Script = r.varBytes()
+		in.Sequence = r.uint32()
+		tx.Inputs[idx] = in
+	}
+
+	tx.Outputs = make([]*TxOut, r.count())
+	for idx := range tx.Outputs {
+		tx.Outputs[idx] = &TxOut{Value: int64(r.uint64()), Script: r.varBytes()}
+	}
+
+	if witness {
+		for _, in := range tx.Inputs {
+			in.Witness = make([][]byte, r.count())
+			for idx := range in.Witness {
+				in.Witness[idx] = r.varBytes()
+			}
+		}
+	}
+
+	tx.LockTime = r.uint32()
+
+	if r.err != nil || len(r.b) != 0 || len(tx.Inputs) == 0 || (witness && !tx.HasWitness()) {
+		return nil, ErrMalformedTx
+	}
+
+	return tx, nil
+}
+
+// reader reads the fields of a serialization. Reading past the end sets err, after which reads return zero values.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.b) {
+		r.err = ErrMalformedTx
+		return nil
+	}
+
+	b := r.b[:n:n]
+	r.b = r.b[n:]
+
+	return b
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+
+	return 0
+}
+
+func (r *reader) varInt() uint64 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+
+	switch b[0] {
+	case 0xfd:
+		if b := r.bytes(2); b != nil {
+			return uint64(binary.LittleEndian.Uint16(b))
+		}
+	case 0xfe:
+		return uint64(r.uint32())
+	case 0xff:
+		return r.uint64()
+	default:
+		return uint64(b[0])
+	}
+
+	return 0
+}
+
+// count reads the number of items which follow, each taking at least one byte.
+func (r *reader) count() int {
+	n := r.varInt()
+	if n > uint64(len(r.b)) {
+		r.err = ErrMalformedTx
+		return 0
+	}
+
+	return int(n)
+}
+
+func (r *reader) varBytes() []byte {
+	n := r.varInt()
+	if n > uint64(len(r.b)) {
+		r.err = ErrMalformedTx
+		return nil
+	}
+
+	return append([]byte(nil), r.bytes(int(n))...)
+}
@@ -0,0 +1,162 @@
+package wire
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+
+	return b
+}
+
+func mustHash(t *testing.T, s string) Hash {
+	hash, err := HashFromHex(s)
+	require.NoError(t, err)
+
+	return hash
+}
+
+// genesisCoinbase returns the coinbase of the mainnet genesis block.
+func genesisCoinbase(t *testing.T) *Tx {
+	return &Tx{
+		Version: 1,
+		Inputs: []*TxIn{{
+			PrevIndex: 0xffffffff,
+			Script:    mustHex(t, "04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73"),
+			Sequence:  0xffffffff,
+		}},
+		Outputs: []*TxOut{{
+			Value:  50e8,
+			Script: mustHex(t, "4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac"),
+		}},
+	}
+}
+
+func TestGenesisBlock(t *testing.T) {
+	coinbase := genesisCoinbase(t)
+	txid := coinbase.TxID()
+	assert.Equal(t, "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", txid.String())
+	assert.Equal(t, txid, coinbase.WTxID())
+	assert.Equal(t, txid, MerkleRoot([]Hash{txid}))
+
+	header := &Header{
+		Version:    1,
+		MerkleRoot: txid,
+		Time:       1231006505,
+		Bits:       0x1d00ffff,
+		Nonce:      2083236893,
+	}
+
+	hash := header.Hash()
+	assert.Equal(t, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f", hash.String())
+	assert.True(t, hash.Big().Cmp(CompactToBig(header.Bits)) <= 0)
+
+	parsed, err := ParseHeader(header.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, header, parsed)
+
+	_, err = ParseHeader(header.Bytes()[1:])
+	assert.ErrorIs(t, err, ErrHeaderSize)
+}
+
+func TestTx_Witness(t *testing.T) {
+	tx := genesisCoinbase(t)
+	tx.Inputs[0].Witness = [][]byte{make([]byte, 32)}
+
+	plain, withWitness := tx.Bytes(false), tx.Bytes(true)
+	assert.Equal(t, []byte{0x00, 0x01}, withWitness[4:6])
+	assert.Len(t, withWitness, len(plain)+2+1+1+32)
+	assert.NotEqual(t, tx.TxID(), tx.WTxID())
+}
+
+func TestParseTx(t *testing.T) {
+	tx := genesisCoinbase(t)
+
+	parsed, err := ParseTx(tx.Bytes(true))
+	require.NoError(t, err)
+	assert.Equal(t, tx, parsed)
+	assert.Equal(t, 4*len(tx.Bytes(false)), tx.Weight())
+
+	tx.Inputs[0].Witness = [][]byte{make([]byte, 32), {0x01}}
+
+	parsed, err = ParseTx(tx.Bytes(true))
+	require.NoError(t, err)
+	assert.Equal(t, tx, parsed)
+	assert.Equal(t, tx.WTxID(), parsed.WTxID())
+	assert.Equal(t, 3*len(tx.Bytes(false))+len(tx.Bytes(true)), tx.Weight())
+
+	for _, b := range [][]byte{nil, tx.Bytes(true)[:50], append(tx.Bytes(false), 0), {1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff}} {
+		_, err = ParseTx(b)
+		assert.ErrorIs(t, err, ErrMalformedTx)
+	}
+}
+
+func TestMerkleBranch(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		hashes := make([]Hash, n)
+		for idx := range hashes {
+			hashes[idx] = DoubleHash([]byte{byte(idx)})
+		}
+
+		expected := MerkleRoot(hashes)
+		assert.Equal(t, expected, MerkleRootFromBranch(hashes[0], MerkleBranch(hashes[1:])), n)
+	}
+
+	// Block 100000 has four transactions.
+	root := MerkleRoot([]Hash{
+		mustHash(t, "8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87"),
+		mustHash(t, "fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4"),
+		mustHash(t, "6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4"),
+		mustHash(t, "e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d"),
+	})
+	assert.Equal(t, "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766", root.String())
+}
+
+func TestScript(t *testing.T) {
+	for n, expected := range map[int64]string{
+		0:      "00",
+		1:      "51",
+		16:     "60",
+		-1:     "4f",
+		17:     "0111",
+		127:    "017f",
+		128:    "028000",
+		255:    "02ff00",
+		256:    "020001",
+		-128:   "028080",
+		500000: "0320a107",
+	} {
+		assert.Equal(t, expected, hex.EncodeToString(AppendPushInt(nil, n)), n)
+	}
+
+	assert.Equal(t, "4c4c", hex.EncodeToString(AppendPushData(nil, make([]byte, 0x4c))[:2]))
+	assert.Equal(t, "4d0001", hex.EncodeToString(AppendPushData(nil, make([]byte, 0x100))[:3]))
+}
+
+func TestAppendVarInt(t *testing.T) {
+	for n, expected := range map[uint64]string{
+		0xfc:        "fc",
+		0xfd:        "fdfd00",
+		0xffff:      "fdffff",
+		0x10000:     "fe00000100",
+		0x100000000: "ff0000000001000000",
+	} {
+		assert.Equal(t, expected, hex.EncodeToString(AppendVarInt(nil, n)), n)
+	}
+}
+
+func TestCompactToBig(t *testing.T) {
+	expected := new(big.Int).Lsh(big.NewInt(0xffff), 208)
+	assert.Equal(t, expected, CompactToBig(0x1d00ffff))
+
+	// The regtest target.
+	expected = new(big.Int).Lsh(big.NewInt(0x7fffff), 232)
+	assert.Equal(t, expected, CompactToBig(0x207fffff))
+}
@@ -0,0 +1,230 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// redacted replaces secret params in logs.
+const redacted = "[REDACTED]"
+
+// maxLoggedParamSize is the length after which string params, like raw transactions, are truncated in logs.
+const maxLoggedParamSize = 128
+
+// allParams marks a method whose params are all secret in secretParams.
+const allParams = -1
+
+// secretParams are the indexes of the params which hold private keys or passphrases, for every method that has them.
+var secretParams = map[string][]int{
+	"createwallet":              {3},
+	"encryptwallet":             {0},
+	"importdescriptors":         {allParams},
+	"importmulti":               {allParams},
+	"importprivkey":             {0},
+	"sethdseed":                 {1},
+	"signmessagewithprivkey":    {0},
+	"signrawtransactionwithkey": {1},
+	"walletpassphrase":          {0},
+	"walletpassphrasechange":    {0, 1},
+}
+
+// CallInfo holds details about the HTTP exchange of a request. See WithCallInfo.
+type CallInfo struct {
+	// Host is the Config.Host of the client that sent the request.
+	Host string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// ResponseSize is the number of bytes of the response body that were read.
+	ResponseSize int64
+	// Retries is the number of times the request was retried. See Config.MaxRetries.
+	Retries int
+}
+
+// callInfoKey is the context key for the CallInfo added with WithCallInfo.
+type callInfoKey struct{}
+
+// WithCallInfo returns a copy of ctx with which the client records details about the HTTP exchange in info. This
+// allows interceptors to log or measure what happened on the wire. For requests a MultiClient or QuorumClient sends to
+// several nodes at once, info holds the details of the node whose answer is returned.
+func WithCallInfo(ctx context.Context, info *CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// CallInfoFromContext returns the CallInfo added to ctx with WithCallInfo, or nil.
+func CallInfoFromContext(ctx context.Context) *CallInfo {
+	info, _ := ctx.Value(callInfoKey{}).(*CallInfo)
+
+	return info
+}
+
+// forkCallInfo returns a copy of ctx with its own CallInfo, if ctx has one, for a request sent concurrently with other
+// requests sharing ctx. The details of the request that answered are copied back with joinCallInfo.
+func forkCallInfo(ctx context.Context) (context.Context, *CallInfo) {
+	if CallInfoFromContext(ctx) == nil {
+		return ctx, nil
+	}
+
+	info := &CallInfo{}
+
+	return WithCallInfo(ctx, info), info
+}
+
+// joinCallInfo records the details of a request forked with forkCallInfo in the CallInfo of ctx.
+func joinCallInfo(ctx context.Context, fork *CallInfo) {
+	info := CallInfoFromContext(ctx)
+	if info == nil || fork == nil {
+		return
+	}
+
+	info.Host, info.StatusCode, info.ResponseSize = fork.Host, fork.StatusCode, fork.ResponseSize
+	info.Retries += fork.Retries
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// NewLoggingInterceptor returns an Interceptor which logs every request to logger, with the method, params, duration,
+// HTTP status, response size, retries and RPC error code. Private keys and passphrases in the params are redacted.
+// Requests are logged at the level in levels for their method, or at slog.LevelDebug, and failed requests at
+// slog.LevelWarn if that is higher.
+func NewLoggingInterceptor(logger *slog.Logger, levels map[string]slog.Level) Interceptor {
+	return func(ctx context.Context, method string, result any, params []any, next SendFunc) error {
+		if !logger.Enabled(ctx, max(logLevel(levels, method), slog.LevelWarn)) {
+			return next(ctx, method, result, params...)
+		}
+
+		info := CallInfoFromContext(ctx)
+		if info == nil {
+			info = &CallInfo{}
+			ctx = WithCallInfo(ctx, info)
+		}
+
+		start := time.Now()
+		err := next(ctx, method, result, params...)
+
+		logCall(ctx, logger, levels, method, params, time.Since(start), info, err)
+
+		return err
+	}
+}
+
+// NewBatchLoggingInterceptor returns a BatchInterceptor which logs every request of a batch to logger, like
+// NewLoggingInterceptor. The duration, HTTP status, response size and retries are those of the whole batch.
+func NewBatchLoggingInterceptor(logger *slog.Logger, levels map[string]slog.Level) BatchInterceptor {
+	return func(ctx context.Context, reqs []*BatchRequest, next BatchSendFunc) error {
+		enabled := false
+		for _, req := range reqs {
+			enabled = enabled || logger.Enabled(ctx, max(logLevel(levels, req.Method), slog.LevelWarn))
+		}
+
+		if !enabled {
+			return next(ctx, reqs)
+		}
+
+		info := CallInfoFromContext(ctx)
+		if info == nil {
+			info = &CallInfo{}
+			ctx = WithCallInfo(ctx, info)
+		}
+
+		start := time.Now()
+		err := next(ctx, reqs)
+		duration := time.Since(start)
+
+		for _, req := range reqs {
+			reqErr := err
+			if reqErr == nil {
+				reqErr = req.Err
+			}
+
+			logCall(ctx, logger, levels, req.Method, req.Params, duration, info, reqErr)
+		}
+
+		return err
+	}
+}
+
+// logLevel returns the level in levels at which requests for method are logged.
+func logLevel(levels map[string]slog.Level, method string) slog.Level {
+	if level, ok := levels[method]; ok {
+		return level
+	}
+
+	return slog.LevelDebug
+}
+
+// logCall logs a request for method which took duration and failed with err, if it isn't nil.
+func logCall(ctx context.Context, logger *slog.Logger, levels map[string]slog.Level, method string, params []any,
+	duration time.Duration, info *CallInfo, err error,
+) {
+	level := logLevel(levels, method)
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.Any("params", redactParams(method, params)),
+		slog.Duration("duration", duration),
+		slog.String("host", info.Host),
+		slog.Int("status", info.StatusCode),
+		slog.Int64("response_size", info.ResponseSize),
+		slog.Int("retries", info.Retries),
+	}
+
+	if err != nil {
+		level = max(level, slog.LevelWarn)
+		attrs = append(attrs, slog.String("error", err.Error()))
+
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			attrs = append(attrs, slog.Int("rpc_code", rpcErr.Code))
+		}
+	}
+
+	logger.LogAttrs(ctx, level, "rpc call", attrs...)
+}
+
+// redactParams returns a copy of params which is safe to log. Secret params are replaced with redacted, and long
+// strings are truncated.
+func redactParams(method string, params []any) []any {
+	secrets := secretParams[method]
+
+	safe := make([]any, len(params))
+	for idx, param := range params {
+		if isSecretParam(secrets, idx) {
+			safe[idx] = redacted
+			continue
+		}
+
+		if s, ok := param.(string); ok && len(s) > maxLoggedParamSize {
+			param = s[:maxLoggedParamSize] + "..."
+		}
+
+		safe[idx] = param
+	}
+
+	return safe
+}
+
+// isSecretParam reports whether the param at idx is one of secrets.
+func isSecretParam(secrets []int, idx int) bool {
+	for _, secret := range secrets {
+		if secret == allParams || secret == idx {
+			return true
+		}
+	}
+
+	return false
+}
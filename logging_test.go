@@ -0,0 +1,144 @@
+package rpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logLines decodes the JSON log lines in buf.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+
+	return lines
+}
+
+func TestClient_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	client := newTestClient(t, &Config{
+		Logger:    slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})),
+		LogLevels: map[string]slog.Level{"signrawtransactionwithkey": slog.LevelInfo},
+	}, func(req *Request) (any, *RPCError) {
+		switch req.Method {
+		case "signrawtransactionwithkey":
+			return map[string]any{"hex": "00", "complete": true}, nil
+		case "getblockhash":
+			return "00ff", nil
+		}
+
+		return nil, &RPCError{Code: -5, Message: "Block not found"}
+	})
+
+	_, err := client.SignRawTransactionWithKey("0200", []string{"cSecretKey"}, nil, "")
+	require.NoError(t, err)
+
+	_, err = client.GetBlockHash(1)
+	require.NoError(t, err)
+
+	_, err = client.GetBlockVerbose("00aa")
+	require.Error(t, err)
+
+	assert.NotContains(t, buf.String(), "cSecretKey")
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2, "getblockhash is logged at debug level")
+
+	assert.Equal(t, "INFO", lines[0]["level"])
+	assert.Equal(t, "signrawtransactionwithkey", lines[0]["method"])
+	assert.Equal(t, []any{"0200", redacted}, lines[0]["params"])
+	assert.Equal(t, float64(200), lines[0]["status"])
+	assert.NotZero(t, lines[0]["response_size"])
+	assert.Contains(t, lines[0], "duration")
+	assert.Equal(t, client.config.Host, lines[0]["host"])
+
+	assert.Equal(t, "WARN", lines[1]["level"])
+	assert.Equal(t, "getblock", lines[1]["method"])
+	assert.Equal(t, float64(-5), lines[1]["rpc_code"])
+}
+
+func TestRedactParams(t *testing.T) {
+	assert.Equal(t, []any{redacted, redacted}, redactParams("importdescriptors", []any{"a", "b"}))
+	assert.Equal(t, []any{redacted, redacted, 60}, redactParams("walletpassphrasechange", []any{"old", "new", 60}))
+	assert.Equal(t, []any{strings.Repeat("a", maxLoggedParamSize) + "..."}, redactParams("sendrawtransaction", []any{strings.Repeat("a", 200)}))
+}
+
+// callInfoInterceptor returns an Interceptor which adds a CallInfo to every request, and stores it in info.
+func callInfoInterceptor(info **CallInfo) Interceptor {
+	return func(ctx context.Context, method string, result any, params []any, next SendFunc) error {
+		*info = &CallInfo{}
+		return next(WithCallInfo(ctx, *info), method, result, params...)
+	}
+}
+
+func TestCallInfo_FanOut(t *testing.T) {
+	a, b := quorumNode(t, "aa", 1), quorumNode(t, "aa", 1)
+
+	q, err := NewQuorumClient(&QuorumConfig{Nodes: []*Client{a, b, quorumNode(t, "bb", 1)}, Required: 2})
+	require.NoError(t, err)
+
+	// The nodes are requested concurrently, and the CallInfo holds the details of a node which agreed.
+	var info *CallInfo
+	hash, err := q.WithInterceptors(callInfoInterceptor(&info)).GetBlockHash(1)
+	require.NoError(t, err)
+	assert.Equal(t, "aa", hash)
+	assert.Contains(t, []string{a.config.Host, b.config.Host}, info.Host)
+	assert.Equal(t, 200, info.StatusCode)
+	assert.Positive(t, info.ResponseSize)
+
+	node := &testNode{name: "a"}
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{downClient(t), node.client(t), downClient(t)}})
+	require.NoError(t, err)
+
+	_, err = m.WithInterceptors(callInfoInterceptor(&info)).SendRawTransaction("00", nil)
+	require.NoError(t, err)
+	assert.Equal(t, m.config.Nodes[1].config.Host, info.Host)
+	assert.Equal(t, 200, info.StatusCode)
+}
+
+func TestClient_LoggerBatch(t *testing.T) {
+	var buf bytes.Buffer
+	client := newBatchServer(t, &Config{
+		Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}, func(reqs []*batchRequest) any {
+		return []map[string]any{
+			{"id": reqs[0].ID, "result": map[string]any{"hex": "00", "complete": true}},
+			{"id": reqs[1].ID, "error": &RPCError{Code: -5, Message: "Block not found"}},
+		}
+	})
+
+	reqs := []*BatchRequest{
+		{Method: "signrawtransactionwithkey", Params: []any{"0200", []string{"cSecretKey"}}},
+		{Method: "getblock", Params: []any{"00aa"}},
+	}
+	require.NoError(t, client.SendBatch(context.Background(), reqs))
+
+	assert.NotContains(t, buf.String(), "cSecretKey")
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2)
+
+	assert.Equal(t, "DEBUG", lines[0]["level"])
+	assert.Equal(t, "signrawtransactionwithkey", lines[0]["method"])
+	assert.Equal(t, []any{"0200", redacted}, lines[0]["params"])
+	assert.Equal(t, float64(200), lines[0]["status"])
+	assert.Equal(t, client.config.Host, lines[0]["host"])
+
+	assert.Equal(t, "WARN", lines[1]["level"])
+	assert.Equal(t, "getblock", lines[1]["method"])
+	assert.Equal(t, float64(-5), lines[1]["rpc_code"])
+}
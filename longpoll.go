@@ -0,0 +1,79 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// defaultLongPollTimeout is the default of Config.LongPollTimeout.
+const defaultLongPollTimeout = 10 * time.Minute
+
+// SubscribeBlockTemplates gets a block template for req, and calls fn with it and with every newer template, until ctx
+// is done or fn returns an error. Newer templates are waited for with long-polls (BIP22), which the node answers when
+// a new block arrives, or when the mempool has changed and a minute has passed. If req is nil, the segwit rule is
+// requested.
+//
+// Long-polls which are still open after Config.LongPollTimeout or Config.ResponseHeaderTimeout are sent again. When
+// the node can't be reached or is starting up, the current template is requested again once it is back, with a backoff
+// like Config.RetryBackoff in between, and only passed to fn if it differs from the last one. Other errors are
+// returned.
+func (c *Client) SubscribeBlockTemplates(ctx context.Context, req *types.BlockTemplateRequest, fn func(*types.BlockTemplate) error) error {
+	pollReq := types.BlockTemplateRequest{Rules: []string{"segwit"}}
+	if req != nil {
+		pollReq = *req
+	}
+
+	timeout := c.config.LongPollTimeout
+	if timeout <= 0 {
+		timeout = defaultLongPollTimeout
+	}
+
+	backoff := c.retryBackoff()
+
+	// lastID is the longpollid of the last template passed to fn, which identifies the tip and mempool it was built on.
+	var lastID string
+
+	for {
+		var tmpl *types.BlockTemplate
+
+		err := c.SendReqContext(WithRequestTimeout(ctx, timeout), "getblocktemplate", &tmpl, &pollReq)
+		switch {
+		case err == nil && tmpl != nil:
+			if tmpl.LongpollId == "" || tmpl.LongpollId != lastID {
+				if err := fn(tmpl); err != nil {
+					return err
+				}
+			}
+
+			lastID = tmpl.LongpollId
+			pollReq.LongPollID = tmpl.LongpollId
+			backoff = c.retryBackoff()
+
+			continue
+		case err == nil:
+			return errors.New("rpcclient: getblocktemplate returned no template")
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, context.DeadlineExceeded):
+			// The long-poll timed out without a newer template, so it is sent again. This includes the response header
+			// timeout of the transport.
+			continue
+		case !isRetryable(err):
+			return err
+		}
+
+		// Templates may have been missed while the node was unreachable, so the current one is requested.
+		pollReq.LongPollID = ""
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
@@ -0,0 +1,176 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SubscribeBlockTemplates(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []string
+		polls    = map[string]int{}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []*types.BlockTemplateRequest `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		longPollID := req.Params[0].LongPollID
+
+		mu.Lock()
+		received = append(received, longPollID)
+		polls[longPollID]++
+		n := polls[longPollID]
+		mu.Unlock()
+
+		var next string
+		switch {
+		case longPollID == "":
+			next = "1"
+			if n > 1 {
+				next = "3"
+			}
+		case longPollID == "1" && n == 1:
+			// Hold the first long-poll open until the client gives up on it.
+			<-r.Context().Done()
+			return
+		case longPollID == "1":
+			next = "2"
+		case longPollID == "2":
+			// The node goes away, so the client requests the current template once it is back.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		default:
+			<-r.Context().Done()
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"result": &types.BlockTemplate{LongpollId: next, Rules: req.Params[0].Rules},
+			"error":  nil,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(&Config{
+		Host:            server.URL,
+		DisableTLS:      true,
+		Timeout:         time.Millisecond,
+		LongPollTimeout: 50 * time.Millisecond,
+		RetryBackoff:    time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	errStop := errors.New("stop")
+
+	var templates []string
+	err = client.SubscribeBlockTemplates(context.Background(), nil, func(tmpl *types.BlockTemplate) error {
+		assert.Equal(t, []string{"segwit"}, tmpl.Rules)
+		templates = append(templates, tmpl.LongpollId)
+
+		if len(templates) == 3 {
+			return errStop
+		}
+
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, []string{"1", "2", "3"}, templates)
+
+	mu.Lock()
+	assert.Equal(t, []string{"", "1", "1", "2", ""}, received)
+	mu.Unlock()
+
+	// A subscription ends when its context is done, even while a long-poll is open.
+	done := make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		done <- client.SubscribeBlockTemplates(ctx, &types.BlockTemplateRequest{LongPollID: "3"}, func(*types.BlockTemplate) error {
+			return nil
+		})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestClient_SubscribeBlockTemplatesUnchanged(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		polls int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []*types.BlockTemplateRequest `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		next := "1"
+		if req.Params[0].LongPollID == "1" {
+			mu.Lock()
+			polls++
+			n := polls
+			mu.Unlock()
+
+			switch n {
+			case 1:
+				// Nothing changes before the response header timeout.
+				<-r.Context().Done()
+				return
+			case 2:
+				// The connection drops, so the current template is requested again, which hasn't changed.
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				conn.Close()
+
+				return
+			}
+
+			next = "2"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": &types.BlockTemplate{LongpollId: next}, "error": nil})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(&Config{
+		Host:                  server.URL,
+		DisableTLS:            true,
+		ResponseHeaderTimeout: 20 * time.Millisecond,
+		RetryBackoff:          time.Millisecond,
+		DisableAutoReconnect:  true,
+	})
+	require.NoError(t, err)
+
+	errStop := errors.New("stop")
+
+	var templates []string
+	err = client.SubscribeBlockTemplates(context.Background(), nil, func(tmpl *types.BlockTemplate) error {
+		templates = append(templates, tmpl.LongpollId)
+		if tmpl.LongpollId == "2" {
+			return errStop
+		}
+
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, []string{"1", "2"}, templates)
+}
@@ -0,0 +1,74 @@
+package rpcclient
+
+import "strings"
+
+// broadcastMethods are the methods which submit data to the network. Clients over several nodes send them to every
+// node, so the data propagates even if some of the nodes are down.
+var broadcastMethods = map[string]bool{
+	"sendrawtransaction":    true,
+	"submitblock":           true,
+	"submitheader":          true,
+	"submitpackage":         true,
+	"prioritisetransaction": true,
+	"preciousblock":         true,
+}
+
+// nodeStateMethods are the methods which change the state of the node they are sent to. They are sent to a single node
+// and never retried, as a request which failed on the way back may still have been executed.
+var nodeStateMethods = map[string]bool{
+	"generateblock":        true,
+	"generatetoaddress":    true,
+	"generatetodescriptor": true,
+	"getnewaddress":        true,
+	"getrawchangeaddress":  true,
+	"pruneblockchain":      true,
+	"savemempool":          true,
+	"stop":                 true,
+}
+
+// isMutating reports whether method changes the state of the node or the network, so its result must not be shared or
+// cached, and the request must not be retried.
+func isMutating(method string) bool {
+	return broadcastMethods[method] || nodeStateMethods[method]
+}
+
+// readOnlyPrefixes are the prefixes of the methods which only read state.
+var readOnlyPrefixes = []string{"get", "list", "decode", "estimate", "validate", "derive", "testmempoolaccept", "help", "uptime"}
+
+// isReadOnly reports whether method only reads state. Unknown methods are assumed to change state.
+func isReadOnly(method string) bool {
+	if isMutating(method) {
+		return false
+	}
+
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// highPriorityMethods are the methods sent with PriorityHigh by default. They are latency-sensitive, and cheap for the
+// node to execute.
+var highPriorityMethods = map[string]bool{
+	"sendrawtransaction": true,
+	"submitblock":        true,
+	"submitpackage":      true,
+	"estimatesmartfee":   true,
+	"estimaterawfee":     true,
+}
+
+// methodCosts are the default costs of the methods which are much heavier for the node than a simple lookup. Other
+// methods cost 1. See Config.MethodCosts.
+var methodCosts = map[string]float64{
+	"scantxoutset":          50,
+	"gettxoutsetinfo":       50,
+	"getblockstats":         10,
+	"getblock":              2,
+	"getrawmempool":         2,
+	"getchaintxstats":       2,
+	"getmempoolancestors":   2,
+	"getmempooldescendants": 2,
+}
@@ -0,0 +1,154 @@
+// Package metrics instruments rpcclient clients with Prometheus metrics. It is a separate module, so users of
+// rpcclient who don't import it don't depend on the Prometheus client.
+//
+//	collector := metrics.NewCollector("bitcoind")
+//	prometheus.MustRegister(collector)
+//
+//	client, err := rpcclient.New(&rpcclient.Config{
+//		Host:         "http://127.0.0.1:8332",
+//		Interceptors: []rpcclient.Interceptor{collector.Interceptor()},
+//	})
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of a request, used as the outcome label.
+const (
+	// OutcomeSuccess is a request that succeeded.
+	OutcomeSuccess = "success"
+	// OutcomeRPCError is a request for which the node returned an error.
+	OutcomeRPCError = "rpc_error"
+	// OutcomeHTTPError is a request for which the server responded with something other than JSON.
+	OutcomeHTTPError = "http_error"
+	// OutcomeCanceled is a request that was cancelled or timed out.
+	OutcomeCanceled = "canceled"
+	// OutcomeError is a request that failed otherwise, eg because the node could not be reached.
+	OutcomeError = "error"
+)
+
+// Enforce Collector has to be implementation of prometheus.Collector.
+var _ prometheus.Collector = &Collector{}
+
+// Collector collects metrics of the requests sent through its Interceptor.
+type Collector struct {
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	inFlight     *prometheus.GaugeVec
+	retries      *prometheus.CounterVec
+	responseSize *prometheus.HistogramVec
+}
+
+// NewCollector creates a new *Collector. The names of the metrics are prefixed with namespace, if it is not empty.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "requests_total",
+			Help:      "Number of RPC requests by method and outcome.",
+		}, []string{"method", "outcome"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of RPC requests, including retries.",
+			Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"method"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "requests_in_flight",
+			Help:      "Number of RPC requests in flight.",
+		}, []string{"method"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "retries_total",
+			Help:      "Number of retried RPC requests.",
+		}, []string{"method"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "response_size_bytes",
+			Help:      "Size of RPC response bodies.",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+		}, []string{"method"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.duration.Describe(ch)
+	c.inFlight.Describe(ch)
+	c.retries.Describe(ch)
+	c.responseSize.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.duration.Collect(ch)
+	c.inFlight.Collect(ch)
+	c.retries.Collect(ch)
+	c.responseSize.Collect(ch)
+}
+
+// Interceptor returns an rpcclient.Interceptor which records the metrics of every request.
+func (c *Collector) Interceptor() rpcclient.Interceptor {
+	return func(ctx context.Context, method string, result any, params []any, next rpcclient.SendFunc) error {
+		inFlight := c.inFlight.WithLabelValues(method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		info := rpcclient.CallInfoFromContext(ctx)
+		if info == nil {
+			info = &rpcclient.CallInfo{}
+			ctx = rpcclient.WithCallInfo(ctx, info)
+		}
+
+		start := time.Now()
+		err := next(ctx, method, result, params...)
+
+		c.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		c.requests.WithLabelValues(method, outcome(err)).Inc()
+
+		if info.Retries > 0 {
+			c.retries.WithLabelValues(method).Add(float64(info.Retries))
+		}
+
+		if info.StatusCode != 0 {
+			c.responseSize.WithLabelValues(method).Observe(float64(info.ResponseSize))
+		}
+
+		return err
+	}
+}
+
+// outcome returns the outcome label for err.
+func outcome(err error) string {
+	var (
+		rpcErr  *rpcclient.RPCError
+		httpErr *rpcclient.HTTPError
+	)
+
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.As(err, &rpcErr):
+		return OutcomeRPCError
+	case errors.As(err, &httpErr):
+		return OutcomeHTTPError
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return OutcomeCanceled
+	}
+
+	return OutcomeError
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcclient.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case "getblockcount":
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(`{"result":null,"error":{"code":-28,"message":"Loading"}}`))
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"result":10,"error":null}`))
+		default:
+			_, _ = w.Write([]byte(`{"result":null,"error":{"code":-5,"message":"Block not found"}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	collector := NewCollector("test")
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	client, err := rpcclient.New(&rpcclient.Config{
+		Host:         server.URL,
+		DisableTLS:   true,
+		MaxRetries:   1,
+		RetryBackoff: time.Millisecond,
+		Interceptors: []rpcclient.Interceptor{collector.Interceptor()},
+	})
+	require.NoError(t, err)
+
+	count, err := client.GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), count)
+
+	_, err = client.GetBlockVerbose("00ff")
+	assert.Error(t, err)
+
+	expected := `
+# HELP test_rpc_requests_total Number of RPC requests by method and outcome.
+# TYPE test_rpc_requests_total counter
+test_rpc_requests_total{method="getblock",outcome="rpc_error"} 1
+test_rpc_requests_total{method="getblockcount",outcome="success"} 1
+# HELP test_rpc_retries_total Number of retried RPC requests.
+# TYPE test_rpc_retries_total counter
+test_rpc_retries_total{method="getblockcount"} 1
+# HELP test_rpc_requests_in_flight Number of RPC requests in flight.
+# TYPE test_rpc_requests_in_flight gauge
+test_rpc_requests_in_flight{method="getblock"} 0
+test_rpc_requests_in_flight{method="getblockcount"} 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"test_rpc_requests_total", "test_rpc_retries_total", "test_rpc_requests_in_flight"))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "test_rpc_request_duration_seconds"))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "test_rpc_response_size_bytes"))
+}
@@ -1,6 +1,8 @@
 package rpcclient
 
 import (
+	"fmt"
+
 	"github.com/omarhachach/rpcclient-core/types"
 )
 
@@ -24,10 +26,10 @@ func (c *Client) GetMiningInfo() (*types.MiningInfo, error) {
 }
 
 // GetNetworkHashPS returns the estimated network hashes per second based on the last nblocks.
-func (c *Client) GetNetworkHashPS(nblocks, height int) (int, error) {
-	var hsps int
+func (c *Client) GetNetworkHashPS(nblocks, height int) (float64, error) {
+	var hsps float64
 
-	return hsps, c.SendReq("getnetworkhashps", nblocks, height)
+	return hsps, c.SendReq("getnetworkhashps", &hsps, nblocks, height)
 }
 
 // PrioritiseTransaction accepts the transaction into mined blocks at a higher (or lower) priority.
@@ -37,12 +39,51 @@ func (c *Client) PrioritiseTransaction(txid string, feeDelta int) (bool, error)
 	return res, c.SendReq("prioritisetransaction", &res, txid, feeDelta)
 }
 
-// SubmitBlock submits a new block to the network.
+// SubmitBlock submits a new block to the network. If the node doesn't accept the block as its new tip, a
+// *SubmitBlockError with the reason is returned.
 func (c *Client) SubmitBlock(hexdata string) error {
-	return c.SendReq("submitblock", new(string), hexdata)
+	var reason *string
+
+	err := c.SendReq("submitblock", &reason, hexdata)
+	if err != nil {
+		return err
+	}
+
+	if reason != nil {
+		return &SubmitBlockError{Reason: *reason}
+	}
+
+	return nil
 }
 
 // SubmitHeader decodes the hexdata as a header and submits it as a candidate chain tip if valid.
 func (c *Client) SubmitHeader(hexdata string) error {
-	return c.SendReq("submitblock", new(string), hexdata)
+	return c.SendReq("submitheader", nil, hexdata)
+}
+
+// Reasons for which submitblock doesn't accept a block, as returned in SubmitBlockError. Other reasons are the
+// reject reasons of the block's validation, like "bad-txnmrklroot".
+const (
+	// SubmitBlockDuplicate means the block was already known and valid.
+	SubmitBlockDuplicate = "duplicate"
+	// SubmitBlockDuplicateInvalid means the block was already known and invalid.
+	SubmitBlockDuplicateInvalid = "duplicate-invalid"
+	// SubmitBlockDuplicateInconclusive means the block was already known, but not on the main chain.
+	SubmitBlockDuplicateInconclusive = "duplicate-inconclusive"
+	// SubmitBlockInconclusive means the block is valid, but did not become the tip, eg because another block at the
+	// same height was received first.
+	SubmitBlockInconclusive = "inconclusive"
+	// SubmitBlockHighHash means the block hash does not meet the target.
+	SubmitBlockHighHash = "high-hash"
+)
+
+// SubmitBlockError is returned by SubmitBlock when the node did not accept the block as its new tip. Reason is one of
+// the SubmitBlock constants, or a reject reason of the block's validation.
+type SubmitBlockError struct {
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *SubmitBlockError) Error() string {
+	return fmt.Sprintf("rpcclient: block not accepted: %v", e.Reason)
 }
@@ -0,0 +1,381 @@
+// Package mining builds candidate blocks from the templates of getblocktemplate, for solo mining against a node.
+//
+//	tmpl, err := client.GetBlockTemplate(&types.BlockTemplateRequest{Rules: []string{"segwit"}})
+//	work, err := mining.NewWork(tmpl, &mining.Options{PayoutScript: script})
+//	block, err := work.Solve(ctx)
+//	err = client.SubmitBlock(block.Hex())
+//
+// It also projects the next blocks from the mempool with ProjectBlocks, to tell whether a transaction is likely to be
+// in the next block.
+package mining
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/internal/wire"
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// DefaultExtraNonceSize is the size of the extranonce in the coinbase, if Options.ExtraNonceSize is not set.
+const DefaultExtraNonceSize = 8
+
+// maxCoinbaseScriptSize is the maximum size of the script of the coinbase input.
+const maxCoinbaseScriptSize = 100
+
+// solveCheckInterval is the number of nonces tried by Solve between checks of its context.
+const solveCheckInterval = 1 << 16
+
+var (
+	// ErrNoPayoutScript is returned when Options.PayoutScript is empty.
+	ErrNoPayoutScript = errors.New("mining: no payout script")
+
+	// ErrExtraNonceSize is returned when an extranonce doesn't have the size of the extranonce of the work.
+	ErrExtraNonceSize = errors.New("mining: extranonce has the wrong size")
+
+	// ErrExhausted is returned by Solve when no block meets the target with any nonce and extranonce.
+	ErrExhausted = errors.New("mining: nonce space exhausted")
+)
+
+// Options are the options for building work from a block template.
+type Options struct {
+	// PayoutScript is the scriptPubKey the coinbase pays the block reward and fees to. It is the scriptPubKey returned
+	// by getaddressinfo for an address.
+	PayoutScript []byte
+
+	// ExtraNonceSize is the size of the extranonce in the script of the coinbase, which is varied to get a new merkle
+	// root when all nonces of the header have been tried. Defaults to DefaultExtraNonceSize.
+	ExtraNonceSize int
+
+	// Tag is added to the script of the coinbase after the extranonce, eg to identify the miner.
+	Tag []byte
+}
+
+// Work is a block template prepared for mining. It holds everything in the block except for the extranonce in the
+// coinbase, and the time and nonce in the header.
+type Work struct {
+	// Template is the block template the work was built from.
+	Template *types.BlockTemplate
+
+	// Target is the number the hash of the block must not exceed.
+	Target *big.Int
+
+	// ExtraNonceSize is the size of the extranonce.
+	ExtraNonceSize int
+
+	header   wire.Header
+	coinbase *wire.Tx
+	txs      [][]byte
+
+	// prefix and suffix are the serialization of the coinbase without witness, before and after the extranonce.
+	prefix, suffix []byte
+
+	// scriptPrefix and scriptSuffix are the script of the coinbase input, before and after the extranonce.
+	scriptPrefix, scriptSuffix []byte
+
+	branch []wire.Hash
+}
+
+// NewWork builds work from tmpl, with a coinbase which pays to opts.PayoutScript. The template must have been
+// requested with the segwit rule if it has transactions with witnesses.
+func NewWork(tmpl *types.BlockTemplate, opts *Options) (*Work, error) {
+	if len(opts.PayoutScript) == 0 {
+		return nil, ErrNoPayoutScript
+	}
+
+	w := &Work{
+		Template:       tmpl,
+		ExtraNonceSize: opts.ExtraNonceSize,
+	}
+
+	if w.ExtraNonceSize <= 0 {
+		w.ExtraNonceSize = DefaultExtraNonceSize
+	}
+
+	if err := w.parseHeader(tmpl); err != nil {
+		return nil, err
+	}
+
+	hashes := make([]wire.Hash, len(tmpl.Transactions))
+	w.txs = make([][]byte, len(tmpl.Transactions))
+
+	for idx, tx := range tmpl.Transactions {
+		data, err := hex.DecodeString(tx.Data)
+		if err != nil {
+			return nil, fmt.Errorf("mining: transaction %v: %w", idx, err)
+		}
+
+		hashes[idx], err = wire.HashFromHex(tx.Txid)
+		if err != nil {
+			return nil, fmt.Errorf("mining: transaction %v: %w", idx, err)
+		}
+
+		w.txs[idx] = data
+	}
+
+	w.branch = wire.MerkleBranch(hashes)
+
+	if err := w.buildCoinbase(tmpl, opts); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// parseHeader sets the fields of the header and the target from tmpl.
+func (w *Work) parseHeader(tmpl *types.BlockTemplate) error {
+	prevBlock, err := wire.HashFromHex(tmpl.PreviousBlockhash)
+	if err != nil {
+		return fmt.Errorf("mining: previous block hash: %w", err)
+	}
+
+	bits, err := strconv.ParseUint(tmpl.Bits, 16, 32)
+	if err != nil {
+		return fmt.Errorf("mining: bits: %w", err)
+	}
+
+	w.header = wire.Header{
+		Version:   int32(tmpl.Version),
+		PrevBlock: prevBlock,
+		Time:      uint32(tmpl.CurTime),
+		Bits:      uint32(bits),
+	}
+
+	w.Target = wire.CompactToBig(w.header.Bits)
+	if tmpl.Target != "" {
+		target, ok := new(big.Int).SetString(tmpl.Target, 16)
+		if !ok {
+			return fmt.Errorf("mining: invalid target %q", tmpl.Target)
+		}
+
+		w.Target = target
+	}
+
+	return nil
+}
+
+// buildCoinbase builds the coinbase, with the height (BIP34), the extranonce and the tag in the script of its input,
+// an output which pays the coinbase value to the payout script, and the witness commitment (BIP141) if the template
+// has one.
+func (w *Work) buildCoinbase(tmpl *types.BlockTemplate, opts *Options) error {
+	w.scriptPrefix = wire.AppendPushInt(nil, int64(tmpl.Height))
+
+	// The extranonce is pushed with a single byte for its size, so it is at a fixed offset in the coinbase.
+	if w.ExtraNonceSize >= 0x4c {
+		return fmt.Errorf("mining: extranonce size %v too large", w.ExtraNonceSize)
+	}
+
+	w.scriptPrefix = append(w.scriptPrefix, byte(w.ExtraNonceSize))
+
+	if len(opts.Tag) > 0 {
+		w.scriptSuffix = wire.AppendPushData(nil, opts.Tag)
+	}
+
+	if size := len(w.scriptPrefix) + w.ExtraNonceSize + len(w.scriptSuffix); size > maxCoinbaseScriptSize {
+		return fmt.Errorf("mining: coinbase script of %v bytes exceeds %v bytes", size, maxCoinbaseScriptSize)
+	}
+
+	w.coinbase = &wire.Tx{
+		Version: 2,
+		Inputs: []*wire.TxIn{{
+			PrevIndex: math.MaxUint32,
+			Sequence:  math.MaxUint32,
+		}},
+		Outputs: []*wire.TxOut{{
+			Value:  int64(tmpl.CoinbaseValue),
+			Script: opts.PayoutScript,
+		}},
+	}
+
+	if tmpl.DefaultWitnessCommitment != "" {
+		commitment, err := hex.DecodeString(tmpl.DefaultWitnessCommitment)
+		if err != nil {
+			return fmt.Errorf("mining: witness commitment: %w", err)
+		}
+
+		w.coinbase.Outputs = append(w.coinbase.Outputs, &wire.TxOut{Script: commitment})
+
+		// The witness reserved value, which the commitment of the template is computed with.
+		w.coinbase.Inputs[0].Witness = [][]byte{make([]byte, 32)}
+	}
+
+	w.coinbase.Inputs[0].Script = w.coinbaseScript(make([]byte, w.ExtraNonceSize))
+	serialized := w.coinbase.Bytes(false)
+
+	// The script follows the version, the input count, the outpoint and the size of the script.
+	offset := 4 + 1 + 36 + len(wire.AppendVarInt(nil, uint64(len(w.coinbase.Inputs[0].Script)))) + len(w.scriptPrefix)
+	w.prefix = serialized[:offset]
+	w.suffix = serialized[offset+w.ExtraNonceSize:]
+
+	return nil
+}
+
+// coinbaseScript returns the script of the coinbase input with extraNonce.
+func (w *Work) coinbaseScript(extraNonce []byte) []byte {
+	script := make([]byte, 0, len(w.scriptPrefix)+len(extraNonce)+len(w.scriptSuffix))
+	script = append(script, w.scriptPrefix...)
+	script = append(script, extraNonce...)
+
+	return append(script, w.scriptSuffix...)
+}
+
+// CoinbaseParts returns the serialization of the coinbase without witness, split around the extranonce. This is the
+// coinb1 and coinb2 of Stratum.
+func (w *Work) CoinbaseParts() (prefix, suffix []byte) {
+	return append([]byte(nil), w.prefix...), append([]byte(nil), w.suffix...)
+}
+
+// MerkleBranch returns the hashes to combine the txid of the coinbase with to compute the merkle root, in internal byte
+// order. This is the merkle branch of Stratum.
+func (w *Work) MerkleBranch() [][]byte {
+	branch := make([][]byte, len(w.branch))
+	for idx, hash := range w.branch {
+		branch[idx] = append([]byte(nil), hash[:]...)
+	}
+
+	return branch
+}
+
+// Block builds the block with extraNonce in the coinbase, and ntime and nonce in the header.
+func (w *Work) Block(extraNonce []byte, ntime, nonce uint32) (*Block, error) {
+	if len(extraNonce) != w.ExtraNonceSize {
+		return nil, ErrExtraNonceSize
+	}
+
+	coinbase := *w.coinbase
+	input := *coinbase.Inputs[0]
+	input.Script = w.coinbaseScript(extraNonce)
+	coinbase.Inputs = []*wire.TxIn{&input}
+
+	header := w.header
+	header.MerkleRoot = wire.MerkleRootFromBranch(coinbase.TxID(), w.branch)
+	header.Time = ntime
+	header.Nonce = nonce
+
+	return &Block{
+		header:   header,
+		coinbase: &coinbase,
+		txs:      w.txs,
+		target:   w.Target,
+	}, nil
+}
+
+// Solve searches for a block which meets the target, trying every nonce for every extranonce in turn, starting at the
+// time of the template. This is only practical for networks with a low difficulty, like regtest.
+func (w *Work) Solve(ctx context.Context) (*Block, error) {
+	extraNonce := make([]byte, w.ExtraNonceSize)
+
+	for {
+		block, err := w.Block(extraNonce, w.header.Time, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for nonce := uint64(0); nonce <= math.MaxUint32; nonce++ {
+			if nonce%solveCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+			}
+
+			block.header.Nonce = uint32(nonce)
+			if block.CheckProofOfWork() {
+				return block, nil
+			}
+		}
+
+		if !increment(extraNonce) {
+			return nil, ErrExhausted
+		}
+	}
+}
+
+// increment increments the little-endian number in b, and reports whether it didn't overflow.
+func increment(b []byte) bool {
+	for idx := range b {
+		b[idx]++
+		if b[idx] != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Block is a candidate block built from Work.
+type Block struct {
+	header   wire.Header
+	coinbase *wire.Tx
+	txs      [][]byte
+	target   *big.Int
+}
+
+// Hash returns the hash of the block, as used by the RPC server.
+func (b *Block) Hash() string {
+	return b.header.Hash().String()
+}
+
+// Header returns the serialized header of the block.
+func (b *Block) Header() []byte {
+	return b.header.Bytes()
+}
+
+// Nonce returns the nonce in the header of the block.
+func (b *Block) Nonce() uint32 {
+	return b.header.Nonce
+}
+
+// CheckProofOfWork reports whether the hash of the block meets the target of the work.
+func (b *Block) CheckProofOfWork() bool {
+	return b.MeetsTarget(b.target)
+}
+
+// MeetsTarget reports whether the hash of the block doesn't exceed target, eg the target of a share in a pool.
+func (b *Block) MeetsTarget(target *big.Int) bool {
+	return b.header.Hash().Big().Cmp(target) <= 0
+}
+
+// Bytes returns the serialized block.
+func (b *Block) Bytes() []byte {
+	buf := b.header.Bytes()
+	buf = wire.AppendVarInt(buf, uint64(1+len(b.txs)))
+	buf = append(buf, b.coinbase.Bytes(true)...)
+
+	for _, tx := range b.txs {
+		buf = append(buf, tx...)
+	}
+
+	return buf
+}
+
+// Hex returns the serialized block as a hex string, as passed to submitblock.
+func (b *Block) Hex() string {
+	return hex.EncodeToString(b.Bytes())
+}
+
+// Mine gets a block template from client, solves it and submits the block. It returns the block, and a
+// *rpcclient.SubmitBlockError if the node didn't accept it. Like Solve, this is only practical on regtest.
+func Mine(ctx context.Context, client rpcclient.IClient, opts *Options) (*Block, error) {
+	tmpl, err := client.GetBlockTemplate(&types.BlockTemplateRequest{Rules: []string{"segwit"}})
+	if err != nil {
+		return nil, err
+	}
+
+	work, err := NewWork(tmpl, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := work.Solve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return block, client.SubmitBlock(block.Hex())
+}
@@ -0,0 +1,153 @@
+package mining
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/internal/wire"
+	"github.com/omarhachach/rpcclient-core/regtest"
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// opTrue is a payout script anyone can spend, which is fine for regtest.
+var opTrue = []byte{0x51}
+
+// testTx is a transaction in testTemplate.
+var testTx = &wire.Tx{
+	Version: 2,
+	Inputs:  []*wire.TxIn{{PrevIndex: 1, Script: []byte{0x51}, Sequence: 0xfffffffd}},
+	Outputs: []*wire.TxOut{{Value: 1000, Script: opTrue}},
+}
+
+// testTemplate returns a regtest block template at height 101 with testTx.
+func testTemplate() *types.BlockTemplate {
+	txid := testTx.TxID()
+
+	return &types.BlockTemplate{
+		Version:                  0x20000000,
+		PreviousBlockhash:        "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+		Transactions:             []*types.BlockTemplateTransaction{{Data: hex.EncodeToString(testTx.Bytes(true)), Txid: txid.String()}},
+		CoinbaseValue:            5000000100,
+		Target:                   "7fffff0000000000000000000000000000000000000000000000000000000000",
+		CurTime:                  1700000000,
+		Bits:                     "207fffff",
+		Height:                   101,
+		DefaultWitnessCommitment: "6a24aa21a9ede2f61c3f71d1defd3fa999dfa36953755c690689799962b48bebd836974e8cf9",
+	}
+}
+
+func TestWork(t *testing.T) {
+	tmpl := testTemplate()
+
+	work, err := NewWork(tmpl, &Options{PayoutScript: opTrue, Tag: []byte("rpcclient")})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultExtraNonceSize, work.ExtraNonceSize)
+
+	block, err := work.Solve(context.Background())
+	require.NoError(t, err)
+	assert.True(t, block.CheckProofOfWork())
+
+	header, err := wire.ParseHeader(block.Header())
+	require.NoError(t, err)
+	assert.Equal(t, int32(0x20000000), header.Version)
+	assert.Equal(t, tmpl.PreviousBlockhash, header.PrevBlock.String())
+	assert.Equal(t, uint32(0x207fffff), header.Bits)
+	assert.Equal(t, uint32(tmpl.CurTime), header.Time)
+	assert.Equal(t, block.Hash(), header.Hash().String())
+
+	// The coinbase without witness is the parts around the extranonce, and its txid is the first leaf of the root.
+	prefix, suffix := work.CoinbaseParts()
+	coinbase := append(append(prefix, make([]byte, DefaultExtraNonceSize)...), suffix...)
+	assert.Equal(t, block.coinbase.Bytes(false), coinbase)
+	assert.Equal(t, wire.MerkleRoot([]wire.Hash{wire.DoubleHash(coinbase), testTx.TxID()}), header.MerkleRoot)
+
+	script := block.coinbase.Inputs[0].Script
+	assert.Equal(t, []byte{0x01, 101, DefaultExtraNonceSize}, script[:3])
+	assert.True(t, bytes.HasSuffix(script, append([]byte{9}, "rpcclient"...)))
+
+	outputs := block.coinbase.Outputs
+	require.Len(t, outputs, 2)
+	assert.Equal(t, int64(5000000100), outputs[0].Value)
+	assert.Equal(t, tmpl.DefaultWitnessCommitment, hex.EncodeToString(outputs[1].Script))
+
+	// The block is the header, the number of transactions, the coinbase with witness and the template transactions.
+	expected := append(block.Header(), 2)
+	expected = append(expected, block.coinbase.Bytes(true)...)
+	expected = append(expected, testTx.Bytes(true)...)
+	assert.Equal(t, hex.EncodeToString(expected), block.Hex())
+
+	_, err = work.Block([]byte{1}, 0, 0)
+	assert.ErrorIs(t, err, ErrExtraNonceSize)
+
+	// A different extranonce changes the merkle root.
+	other, err := work.Block([]byte{1, 0, 0, 0, 0, 0, 0, 0}, header.Time, 0)
+	require.NoError(t, err)
+	otherHeader, err := wire.ParseHeader(other.Header())
+	require.NoError(t, err)
+	assert.NotEqual(t, header.MerkleRoot, otherHeader.MerkleRoot)
+}
+
+func TestNewWork_Invalid(t *testing.T) {
+	_, err := NewWork(testTemplate(), &Options{})
+	assert.ErrorIs(t, err, ErrNoPayoutScript)
+
+	_, err = NewWork(testTemplate(), &Options{PayoutScript: opTrue, Tag: make([]byte, 90)})
+	assert.Error(t, err)
+
+	tmpl := testTemplate()
+	tmpl.Bits = "zz"
+	_, err = NewWork(tmpl, &Options{PayoutScript: opTrue})
+	assert.Error(t, err)
+}
+
+func TestMine(t *testing.T) {
+	var submitted string
+	client := rpcclient.NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		switch method {
+		case "getblocktemplate":
+			data, err := json.Marshal(testTemplate())
+			require.NoError(t, err)
+
+			return json.Unmarshal(data, result)
+		case "submitblock":
+			submitted = params[0].(string)
+			return json.Unmarshal([]byte(`"inconclusive"`), result)
+		}
+
+		return &rpcclient.RPCError{Code: -32601, Message: "Method not found"}
+	})
+
+	block, err := Mine(context.Background(), client, &Options{PayoutScript: opTrue})
+	assert.Equal(t, &rpcclient.SubmitBlockError{Reason: rpcclient.SubmitBlockInconclusive}, err)
+	require.NotNil(t, block)
+	assert.Equal(t, block.Hex(), submitted)
+}
+
+// The node binary used by this test is read from RPCCLIENT_NODE_BINARY, eg /usr/local/bin/bitcoind.
+func TestMine_Regtest(t *testing.T) {
+	node := regtest.New(t, os.Getenv("RPCCLIENT_NODE_BINARY"))
+
+	address, err := node.NewAddress()
+	require.NoError(t, err)
+
+	_, err = node.FundAndConfirm(address, 1)
+	require.NoError(t, err)
+
+	// Leave a transaction in the mempool, so the block has a witness commitment.
+	var txid string
+	require.NoError(t, node.Client.SendReq("sendtoaddress", &txid, address, 0.5))
+
+	block, err := Mine(context.Background(), node.Client, &Options{PayoutScript: opTrue})
+	require.NoError(t, err)
+
+	best, err := node.Client.GetBestBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, block.Hash(), best)
+}
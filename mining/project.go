@@ -0,0 +1,380 @@
+package mining
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// DefaultWeightLimit is the maximum weight of a block, if ProjectOptions.WeightLimit is not set.
+const DefaultWeightLimit = 4_000_000
+
+// DefaultMinFeeRate is the fee rate in sat/vB below which transactions are not selected, if ProjectOptions.MinFeeRate is
+// not set. It is the default of the -blockmintxfee option of Bitcoin Core.
+const DefaultMinFeeRate = 1.0
+
+// coinbaseWeight is the weight reserved for the block header and coinbase, like Bitcoin Core does by default.
+const coinbaseWeight = 4000
+
+// maxConsecutiveFailures is the number of packages which don't fit in a nearly full block after which it is considered
+// full, like in Bitcoin Core.
+const maxConsecutiveFailures = 1000
+
+// ProjectOptions are the options for ProjectBlocks.
+type ProjectOptions struct {
+	// Blocks is the maximum number of blocks to project. If 0, blocks are projected until the mempool is empty.
+	Blocks int
+
+	// WeightLimit is the maximum weight of a block, including the coinbase. Defaults to DefaultWeightLimit.
+	WeightLimit int
+
+	// MinFeeRate is the lowest fee rate in sat/vB of a package to be selected. Defaults to DefaultMinFeeRate, and
+	// negative values select every package.
+	MinFeeRate float64
+}
+
+// ProjectedBlock is a block assembled from the mempool.
+type ProjectedBlock struct {
+	// Txids are the transactions of the block besides the coinbase, in the order they were selected. A transaction
+	// always follows its ancestors.
+	Txids []string
+
+	// FeeRates are the fee rates in sat/vB of the packages the transactions were selected in, the same length as
+	// Txids. A parent selected because of the fee its child pays has the fee rate of the package of both.
+	FeeRates []float64
+
+	// Weight is the total weight of the transactions.
+	Weight int
+
+	// Vsize is the total virtual size of the transactions.
+	Vsize int
+
+	// Fees are the total fees of the transactions in satoshis.
+	Fees int64
+
+	// MinFeeRate and MaxFeeRate are the range of FeeRates.
+	MinFeeRate, MaxFeeRate float64
+}
+
+// MedianFeeRate returns the median of FeeRates.
+func (b *ProjectedBlock) MedianFeeRate() float64 {
+	if len(b.Txids) == 0 {
+		return 0
+	}
+
+	// FeeRates don't have to be in order, as packages with a lower fee rate may fit in the space left at the end.
+	feeRates := append([]float64(nil), b.FeeRates...)
+	sort.Float64s(feeRates)
+
+	if len(feeRates)%2 == 0 {
+		return (feeRates[len(feeRates)/2-1] + feeRates[len(feeRates)/2]) / 2
+	}
+
+	return feeRates[len(feeRates)/2]
+}
+
+// Projection holds the projected blocks, from the next block.
+type Projection []*ProjectedBlock
+
+// BlockOf returns the index of the projected block txid is in, or -1 if it isn't in any of them.
+func (p Projection) BlockOf(txid string) int {
+	for idx, block := range p {
+		for _, id := range block.Txids {
+			if id == txid {
+				return idx
+			}
+		}
+	}
+
+	return -1
+}
+
+// Project projects the next blocks of the mempool of the node with ProjectBlocks, using the weight limit of the block
+// template. If blocks is 0, blocks are projected until the mempool is empty.
+func Project(client rpcclient.IClient, blocks int) (Projection, error) {
+	tmpl, err := client.GetBlockTemplate(&types.BlockTemplateRequest{Rules: []string{"segwit"}})
+	if err != nil {
+		return nil, err
+	}
+
+	mempool, err := client.GetRawMempoolVerbose()
+	if err != nil {
+		return nil, err
+	}
+
+	return ProjectBlocks(mempool, &ProjectOptions{Blocks: blocks, WeightLimit: tmpl.WeightLimit}), nil
+}
+
+// ProjectBlocks assembles blocks from mempool, the result of getrawmempool with verbose set, the way Bitcoin Core
+// assembles block templates: the package of a transaction and its unconfirmed ancestors with the highest fee rate is
+// selected first, so a child can pay for its parents. Modified fees are used, so prioritisetransaction is taken into
+// account. Sigop limits are not.
+//
+// The projection assumes no transactions arrive, and each block is assembled from the transactions not selected in the
+// previous ones.
+func ProjectBlocks(mempool map[string]*types.MempoolTransaction, opts *ProjectOptions) Projection {
+	if opts == nil {
+		opts = &ProjectOptions{}
+	}
+
+	weightLimit := opts.WeightLimit
+	if weightLimit <= 0 {
+		weightLimit = DefaultWeightLimit
+	}
+
+	minFeeRate := opts.MinFeeRate
+	if minFeeRate == 0 {
+		minFeeRate = DefaultMinFeeRate
+	}
+
+	txs := newPoolTxs(mempool)
+
+	var blocks Projection
+
+	for len(txs) > 0 && (opts.Blocks <= 0 || len(blocks) < opts.Blocks) {
+		block := assemble(txs, weightLimit-coinbaseWeight, minFeeRate)
+		if len(block.Txids) == 0 {
+			break
+		}
+
+		blocks = append(blocks, block)
+
+		remaining := txs[:0]
+		for _, tx := range txs {
+			if !tx.selected {
+				remaining = append(remaining, tx)
+			}
+		}
+
+		txs = remaining
+	}
+
+	return blocks
+}
+
+// poolTx is a transaction in the mempool during block assembly.
+type poolTx struct {
+	txid   string
+	fee    int64
+	vsize  int
+	weight int
+
+	parents, children []*poolTx
+
+	// ancestors and descendants are the unconfirmed ancestors and descendants of the transaction, without itself.
+	ancestors, descendants []*poolTx
+
+	// pkgFee, pkgVsize and pkgWeight are the totals of the transaction and its ancestors which haven't been selected.
+	pkgFee    int64
+	pkgVsize  int
+	pkgWeight int
+
+	selected bool
+	index    int
+}
+
+// feeRate returns the fee rate of the package of the transaction in sat/vB.
+func (tx *poolTx) feeRate() float64 {
+	return float64(tx.pkgFee) / float64(tx.pkgVsize)
+}
+
+// newPoolTxs builds the transaction graph of mempool. Parents which aren't in mempool are ignored, as they were
+// confirmed or removed between the calls which retrieved it.
+func newPoolTxs(mempool map[string]*types.MempoolTransaction) []*poolTx {
+	byTxid := make(map[string]*poolTx, len(mempool))
+	txs := make([]*poolTx, 0, len(mempool))
+
+	for txid, entry := range mempool {
+		tx := &poolTx{txid: txid, vsize: max(entry.Vsize, 1), weight: entry.Weight}
+
+		if entry.Fees != nil {
+			tx.fee = int64(math.Round(entry.Fees.Modified * 1e8))
+		}
+
+		if tx.weight <= 0 {
+			tx.weight = tx.vsize * 4
+		}
+
+		byTxid[txid] = tx
+		txs = append(txs, tx)
+	}
+
+	for _, tx := range txs {
+		for _, parentID := range mempool[tx.txid].Depends {
+			if parent, ok := byTxid[parentID]; ok {
+				tx.parents = append(tx.parents, parent)
+				parent.children = append(parent.children, tx)
+			}
+		}
+	}
+
+	for _, tx := range txs {
+		tx.ancestors = walk(tx, func(tx *poolTx) []*poolTx { return tx.parents })
+		tx.descendants = walk(tx, func(tx *poolTx) []*poolTx { return tx.children })
+	}
+
+	return txs
+}
+
+// walk returns the transactions reachable from tx through next, without tx itself.
+func walk(tx *poolTx, next func(*poolTx) []*poolTx) []*poolTx {
+	seen := map[*poolTx]bool{tx: true}
+	stack := append([]*poolTx(nil), next(tx)...)
+
+	var found []*poolTx
+
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if seen[cur] {
+			continue
+		}
+
+		seen[cur] = true
+		found = append(found, cur)
+		stack = append(stack, next(cur)...)
+	}
+
+	return found
+}
+
+// assemble selects the transactions of a block with at most maxWeight weight from txs, marking them as selected.
+func assemble(txs []*poolTx, maxWeight int, minFeeRate float64) *ProjectedBlock {
+	queue := make(packageQueue, 0, len(txs))
+
+	for _, tx := range txs {
+		tx.pkgFee, tx.pkgVsize, tx.pkgWeight = tx.fee, tx.vsize, tx.weight
+
+		for _, ancestor := range tx.ancestors {
+			if !ancestor.selected {
+				tx.pkgFee += ancestor.fee
+				tx.pkgVsize += ancestor.vsize
+				tx.pkgWeight += ancestor.weight
+			}
+		}
+
+		tx.index = len(queue)
+		queue = append(queue, tx)
+	}
+
+	heap.Init(&queue)
+
+	block := &ProjectedBlock{}
+	failures := 0
+
+	for queue.Len() > 0 {
+		tx := heap.Pop(&queue).(*poolTx)
+
+		feeRate := tx.feeRate()
+		if minFeeRate > 0 && feeRate < minFeeRate {
+			break
+		}
+
+		if block.Weight+tx.pkgWeight > maxWeight {
+			failures++
+
+			if failures > maxConsecutiveFailures && block.Weight > maxWeight-coinbaseWeight {
+				break
+			}
+
+			continue
+		}
+
+		failures = 0
+
+		pkg := []*poolTx{tx}
+		for _, ancestor := range tx.ancestors {
+			if !ancestor.selected {
+				pkg = append(pkg, ancestor)
+			}
+		}
+
+		// A transaction has more ancestors than any of its ancestors, so this puts parents before children.
+		sort.SliceStable(pkg, func(i, j int) bool { return len(pkg[i].ancestors) < len(pkg[j].ancestors) })
+
+		for _, member := range pkg {
+			member.selected = true
+
+			block.Txids = append(block.Txids, member.txid)
+			block.FeeRates = append(block.FeeRates, feeRate)
+			block.Weight += member.weight
+			block.Vsize += member.vsize
+			block.Fees += member.fee
+
+			if member.index >= 0 {
+				heap.Remove(&queue, member.index)
+			}
+
+			for _, descendant := range member.descendants {
+				if descendant.selected {
+					continue
+				}
+
+				descendant.pkgFee -= member.fee
+				descendant.pkgVsize -= member.vsize
+				descendant.pkgWeight -= member.weight
+
+				if descendant.index >= 0 {
+					heap.Fix(&queue, descendant.index)
+				}
+			}
+		}
+	}
+
+	if len(block.FeeRates) > 0 {
+		block.MinFeeRate, block.MaxFeeRate = block.FeeRates[0], block.FeeRates[0]
+
+		for _, feeRate := range block.FeeRates {
+			block.MinFeeRate = min(block.MinFeeRate, feeRate)
+			block.MaxFeeRate = max(block.MaxFeeRate, feeRate)
+		}
+	}
+
+	return block
+}
+
+// packageQueue is a max-heap of transactions by the fee rate of their package.
+type packageQueue []*poolTx
+
+func (q packageQueue) Len() int {
+	return len(q)
+}
+
+func (q packageQueue) Less(i, j int) bool {
+	// Compare fee per vsize without dividing, so equal fee rates compare equal.
+	a := q[i].pkgFee * int64(q[j].pkgVsize)
+	b := q[j].pkgFee * int64(q[i].pkgVsize)
+
+	if a != b {
+		return a > b
+	}
+
+	// Break ties by txid, so projections are deterministic.
+	return q[i].txid < q[j].txid
+}
+
+func (q packageQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *packageQueue) Push(x any) {
+	tx := x.(*poolTx)
+	tx.index = len(*q)
+	*q = append(*q, tx)
+}
+
+func (q *packageQueue) Pop() any {
+	old := *q
+	tx := old[len(old)-1]
+	old[len(old)-1] = nil
+	tx.index = -1
+	*q = old[:len(old)-1]
+
+	return tx
+}
@@ -0,0 +1,132 @@
+package mining
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mempoolEntry returns a mempool entry of vsize vbytes paying fee satoshis, spending outputs of depends.
+func mempoolEntry(vsize int, fee int64, depends ...string) *types.MempoolTransaction {
+	return &types.MempoolTransaction{
+		Vsize:   vsize,
+		Weight:  vsize * 4,
+		Fees:    &types.MempoolTransactionFees{Base: float64(fee) / 1e8, Modified: float64(fee) / 1e8},
+		Depends: depends,
+	}
+}
+
+// testMempool holds transactions which fill a bit more than one block of 2500 vB.
+func testMempool() map[string]*types.MempoolTransaction {
+	return map[string]*types.MempoolTransaction{
+		"a": mempoolEntry(1000, 50000),
+		// b pays a low fee rate, but its child c pays for both.
+		"b": mempoolEntry(1000, 2000),
+		"c": mempoolEntry(200, 20000, "b"),
+		"d": mempoolEntry(500, 5000),
+		// f is selected at its own fee rate once its parent a is in a block.
+		"f": mempoolEntry(500, 2500, "a"),
+		// g fills the space left after d doesn't fit anymore.
+		"g": mempoolEntry(300, 2400),
+		// e pays less than the minimum fee rate.
+		"e": mempoolEntry(1000, 500),
+	}
+}
+
+// testWeightLimit is a weight limit for blocks of 2500 vB besides the coinbase.
+const testWeightLimit = coinbaseWeight + 4*2500
+
+func TestProjectBlocks(t *testing.T) {
+	blocks := ProjectBlocks(testMempool(), &ProjectOptions{WeightLimit: testWeightLimit})
+	require.Len(t, blocks, 2)
+
+	pkgRate := 22000.0 / 1200
+
+	assert.Equal(t, &ProjectedBlock{
+		Txids:      []string{"a", "b", "c", "g"},
+		FeeRates:   []float64{50, pkgRate, pkgRate, 8},
+		Weight:     10000,
+		Vsize:      2500,
+		Fees:       74400,
+		MinFeeRate: 8,
+		MaxFeeRate: 50,
+	}, blocks[0])
+	assert.Equal(t, pkgRate, blocks[0].MedianFeeRate())
+
+	assert.Equal(t, &ProjectedBlock{
+		Txids:      []string{"d", "f"},
+		FeeRates:   []float64{10, 5},
+		Weight:     4000,
+		Vsize:      1000,
+		Fees:       7500,
+		MinFeeRate: 5,
+		MaxFeeRate: 10,
+	}, blocks[1])
+	assert.Equal(t, 7.5, blocks[1].MedianFeeRate())
+
+	assert.Equal(t, 0, blocks.BlockOf("c"))
+	assert.Equal(t, 1, blocks.BlockOf("f"))
+	assert.Equal(t, -1, blocks.BlockOf("e"))
+
+	// Every package is selected with a negative minimum fee rate, and the number of blocks can be limited.
+	blocks = ProjectBlocks(testMempool(), &ProjectOptions{WeightLimit: testWeightLimit, MinFeeRate: -1})
+	require.Len(t, blocks, 2)
+	assert.Equal(t, []string{"d", "f", "e"}, blocks[1].Txids)
+
+	blocks = ProjectBlocks(testMempool(), &ProjectOptions{Blocks: 1, WeightLimit: testWeightLimit})
+	assert.Len(t, blocks, 1)
+
+	// A full block takes everything.
+	blocks = ProjectBlocks(testMempool(), nil)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, []string{"a", "b", "c", "d", "g", "f"}, blocks[0].Txids)
+
+	assert.Empty(t, ProjectBlocks(nil, nil))
+}
+
+func TestProjectBlocks_Chain(t *testing.T) {
+	// A chain where the grandchild pays for everything, and a parent which is no longer in the mempool.
+	mempool := map[string]*types.MempoolTransaction{
+		"x": mempoolEntry(100, 100),
+		"y": mempoolEntry(100, 100, "x", "gone"),
+		"z": mempoolEntry(100, 5800, "y"),
+		"w": mempoolEntry(100, 1500, "x"),
+	}
+
+	blocks := ProjectBlocks(mempool, nil)
+	require.Len(t, blocks, 1)
+
+	// w is selected at its own fee rate after x, since x was taken by the package of z.
+	assert.Equal(t, []string{"x", "y", "z", "w"}, blocks[0].Txids)
+	assert.Equal(t, []float64{20, 20, 20, 15}, blocks[0].FeeRates)
+}
+
+func TestProject(t *testing.T) {
+	client := rpcclient.NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		var res any
+
+		switch method {
+		case "getblocktemplate":
+			res = &types.BlockTemplate{WeightLimit: testWeightLimit}
+		case "getrawmempool":
+			res = testMempool()
+		default:
+			return &rpcclient.RPCError{Code: -32601, Message: "Method not found"}
+		}
+
+		data, err := json.Marshal(res)
+		require.NoError(t, err)
+
+		return json.Unmarshal(data, result)
+	})
+
+	blocks, err := Project(client, 0)
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, []string{"a", "b", "c", "g"}, blocks[0].Txids)
+}
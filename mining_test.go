@@ -0,0 +1,40 @@
+package rpcclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_MiningMethods(t *testing.T) {
+	var sent []*Request
+	client := newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		sent = append(sent, req)
+
+		switch req.Method {
+		case "getnetworkhashps":
+			return 6.5e20, nil
+		case "submitblock":
+			if req.Params[0] == "dup" {
+				return SubmitBlockDuplicate, nil
+			}
+		}
+
+		return nil, nil
+	})
+
+	hashps, err := client.GetNetworkHashPS(120, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 6.5e20, hashps)
+	assert.Equal(t, []any{float64(120), float64(-1)}, sent[0].Params)
+
+	require.NoError(t, client.SubmitBlock("00"))
+
+	err = client.SubmitBlock("dup")
+	assert.Equal(t, &SubmitBlockError{Reason: SubmitBlockDuplicate}, err)
+	assert.EqualError(t, err, "rpcclient: block not accepted: duplicate")
+
+	require.NoError(t, client.SubmitHeader("00"))
+	assert.Equal(t, "submitheader", sent[3].Method)
+}
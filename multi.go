@@ -0,0 +1,459 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Enforce MultiClient has to be implementation of IClient.
+var _ IClient = &MultiClient{}
+
+// ErrNoNodes is returned by NewMultiClient if no nodes are configured.
+var ErrNoNodes = errors.New("rpcclient: no nodes configured")
+
+var (
+	errInitialBlockDownload = errors.New("rpcclient: node is in initial block download")
+	errLagging              = errors.New("rpcclient: node is lagging behind the other nodes")
+)
+
+// Strategy is the way a MultiClient picks the node for a read.
+type Strategy int
+
+const (
+	// RoundRobin spreads the reads evenly over the healthy nodes.
+	RoundRobin Strategy = iota
+	// LeastLatency sends the reads to the healthy node with the lowest average latency.
+	LeastLatency
+)
+
+// Defaults for the options in MultiConfig.
+const (
+	defaultMaxBlockLag = 2
+	// latencyWeight is the weight of a new sample in the moving average of a node's latency.
+	latencyWeight = 0.2
+)
+
+// rpcInWarmup is the error code returned by a node while it is starting up.
+const rpcInWarmup = -28
+
+// MultiConfig are the options for a MultiClient.
+type MultiConfig struct {
+	// Nodes are the clients for each of the nodes.
+	Nodes []*Client
+
+	// Strategy is the way reads are spread over the healthy nodes. Defaults to RoundRobin.
+	Strategy Strategy
+
+	// MaxBlockLag is the number of blocks a node may be behind the node with the most blocks, before it is marked
+	// unhealthy. Defaults to 2.
+	MaxBlockLag int
+
+	// HealthCheckInterval is the interval at which the nodes are checked in the background. Zero disables the
+	// background checks, CheckHealth can then be called manually.
+	HealthCheckInterval time.Duration
+}
+
+// NodeStatus is the health of a node as last seen by a MultiClient.
+type NodeStatus struct {
+	// Host is the Config.Host of the node's client.
+	Host string
+	// Healthy reports whether reads are sent to the node.
+	Healthy bool
+	// Blocks is the block count reported by the last health check.
+	Blocks int32
+	// Latency is the moving average of the node's response time.
+	Latency time.Duration
+	// Err is the reason the node is unhealthy.
+	Err error
+}
+
+// MultiClient is an IClient over several nodes. Reads are sent to one of the healthy nodes, and fail over to the next
+// node if the node can't be reached. Streamed results only fail over until their decoding has started. Transactions,
+// blocks and the other broadcastMethods are sent to every node. Nodes which can't be reached, are in initial block
+// download or are lagging behind the other nodes are unhealthy.
+type MultiClient struct {
+	*Client
+
+	config *MultiConfig
+	nodes  []*node
+	next   uint32
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// node holds the state of a single node of a MultiClient.
+type node struct {
+	client *Client
+
+	mu      sync.Mutex
+	healthy bool
+	blocks  int32
+	latency time.Duration
+	err     error
+}
+
+// blockCount is the result of a health check of a node.
+type blockCount struct {
+	blocks int32
+	ibd    bool
+	err    error
+}
+
+// NewMultiClient creates a new *MultiClient for the nodes in config. All nodes are considered healthy until the first
+// health check. If config.HealthCheckInterval is set, the nodes are checked right away and then at every interval
+// until Close is called.
+func NewMultiClient(config *MultiConfig) (*MultiClient, error) {
+	if len(config.Nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	m := &MultiClient{
+		config: config,
+		nodes:  make([]*node, len(config.Nodes)),
+	}
+
+	for idx, client := range config.Nodes {
+		m.nodes[idx] = &node{client: client, healthy: true}
+	}
+
+	m.Client = NewWithSendFunc(m.send)
+
+	if config.HealthCheckInterval > 0 {
+		var ctx context.Context
+		ctx, m.cancel = context.WithCancel(context.Background())
+		m.done = make(chan struct{})
+
+		go m.checkLoop(ctx)
+	}
+
+	return m, nil
+}
+
+// Close stops the background health checks.
+func (m *MultiClient) Close() {
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+}
+
+// checkLoop runs the health checks until ctx is cancelled.
+func (m *MultiClient) checkLoop(ctx context.Context) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		m.CheckHealth(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CheckHealth checks every node with GetBlockChainInfo, and updates which of the nodes are healthy.
+func (m *MultiClient) CheckHealth(ctx context.Context) {
+	infos := make([]*blockCount, len(m.nodes))
+
+	var wg sync.WaitGroup
+	for idx, n := range m.nodes {
+		nodeCtx, _ := forkCallInfo(ctx)
+
+		wg.Add(1)
+		go func(idx int, n *node) {
+			defer wg.Done()
+
+			start := time.Now()
+			info, err := n.client.WithContext(nodeCtx).GetBlockChainInfo()
+			if err == nil {
+				n.observe(time.Since(start))
+				infos[idx] = &blockCount{blocks: info.Blocks, ibd: info.InitialBlockDownload}
+			} else {
+				infos[idx] = &blockCount{err: err}
+			}
+		}(idx, n)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	var best int32
+	for _, info := range infos {
+		if info.err == nil && info.blocks > best {
+			best = info.blocks
+		}
+	}
+
+	maxLag := int32(m.config.MaxBlockLag)
+	if maxLag <= 0 {
+		maxLag = defaultMaxBlockLag
+	}
+
+	for idx, info := range infos {
+		err := info.err
+		switch {
+		case err != nil:
+		case info.ibd:
+			err = errInitialBlockDownload
+		case best-info.blocks > maxLag:
+			err = errLagging
+		}
+
+		n := m.nodes[idx]
+		n.mu.Lock()
+		n.healthy, n.err = err == nil, err
+		if info.err == nil {
+			n.blocks = info.blocks
+		}
+		n.mu.Unlock()
+	}
+}
+
+// Nodes returns the status of every node, in the order of MultiConfig.Nodes.
+func (m *MultiClient) Nodes() []NodeStatus {
+	statuses := make([]NodeStatus, len(m.nodes))
+	for idx, n := range m.nodes {
+		n.mu.Lock()
+		statuses[idx] = NodeStatus{
+			Host:    n.client.config.Host,
+			Healthy: n.healthy,
+			Blocks:  n.blocks,
+			Latency: n.latency,
+			Err:     n.err,
+		}
+		n.mu.Unlock()
+	}
+
+	return statuses
+}
+
+// send is the SendFunc of the MultiClient.
+func (m *MultiClient) send(ctx context.Context, method string, result any, params ...any) error {
+	if broadcastMethods[method] {
+		return m.broadcast(ctx, method, result, params)
+	}
+
+	stream, isStream := result.(StreamResult)
+
+	var err error
+	for attempt, n := range m.pick() {
+		res := result
+
+		var tracked *trackedStream
+		if isStream {
+			tracked = &trackedStream{StreamResult: stream}
+			res = tracked
+		} else if attempt > 0 {
+			// The failed node may have decoded part of its result already, like some of the entries of a map.
+			resetResult(result)
+		}
+
+		start := time.Now()
+		err = n.client.SendReqContext(ctx, method, res, params...)
+
+		// Once a stream has started, its result may have been passed on partially, and the error may come from the
+		// caller's callback, so it isn't the node's fault and the request can't be sent to another node. An error
+		// returned by the node comes with a null result, so nothing was passed on.
+		var rpcErr *RPCError
+		if tracked != nil && tracked.started && err != nil && !errors.As(err, &rpcErr) {
+			return err
+		}
+
+		if !isNodeFailure(err) {
+			n.observe(time.Since(start))
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		n.markUnhealthy(err)
+
+		if isMutating(method) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// resetResult sets the value result points to back to its zero value.
+func resetResult(result any) {
+	if value := reflect.ValueOf(result); value.Kind() == reflect.Pointer && !value.IsNil() {
+		value.Elem().SetZero()
+	}
+}
+
+// trackedStream is a StreamResult which records whether its decoding has started.
+type trackedStream struct {
+	StreamResult
+	started bool
+}
+
+// DecodeJSONStream implements StreamResult.
+func (s *trackedStream) DecodeJSONStream(dec *json.Decoder) error {
+	s.started = true
+
+	return s.StreamResult.DecodeJSONStream(dec)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, for nodes which don't decode the result from the response body.
+func (s *trackedStream) UnmarshalJSON(data []byte) error {
+	s.started = true
+
+	if unmarshaler, ok := s.StreamResult.(json.Unmarshaler); ok {
+		return unmarshaler.UnmarshalJSON(data)
+	}
+
+	return unmarshalStream(s.StreamResult, data)
+}
+
+// broadcast sends the request to every node, and decodes the result of the first node that succeeds. If all nodes fail,
+// an error returned by a node is preferred over an error reaching a node.
+func (m *MultiClient) broadcast(ctx context.Context, method string, result any, params []any) error {
+	type response struct {
+		raw  json.RawMessage
+		err  error
+		info *CallInfo
+	}
+
+	responses := make([]response, len(m.nodes))
+
+	var wg sync.WaitGroup
+	for idx, n := range m.nodes {
+		var nodeCtx context.Context
+		nodeCtx, responses[idx].info = forkCallInfo(ctx)
+
+		wg.Add(1)
+		go func(idx int, n *node) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := n.client.SendReqContext(nodeCtx, method, &responses[idx].raw, params...)
+			if !isNodeFailure(err) {
+				n.observe(time.Since(start))
+			} else if ctx.Err() == nil {
+				n.markUnhealthy(err)
+			}
+
+			responses[idx].err = err
+		}(idx, n)
+	}
+	wg.Wait()
+
+	var failed *response
+	for idx := range responses {
+		res := &responses[idx]
+		if res.err == nil {
+			joinCallInfo(ctx, res.info)
+
+			if result == nil {
+				return nil
+			}
+
+			return json.Unmarshal(res.raw, result)
+		}
+
+		if failed == nil || !isNodeFailure(res.err) {
+			failed = res
+		}
+	}
+
+	joinCallInfo(ctx, failed.info)
+
+	return failed.err
+}
+
+// pick returns the order in which the nodes are tried for a read. The healthy nodes come first, ordered by the
+// Strategy, followed by the unhealthy nodes as a last resort.
+func (m *MultiClient) pick() []*node {
+	healthy := make([]*node, 0, len(m.nodes))
+	unhealthy := make([]*node, 0, len(m.nodes))
+
+	for _, n := range m.nodes {
+		if n.isHealthy() {
+			healthy = append(healthy, n)
+		} else {
+			unhealthy = append(unhealthy, n)
+		}
+	}
+
+	switch m.config.Strategy {
+	case LeastLatency:
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return healthy[i].averageLatency() < healthy[j].averageLatency()
+		})
+	default:
+		if len(healthy) > 0 {
+			offset := int(atomic.AddUint32(&m.next, 1)-1) % len(healthy)
+			healthy = append(healthy[offset:], healthy[:offset]...)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// isNodeFailure reports whether err means the node could not serve the request, so it should be sent to another node.
+// An error returned by the node is not a failure of the node, unless the node is still starting up.
+func isNodeFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == rpcInWarmup
+	}
+
+	return true
+}
+
+// observe records a successful response which took latency.
+func (n *node) observe(latency time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.latency == 0 {
+		n.latency = latency
+	} else {
+		n.latency += time.Duration(latencyWeight * float64(latency-n.latency))
+	}
+}
+
+// markUnhealthy marks the node unhealthy until the next health check.
+func (n *node) markUnhealthy(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.healthy, n.err = false, err
+}
+
+// isHealthy reports whether the node is healthy.
+func (n *node) isHealthy() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.healthy
+}
+
+// averageLatency returns the moving average of the node's latency.
+func (n *node) averageLatency() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.latency
+}
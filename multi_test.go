@@ -0,0 +1,228 @@
+package rpcclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNode is a fake node for MultiClient tests, which records the methods it receives.
+type testNode struct {
+	name   string
+	blocks int
+	ibd    bool
+
+	mu      sync.Mutex
+	methods []string
+}
+
+// client returns a client connected to the node.
+func (n *testNode) client(t *testing.T) *Client {
+	return newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		n.mu.Lock()
+		n.methods = append(n.methods, req.Method)
+		n.mu.Unlock()
+
+		switch req.Method {
+		case "getblockchaininfo":
+			return map[string]any{"blocks": n.blocks, "initialblockdownload": n.ibd}, nil
+		case "getrawmempool":
+			return map[string]any{"tx-" + n.name: map[string]any{"vsize": 100}, "tx2-" + n.name: map[string]any{"vsize": 200}}, nil
+		case "sendrawtransaction":
+			if n.name == "b" {
+				return nil, &RPCError{Code: -26, Message: "txn-mempool-conflict"}
+			}
+		}
+
+		return n.name, nil
+	})
+}
+
+// received returns the methods received by the node, except for the health checks.
+func (n *testNode) received() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var methods []string
+	for _, method := range n.methods {
+		if method != "getblockchaininfo" {
+			methods = append(methods, method)
+		}
+	}
+
+	return methods
+}
+
+// downClient returns a client for a node which can't be reached.
+func downClient(t *testing.T) *Client {
+	client, err := New(&Config{Host: "http://127.0.0.1:1", DisableTLS: true})
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestMultiClient_CheckHealth(t *testing.T) {
+	a := &testNode{name: "a", blocks: 100}
+	b := &testNode{name: "b", blocks: 97}
+	c := &testNode{name: "c", blocks: 100, ibd: true}
+
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{a.client(t), b.client(t), c.client(t), downClient(t)}})
+	require.NoError(t, err)
+
+	m.CheckHealth(context.Background())
+
+	statuses := m.Nodes()
+	require.Len(t, statuses, 4)
+	assert.True(t, statuses[0].Healthy)
+	assert.Equal(t, int32(100), statuses[0].Blocks)
+	assert.NotZero(t, statuses[0].Latency)
+	assert.ErrorIs(t, statuses[1].Err, errLagging)
+	assert.ErrorIs(t, statuses[2].Err, errInitialBlockDownload)
+	assert.False(t, statuses[3].Healthy)
+	assert.Error(t, statuses[3].Err)
+
+	for idx := 0; idx < 3; idx++ {
+		hash, err := m.GetBestBlockHash()
+		require.NoError(t, err)
+		assert.Equal(t, "a", hash)
+	}
+
+	b.blocks = 99
+	m.CheckHealth(context.Background())
+	assert.True(t, m.Nodes()[1].Healthy)
+
+	_, err = NewMultiClient(&MultiConfig{})
+	assert.ErrorIs(t, err, ErrNoNodes)
+}
+
+func TestMultiClient_RoundRobinAndFailover(t *testing.T) {
+	a := &testNode{name: "a"}
+	b := &testNode{name: "b"}
+
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{a.client(t), downClient(t), b.client(t)}})
+	require.NoError(t, err)
+
+	var hashes []string
+	for idx := 0; idx < 4; idx++ {
+		hash, err := m.GetBestBlockHash()
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+
+	assert.ElementsMatch(t, []string{"a", "a", "b", "b"}, hashes)
+	assert.False(t, m.Nodes()[1].Healthy)
+}
+
+func TestMultiClient_FailoverResetsResult(t *testing.T) {
+	// The first node fails after decoding part of the mempool.
+	partial := NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		if err := json.Unmarshal([]byte(`{"tx-stale": {"vsize": 300}}`), result); err != nil {
+			return err
+		}
+
+		return errors.New("connection reset by peer")
+	})
+
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{partial, (&testNode{name: "a"}).client(t)}})
+	require.NoError(t, err)
+
+	mempool, err := m.GetRawMempoolVerbose()
+	require.NoError(t, err)
+	assert.Len(t, mempool, 2)
+	assert.NotContains(t, mempool, "tx-stale")
+}
+
+func TestMultiClient_LeastLatency(t *testing.T) {
+	a := &testNode{name: "a"}
+	b := &testNode{name: "b"}
+
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{a.client(t), b.client(t)}, Strategy: LeastLatency})
+	require.NoError(t, err)
+
+	m.nodes[0].latency, m.nodes[1].latency = 50, 10
+
+	hash, err := m.GetBestBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, "b", hash)
+}
+
+func TestMultiClient_Broadcast(t *testing.T) {
+	a := &testNode{name: "a"}
+	b := &testNode{name: "b"}
+
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{a.client(t), b.client(t), downClient(t)}})
+	require.NoError(t, err)
+
+	txid, err := m.SendRawTransaction("00", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a", txid)
+	assert.Equal(t, []string{"sendrawtransaction"}, a.received())
+	assert.Equal(t, []string{"sendrawtransaction"}, b.received())
+
+	m, err = NewMultiClient(&MultiConfig{Nodes: []*Client{downClient(t), b.client(t)}})
+	require.NoError(t, err)
+
+	_, err = m.SendRawTransaction("00", nil)
+	var rpcErr *RPCError
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -26, rpcErr.Code)
+}
+
+func TestMultiClient_NoRetryForNodeState(t *testing.T) {
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{downClient(t), (&testNode{name: "a"}).client(t)}})
+	require.NoError(t, err)
+
+	_, err = m.GenerateToAddress(1, "addr", 0)
+	assert.Error(t, err)
+}
+
+func TestMultiClient_Stream(t *testing.T) {
+	nodes := []*testNode{{name: "a"}, {name: "b"}, {name: "c"}}
+	m, err := NewMultiClient(&MultiConfig{Nodes: []*Client{nodes[0].client(t), nodes[1].client(t), nodes[2].client(t)}})
+	require.NoError(t, err)
+
+	// An error of the callback is returned right away, and isn't held against the node.
+	stop := errors.New("stop")
+	calls := 0
+
+	err = m.IterRawMempoolVerbose(func(txid string, entry *types.MempoolTransaction) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+
+	for _, status := range m.Nodes() {
+		assert.True(t, status.Healthy)
+		assert.NoError(t, status.Err)
+	}
+
+	var received int
+	for _, n := range nodes {
+		received += len(n.received())
+	}
+
+	assert.Equal(t, 1, received)
+
+	// A node which can't be reached fails over before the stream has started.
+	a := &testNode{name: "a"}
+	m, err = NewMultiClient(&MultiConfig{Nodes: []*Client{downClient(t), a.client(t)}, Strategy: LeastLatency})
+	require.NoError(t, err)
+
+	m.nodes[0].latency, m.nodes[1].latency = 10, 50
+
+	var txids []string
+	err = m.IterRawMempoolVerbose(func(txid string, entry *types.MempoolTransaction) error {
+		txids = append(txids, txid)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tx-a", "tx2-a"}, txids)
+	assert.False(t, m.Nodes()[0].Healthy)
+}
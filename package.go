@@ -0,0 +1,258 @@
+package rpcclient
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/omarhachach/rpcclient-core/internal/wire"
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// MaxPackageCount is the maximum number of transactions in a package accepted by submitpackage and testmempoolaccept.
+const MaxPackageCount = 25
+
+// MaxPackageWeight is the maximum total weight of the transactions in a package.
+const MaxPackageWeight = 404_000
+
+var (
+	// ErrPackageSize is returned when a package is empty, or has more than MaxPackageCount transactions or
+	// MaxPackageWeight weight.
+	ErrPackageSize = errors.New("rpcclient: package size out of bounds")
+
+	// ErrPackageDuplicate is returned when a package contains a transaction more than once.
+	ErrPackageDuplicate = errors.New("rpcclient: package contains duplicate transactions")
+
+	// ErrPackageConflict is returned when transactions of a package spend the same output.
+	ErrPackageConflict = errors.New("rpcclient: package contains conflicting transactions")
+
+	// ErrPackageNotSorted is returned when a transaction of a package comes before one of its parents.
+	ErrPackageNotSorted = errors.New("rpcclient: package not sorted")
+
+	// ErrPackageShape is returned when a package isn't a child with its parents, where the parents don't spend each
+	// other, as required by submitpackage.
+	ErrPackageShape = errors.New("rpcclient: package is not a child with its parents")
+)
+
+// SubmitPackageError is returned by SubmitPackage when the node didn't accept the whole package. The result is returned
+// with it, for the results of the individual transactions.
+type SubmitPackageError struct {
+	// Message is the package_msg of the result.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *SubmitPackageError) Error() string {
+	return "rpcclient: package not accepted: " + e.Message
+}
+
+// SubmitPackage submits a package of a child transaction with its unconfirmed parents to the mempool, so a child can
+// pay for parents which don't meet the minimum fee rate of the mempool on their own. The package is sorted with
+// SortPackage and checked with ValidatePackage first. If maxFeeRate (in BTC/kvB) or maxBurnAmount (in BTC) are nil,
+// the node defaults are used.
+func (c *Client) SubmitPackage(rawtxs []string, maxFeeRate, maxBurnAmount *float64) (*types.SubmitPackageResult, error) {
+	sorted, err := SortPackage(rawtxs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidatePackage(sorted); err != nil {
+		return nil, err
+	}
+
+	params := []any{sorted}
+
+	switch {
+	case maxBurnAmount != nil:
+		// The node treats null as the default.
+		var feeRate any
+		if maxFeeRate != nil {
+			feeRate = *maxFeeRate
+		}
+
+		params = append(params, feeRate, *maxBurnAmount)
+	case maxFeeRate != nil:
+		params = append(params, *maxFeeRate)
+	}
+
+	var res *types.SubmitPackageResult
+	if err := c.SendReq("submitpackage", &res, params...); err != nil {
+		return res, err
+	}
+
+	if res.PackageMsg != "success" {
+		return res, &SubmitPackageError{Message: res.PackageMsg}
+	}
+
+	return res, nil
+}
+
+// packageTx is a parsed transaction of a package.
+type packageTx struct {
+	raw  string
+	tx   *wire.Tx
+	txid wire.Hash
+}
+
+// outpoint identifies a transaction output.
+type outpoint struct {
+	hash  wire.Hash
+	index uint32
+}
+
+// parsePackage parses the raw transactions of a package, and checks that they are unique and don't conflict.
+func parsePackage(rawtxs []string) ([]*packageTx, error) {
+	txs := make([]*packageTx, len(rawtxs))
+	txids := make(map[wire.Hash]bool, len(rawtxs))
+	spent := map[outpoint]bool{}
+
+	for idx, raw := range rawtxs {
+		b, err := hex.DecodeString(raw)
+		if err != nil {
+			return nil, fmt.Errorf("rpcclient: package transaction %v: %w", idx, err)
+		}
+
+		tx, err := wire.ParseTx(b)
+		if err != nil {
+			return nil, fmt.Errorf("rpcclient: package transaction %v: %w", idx, err)
+		}
+
+		txs[idx] = &packageTx{raw: raw, tx: tx, txid: tx.TxID()}
+
+		if txids[txs[idx].txid] {
+			return nil, fmt.Errorf("%w: %v", ErrPackageDuplicate, txs[idx].txid)
+		}
+
+		txids[txs[idx].txid] = true
+
+		for _, in := range tx.Inputs {
+			prevOut := outpoint{hash: in.PrevHash, index: in.PrevIndex}
+			if spent[prevOut] {
+				return nil, fmt.Errorf("%w: %v:%v", ErrPackageConflict, in.PrevHash, in.PrevIndex)
+			}
+
+			spent[prevOut] = true
+		}
+	}
+
+	return txs, nil
+}
+
+// parents returns the indexes in txs of the transactions tx spends.
+func (tx *packageTx) parents(byTxid map[wire.Hash]int) []int {
+	var parents []int
+
+	seen := map[int]bool{}
+
+	for _, in := range tx.tx.Inputs {
+		if idx, ok := byTxid[in.PrevHash]; ok && !seen[idx] {
+			seen[idx] = true
+			parents = append(parents, idx)
+		}
+	}
+
+	return parents
+}
+
+// SortPackage sorts the raw transactions of a package topologically, so every transaction comes after the transactions
+// it spends. Otherwise, the order of the transactions is kept. It returns an error if the package contains duplicate or
+// conflicting transactions.
+func SortPackage(rawtxs []string) ([]string, error) {
+	txs, err := parsePackage(rawtxs)
+	if err != nil {
+		return nil, err
+	}
+
+	byTxid := make(map[wire.Hash]int, len(txs))
+	for idx, tx := range txs {
+		byTxid[tx.txid] = idx
+	}
+
+	added := make([]bool, len(txs))
+	sorted := make([]string, 0, len(txs))
+
+	// Repeatedly add the first transaction whose parents have been added. Packages are small, so this is fast enough.
+	for len(sorted) < len(txs) {
+		next := -1
+
+		for idx, tx := range txs {
+			if added[idx] {
+				continue
+			}
+
+			ready := true
+			for _, parent := range tx.parents(byTxid) {
+				ready = ready && added[parent]
+			}
+
+			if ready {
+				next = idx
+				break
+			}
+		}
+
+		// Only possible for transactions which spend each other, which can't be mined.
+		if next < 0 {
+			return nil, ErrPackageNotSorted
+		}
+
+		added[next] = true
+		sorted = append(sorted, txs[next].raw)
+	}
+
+	return sorted, nil
+}
+
+// ValidatePackage checks the raw transactions of a package like submitpackage does before looking at the mempool: the
+// package has at most MaxPackageCount transactions and MaxPackageWeight weight, no duplicate or conflicting
+// transactions, and is sorted. It is a child with its parents: the last transaction spends all others, which don't
+// spend each other.
+func ValidatePackage(rawtxs []string) error {
+	if len(rawtxs) == 0 || len(rawtxs) > MaxPackageCount {
+		return ErrPackageSize
+	}
+
+	txs, err := parsePackage(rawtxs)
+	if err != nil {
+		return err
+	}
+
+	var weight int
+
+	byTxid := make(map[wire.Hash]int, len(txs))
+	for idx, tx := range txs {
+		byTxid[tx.txid] = idx
+		weight += tx.tx.Weight()
+	}
+
+	if weight > MaxPackageWeight {
+		return ErrPackageSize
+	}
+
+	child := txs[len(txs)-1]
+	childParents := map[int]bool{}
+
+	for _, parent := range child.parents(byTxid) {
+		childParents[parent] = true
+	}
+
+	for idx, tx := range txs[:len(txs)-1] {
+		parents := tx.parents(byTxid)
+
+		for _, parent := range parents {
+			if parent > idx {
+				return fmt.Errorf("%w: %v", ErrPackageNotSorted, tx.txid)
+			}
+		}
+
+		if len(parents) > 0 {
+			return fmt.Errorf("%w: %v spends another parent", ErrPackageShape, tx.txid)
+		}
+
+		if !childParents[idx] {
+			return fmt.Errorf("%w: %v isn't spent by the child", ErrPackageShape, tx.txid)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package rpcclient
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core/internal/wire"
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPackageTx returns a transaction spending output 0 of each of parents, or a confirmed output if there are none.
+// The value of its output makes it unique.
+func testPackageTx(value int64, parents ...*wire.Tx) *wire.Tx {
+	tx := &wire.Tx{
+		Version: 2,
+		Outputs: []*wire.TxOut{{Value: value, Script: []byte{0x51}}},
+	}
+
+	for _, parent := range parents {
+		tx.Inputs = append(tx.Inputs, &wire.TxIn{PrevHash: parent.TxID(), Sequence: 0xfffffffd})
+	}
+
+	if len(parents) == 0 {
+		tx.Inputs = []*wire.TxIn{{PrevHash: wire.DoubleHash([]byte{byte(value)}), Witness: [][]byte{{0x01}}}}
+	}
+
+	return tx
+}
+
+func rawTxs(txs ...*wire.Tx) []string {
+	raw := make([]string, len(txs))
+	for idx, tx := range txs {
+		raw[idx] = hex.EncodeToString(tx.Bytes(true))
+	}
+
+	return raw
+}
+
+func TestSortPackage(t *testing.T) {
+	parent1 := testPackageTx(1)
+	parent2 := testPackageTx(2)
+	child := testPackageTx(3, parent1, parent2)
+	grandchild := testPackageTx(4, child)
+
+	sorted, err := SortPackage(rawTxs(grandchild, child, parent2, parent1))
+	require.NoError(t, err)
+	assert.Equal(t, rawTxs(parent2, parent1, child, grandchild), sorted)
+
+	sorted, err = SortPackage(rawTxs(parent1, child, parent2))
+	require.NoError(t, err)
+	assert.Equal(t, rawTxs(parent1, parent2, child), sorted)
+
+	_, err = SortPackage(rawTxs(parent1, child, parent1))
+	assert.ErrorIs(t, err, ErrPackageDuplicate)
+
+	conflict := testPackageTx(5, parent1)
+	_, err = SortPackage(rawTxs(parent1, child, conflict))
+	assert.ErrorIs(t, err, ErrPackageConflict)
+
+	_, err = SortPackage([]string{"00"})
+	assert.ErrorIs(t, err, wire.ErrMalformedTx)
+}
+
+func TestValidatePackage(t *testing.T) {
+	parent1 := testPackageTx(1)
+	parent2 := testPackageTx(2)
+	child := testPackageTx(3, parent1, parent2)
+
+	assert.NoError(t, ValidatePackage(rawTxs(parent1, parent2, child)))
+	assert.NoError(t, ValidatePackage(rawTxs(parent2, child)))
+	assert.NoError(t, ValidatePackage(rawTxs(parent1)))
+
+	assert.ErrorIs(t, ValidatePackage(nil), ErrPackageSize)
+	assert.ErrorIs(t, ValidatePackage(make([]string, MaxPackageCount+1)), ErrPackageSize)
+
+	large := testPackageTx(4)
+	large.Outputs[0].Script = make([]byte, MaxPackageWeight/4)
+	assert.ErrorIs(t, ValidatePackage(rawTxs(large)), ErrPackageSize)
+
+	assert.ErrorIs(t, ValidatePackage(rawTxs(child, parent1, parent2)), ErrPackageNotSorted)
+	assert.ErrorIs(t, ValidatePackage(rawTxs(parent1, testPackageTx(5), child)), ErrPackageShape)
+
+	// A chain of three isn't a child with its parents.
+	grandchild := testPackageTx(6, child)
+	assert.ErrorIs(t, ValidatePackage(rawTxs(parent1, parent2, child, grandchild)), ErrPackageShape)
+}
+
+func TestClient_SubmitPackage(t *testing.T) {
+	parent := testPackageTx(1)
+	child := testPackageTx(2, parent)
+	wtxid := child.WTxID().String()
+
+	var sent []*Request
+	client := newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		sent = append(sent, req)
+
+		msg := "success"
+		if len(req.Params) > 1 {
+			msg = "transaction failed"
+		}
+
+		return map[string]any{
+			"package_msg": msg,
+			"tx-results": map[string]any{
+				wtxid: map[string]any{
+					"txid":  child.TxID().String(),
+					"vsize": 100,
+					"fees":  map[string]any{"base": 0.0001, "effective-feerate": 0.00025, "effective-includes": []string{wtxid}},
+				},
+			},
+			"replaced-transactions": []string{},
+		}, nil
+	})
+
+	res, err := client.SubmitPackage(rawTxs(child, parent), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []any{[]any{rawTxs(parent)[0], rawTxs(child)[0]}}, sent[0].Params)
+	assert.Equal(t, &types.SubmitPackageTxResult{
+		Txid:  child.TxID().String(),
+		Vsize: 100,
+		Fees:  &types.MempoolAcceptFees{Base: 0.0001, EffectiveFeeRate: 0.00025, EffectiveIncludes: []string{wtxid}},
+	}, res.TxResults[wtxid])
+
+	burn := 0.0
+	res, err = client.SubmitPackage(rawTxs(parent, child), nil, &burn)
+	assert.Equal(t, &SubmitPackageError{Message: "transaction failed"}, err)
+	assert.NotNil(t, res)
+	assert.Equal(t, []any{nil, 0.0}, sent[1].Params[1:])
+
+	// Invalid packages aren't sent.
+	_, err = client.SubmitPackage(rawTxs(parent, testPackageTx(3)), nil, nil)
+	assert.ErrorIs(t, err, ErrPackageShape)
+	assert.Len(t, sent, 2)
+}
+
+func TestClient_TestMempoolAccept(t *testing.T) {
+	client := newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		return []map[string]any{
+			{"txid": "aa", "wtxid": "bb", "allowed": true, "vsize": 141, "fees": map[string]any{"base": 0.00001, "effective-feerate": 0.0001, "effective-includes": []string{"bb"}}},
+			{"txid": "cc", "wtxid": "dd", "package-error": "package-not-sorted"},
+		}, nil
+	})
+
+	res, err := client.TestMempoolAccept([]string{"00", "01"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []*types.TestMempoolAcceptResult{
+		{
+			Txid:    "aa",
+			Wtxid:   "bb",
+			Allowed: true,
+			Vsize:   141,
+			Fees:    types.MempoolAcceptFees{Base: 0.00001, EffectiveFeeRate: 0.0001, EffectiveIncludes: []string{"bb"}},
+		},
+		{Txid: "cc", Wtxid: "dd", PackageError: "package-not-sorted"},
+	}, res)
+}
@@ -0,0 +1,221 @@
+package rpcclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Enforce QuorumClient has to be implementation of IClient.
+var _ IClient = &QuorumClient{}
+
+// ErrQuorumMutating is returned by a QuorumClient for methods which change the state of the node or the network, as
+// there is nothing to agree on. Use a MultiClient to broadcast them instead.
+var ErrQuorumMutating = errors.New("rpcclient: quorum is only supported for reads")
+
+// volatileFields are the fields that DefaultNormalize removes from objects, as nodes which agree on the chain may still
+// return different values for them.
+var volatileFields = []string{"confirmations", "nextblockhash"}
+
+// QuorumConfig are the options for a QuorumClient.
+type QuorumConfig struct {
+	// Nodes are the clients for each of the nodes. Every read is sent to all of them.
+	Nodes []*Client
+
+	// Required is the number of nodes that must agree on the result. Defaults to a majority of the nodes.
+	Required int
+
+	// Normalize returns the part of a result that the nodes must agree on. The result is decoded with
+	// json.Decoder.UseNumber. Defaults to DefaultNormalize.
+	Normalize func(method string, result any) any
+}
+
+// QuorumClient is an IClient which sends every read to several nodes, and only returns the result if enough of the
+// nodes agree on it. Errors returned by the nodes count as an answer too, so a transaction which isn't found by a
+// quorum of the nodes results in that error. Streamed results are buffered to be compared, and only the agreed result
+// is passed to the stream, once.
+type QuorumClient struct {
+	*Client
+
+	config *QuorumConfig
+}
+
+// QuorumError is returned by a QuorumClient if not enough nodes agree on the result.
+type QuorumError struct {
+	// Method is the method of the request.
+	Method string
+	// Required is the number of nodes that had to agree.
+	Required int
+	// Agreed is the number of nodes in the largest group that agreed.
+	Agreed int
+	// Responses holds the answer of each node, in the order of QuorumConfig.Nodes.
+	Responses []QuorumResponse
+}
+
+// QuorumResponse is the answer of a single node to a quorum read.
+type QuorumResponse struct {
+	// Host is the Config.Host of the node's client.
+	Host string
+	// Result is the raw result returned by the node, if Err is nil.
+	Result json.RawMessage
+	// Err is the error returned by the node, or the error reaching it.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *QuorumError) Error() string {
+	answers := make([]string, len(e.Responses))
+	for idx, res := range e.Responses {
+		if res.Err != nil {
+			answers[idx] = fmt.Sprintf("%v: %v", res.Host, res.Err)
+		} else {
+			answers[idx] = fmt.Sprintf("%v: %s", res.Host, res.Result)
+		}
+	}
+
+	return fmt.Sprintf("rpcclient: no quorum for %v: %v of %v required nodes agree (%v)", e.Method, e.Agreed,
+		e.Required, strings.Join(answers, "; "))
+}
+
+// NewQuorumClient creates a new *QuorumClient for the nodes in config.
+func NewQuorumClient(config *QuorumConfig) (*QuorumClient, error) {
+	if len(config.Nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	if config.Required > len(config.Nodes) {
+		return nil, fmt.Errorf("rpcclient: quorum of %v is larger than the %v nodes", config.Required, len(config.Nodes))
+	}
+
+	q := &QuorumClient{config: config}
+	q.Client = NewWithSendFunc(q.send)
+
+	return q, nil
+}
+
+// DefaultNormalize removes the volatileFields from objects, which differ between nodes that are a few blocks apart.
+func DefaultNormalize(method string, result any) any {
+	if obj, ok := result.(map[string]any); ok {
+		for _, field := range volatileFields {
+			delete(obj, field)
+		}
+	}
+
+	return result
+}
+
+// quorumVote is the answer of a node, keyed by its normalized result.
+type quorumVote struct {
+	idx int
+	key string
+}
+
+// send is the SendFunc of the QuorumClient. It returns as soon as the required number of nodes agree.
+func (q *QuorumClient) send(ctx context.Context, method string, result any, params ...any) error {
+	if isMutating(method) {
+		return ErrQuorumMutating
+	}
+
+	required := q.config.Required
+	if required <= 0 {
+		required = len(q.config.Nodes)/2 + 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses := make([]QuorumResponse, len(q.config.Nodes))
+	infos := make([]*CallInfo, len(q.config.Nodes))
+	votes := make(chan quorumVote, len(q.config.Nodes))
+
+	for idx, client := range q.config.Nodes {
+		responses[idx].Host = client.config.Host
+
+		var nodeCtx context.Context
+		nodeCtx, infos[idx] = forkCallInfo(ctx)
+
+		go func(idx int, client *Client) {
+			res := &responses[idx]
+			res.Err = client.SendReqContext(nodeCtx, method, &res.Result, params...)
+			votes <- quorumVote{idx: idx, key: q.voteKey(method, res)}
+		}(idx, client)
+	}
+
+	counts := map[string]int{}
+	agreed := 0
+
+	for range q.config.Nodes {
+		vote := <-votes
+		if vote.key == "" {
+			continue
+		}
+
+		counts[vote.key]++
+		if counts[vote.key] > agreed {
+			agreed = counts[vote.key]
+		}
+
+		if counts[vote.key] < required {
+			continue
+		}
+
+		joinCallInfo(ctx, infos[vote.idx])
+
+		res := responses[vote.idx]
+		if res.Err != nil {
+			return res.Err
+		}
+
+		if result == nil {
+			return nil
+		}
+
+		return json.Unmarshal(res.Result, result)
+	}
+
+	return &QuorumError{
+		Method:    method,
+		Required:  required,
+		Agreed:    agreed,
+		Responses: responses,
+	}
+}
+
+// voteKey returns the key under which the response is counted, or "" if the node could not be reached. Errors returned
+// by the node are keyed by their code.
+func (q *QuorumClient) voteKey(method string, res *QuorumResponse) string {
+	if res.Err != nil {
+		var rpcErr *RPCError
+		if errors.As(res.Err, &rpcErr) && rpcErr.Code != rpcInWarmup {
+			return fmt.Sprintf("error %v", rpcErr.Code)
+		}
+
+		return ""
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(res.Result))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		res.Err = err
+		return ""
+	}
+
+	normalize := q.config.Normalize
+	if normalize == nil {
+		normalize = DefaultNormalize
+	}
+
+	// Maps are encoded with sorted keys, so equal values have equal keys.
+	key, err := json.Marshal(normalize(method, value))
+	if err != nil {
+		res.Err = err
+		return ""
+	}
+
+	return "result " + string(key)
+}
@@ -0,0 +1,110 @@
+package rpcclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quorumNode returns a client for a node which answers getblockhash with hash, and getrawtransaction with a
+// transaction with confirmations.
+func quorumNode(t *testing.T, hash string, confirmations int) *Client {
+	return newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		switch req.Method {
+		case "getblockhash":
+			return hash, nil
+		case "getrawtransaction":
+			if confirmations < 0 {
+				return nil, &RPCError{Code: -5, Message: "No such mempool or blockchain transaction."}
+			}
+
+			return map[string]any{"txid": req.Params[0], "blockhash": hash, "confirmations": confirmations}, nil
+		}
+
+		return nil, &RPCError{Code: -32601, Message: "Method not found"}
+	})
+}
+
+func TestQuorumClient(t *testing.T) {
+	q, err := NewQuorumClient(&QuorumConfig{
+		Nodes:    []*Client{quorumNode(t, "aa", 6), quorumNode(t, "bb", 1), quorumNode(t, "aa", 7), downClient(t)},
+		Required: 2,
+	})
+	require.NoError(t, err)
+
+	hash, err := q.GetBlockHash(1)
+	require.NoError(t, err)
+	assert.Equal(t, "aa", hash)
+
+	tx, err := q.GetRawTransactionVerbose("ff", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "aa", tx.Blockhash)
+
+	_, err = q.SendRawTransaction("00", nil)
+	assert.ErrorIs(t, err, ErrQuorumMutating)
+
+	_, err = NewQuorumClient(&QuorumConfig{Nodes: []*Client{downClient(t)}, Required: 2})
+	assert.Error(t, err)
+}
+
+func TestQuorumClient_Disagreement(t *testing.T) {
+	q, err := NewQuorumClient(&QuorumConfig{
+		Nodes:    []*Client{quorumNode(t, "aa", 1), quorumNode(t, "bb", 1), downClient(t)},
+		Required: 2,
+	})
+	require.NoError(t, err)
+
+	_, err = q.GetBlockHash(1)
+
+	var quorumErr *QuorumError
+	require.True(t, errors.As(err, &quorumErr))
+	assert.Equal(t, "getblockhash", quorumErr.Method)
+	assert.Equal(t, 2, quorumErr.Required)
+	assert.Equal(t, 1, quorumErr.Agreed)
+	require.Len(t, quorumErr.Responses, 3)
+	assert.JSONEq(t, `"aa"`, string(quorumErr.Responses[0].Result))
+	assert.JSONEq(t, `"bb"`, string(quorumErr.Responses[1].Result))
+	assert.Error(t, quorumErr.Responses[2].Err)
+	assert.Contains(t, err.Error(), "1 of 2 required nodes agree")
+}
+
+func TestQuorumClient_ErrorQuorum(t *testing.T) {
+	q, err := NewQuorumClient(&QuorumConfig{
+		Nodes: []*Client{quorumNode(t, "aa", -1), quorumNode(t, "aa", 3), quorumNode(t, "aa", -1)},
+		Normalize: func(method string, result any) any {
+			return result
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = q.GetRawTransactionVerbose("ff", nil)
+
+	var rpcErr *RPCError
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -5, rpcErr.Code)
+}
+
+func TestQuorumClient_Stream(t *testing.T) {
+	node := func() *Client {
+		return newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+			return map[string]any{"aa": map[string]any{"vsize": 100}, "bb": map[string]any{"vsize": 200}}, nil
+		})
+	}
+
+	q, err := NewQuorumClient(&QuorumConfig{Nodes: []*Client{node(), node(), node()}, Required: 2})
+	require.NoError(t, err)
+
+	// The callback only runs for the agreed result, and its error is returned as is.
+	stop := errors.New("stop")
+	calls := 0
+
+	err = q.IterRawMempoolVerbose(func(txid string, entry *types.MempoolTransaction) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
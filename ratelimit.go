@@ -0,0 +1,281 @@
+package rpcclient
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Priority is the priority class of a request. Requests which wait for Config.MaxConcurrentRequests or Config.RateLimit
+// are sent in order of priority, and in the order they were made within a priority.
+type Priority int
+
+// Priority classes of requests.
+const (
+	// PriorityLow is for background work, like indexing, which may wait for all other requests.
+	PriorityLow Priority = -1
+	// PriorityNormal is the priority of requests, unless set otherwise.
+	PriorityNormal Priority = 0
+	// PriorityHigh is for latency-sensitive requests, like broadcasting transactions, which jump the queue.
+	PriorityHigh Priority = 1
+)
+
+// priorityKey is the context key for the priority added with WithPriority.
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx with which requests are sent with priority, instead of the priority of their
+// method. See Config.MethodPriorities.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// priority returns the priority of a request for method sent with ctx.
+func (c *Client) priority(ctx context.Context, method string) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+
+	if priority, ok := c.config.MethodPriorities[method]; ok {
+		return priority
+	}
+
+	if highPriorityMethods[method] {
+		return PriorityHigh
+	}
+
+	return PriorityNormal
+}
+
+// cost returns the number of tokens of Config.RateLimit a request for method takes.
+func (c *Client) cost(method string) float64 {
+	if cost, ok := c.config.MethodCosts[method]; ok {
+		return cost
+	}
+
+	if cost, ok := methodCosts[method]; ok {
+		return cost
+	}
+
+	return 1
+}
+
+// limiter queues requests until a slot for concurrent requests is free, and the token bucket holds enough tokens
+// for them. Waiting requests are admitted strictly in order of priority, so a request waiting for tokens holds up the
+// requests of a lower priority behind it.
+type limiter struct {
+	mu sync.Mutex
+
+	// maxInFlight is the maximum number of requests in flight, or 0 if there is no limit.
+	maxInFlight int
+	inFlight    int
+
+	// rate is the number of tokens added to the bucket per second, or 0 if there is no limit. The bucket holds at
+	// most burst tokens.
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	waiters waiterQueue
+	seq     uint64
+
+	// timer admits the next waiter once the bucket holds enough tokens for it.
+	timer *time.Timer
+}
+
+// waiter is a request waiting in a limiter.
+type waiter struct {
+	priority Priority
+	seq      uint64
+	cost     float64
+
+	// ready is closed when the request is admitted.
+	ready chan struct{}
+
+	// index is the index of the waiter in the queue, or -1 when it has been admitted.
+	index int
+}
+
+// newLimiter creates a new *limiter for the limits in config, or returns nil if config has no limits.
+func newLimiter(config *Config) *limiter {
+	if config.MaxConcurrentRequests <= 0 && config.RateLimit <= 0 {
+		return nil
+	}
+
+	l := &limiter{
+		maxInFlight: max(config.MaxConcurrentRequests, 0),
+		last:        time.Now(),
+	}
+
+	if config.RateLimit > 0 {
+		l.rate = config.RateLimit
+
+		l.burst = float64(config.RateBurst)
+		if l.burst <= 0 {
+			l.burst = math.Max(1, math.Ceil(config.RateLimit))
+		}
+
+		l.tokens = l.burst
+	}
+
+	return l
+}
+
+// acquire waits until the request may be sent, and takes cost tokens from the bucket. Requests which cost more than
+// the bucket holds take all of its tokens. release must be called when the request is done, if acquire succeeded.
+func (l *limiter) acquire(ctx context.Context, priority Priority, cost float64) error {
+	l.mu.Lock()
+
+	if l.rate > 0 {
+		cost = math.Min(cost, l.burst)
+	} else {
+		cost = 0
+	}
+
+	l.refill()
+
+	if len(l.waiters) == 0 && l.admissible(cost) {
+		l.admit(cost)
+		l.mu.Unlock()
+
+		return nil
+	}
+
+	w := &waiter{
+		priority: priority,
+		seq:      l.seq,
+		cost:     cost,
+		ready:    make(chan struct{}),
+	}
+	l.seq++
+
+	heap.Push(&l.waiters, w)
+	l.dispatch()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w.index < 0 {
+		// The request was admitted while it was being cancelled.
+		l.inFlight--
+	} else {
+		heap.Remove(&l.waiters, w.index)
+	}
+
+	l.dispatch()
+
+	return ctx.Err()
+}
+
+// release frees the slot of a request admitted with acquire.
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	l.dispatch()
+}
+
+// refill adds the tokens for the time since the last refill to the bucket.
+func (l *limiter) refill() {
+	now := time.Now()
+
+	if l.rate > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+
+	l.last = now
+}
+
+// admissible reports whether a request of cost can be admitted now.
+func (l *limiter) admissible(cost float64) bool {
+	return (l.maxInFlight == 0 || l.inFlight < l.maxInFlight) && l.tokens >= cost
+}
+
+// admit takes a slot and cost tokens for a request.
+func (l *limiter) admit(cost float64) {
+	l.inFlight++
+	l.tokens -= cost
+}
+
+// dispatch admits waiters in order until the next one has to wait, and starts a timer if it waits for tokens. It must
+// be called with l.mu held.
+func (l *limiter) dispatch() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+
+	l.refill()
+
+	for len(l.waiters) > 0 {
+		next := l.waiters[0]
+		if !l.admissible(next.cost) {
+			if l.maxInFlight == 0 || l.inFlight < l.maxInFlight {
+				// Only tokens are missing. A free slot is waited for by release.
+				wait := time.Duration((next.cost - l.tokens) / l.rate * float64(time.Second))
+				l.timer = time.AfterFunc(wait, func() {
+					l.mu.Lock()
+					defer l.mu.Unlock()
+
+					l.dispatch()
+				})
+			}
+
+			return
+		}
+
+		heap.Pop(&l.waiters)
+		l.admit(next.cost)
+		close(next.ready)
+	}
+}
+
+// waiterQueue is a heap of waiters, ordered by priority and then by the order in which they started waiting.
+type waiterQueue []*waiter
+
+// Len implements heap.Interface.
+func (q waiterQueue) Len() int { return len(q) }
+
+// Less implements heap.Interface.
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+
+	return q[i].seq < q[j].seq
+}
+
+// Swap implements heap.Interface.
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+// Push implements heap.Interface.
+func (q *waiterQueue) Push(x any) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+// Pop implements heap.Interface.
+func (q *waiterQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+
+	return w
+}
@@ -0,0 +1,120 @@
+package rpcclient
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitQueued waits until n requests are waiting in l.
+func waitQueued(t *testing.T, l *limiter, n int) {
+	require.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		return len(l.waiters) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestLimiter_Priority(t *testing.T) {
+	l := newLimiter(&Config{MaxConcurrentRequests: 1})
+	require.NoError(t, l.acquire(context.Background(), PriorityNormal, 1))
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+
+	queue := func(name string, priority Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, l.acquire(context.Background(), priority, 1))
+
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+
+			l.release()
+		}()
+	}
+
+	queue("low", PriorityLow)
+	waitQueued(t, l, 1)
+	queue("normal 1", PriorityNormal)
+	waitQueued(t, l, 2)
+	queue("normal 2", PriorityNormal)
+	waitQueued(t, l, 3)
+	queue("high", PriorityHigh)
+	waitQueued(t, l, 4)
+
+	// A cancelled request leaves the queue.
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() { errs <- l.acquire(ctx, PriorityHigh, 1) }()
+	waitQueued(t, l, 5)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+
+	l.release()
+	wg.Wait()
+
+	assert.Equal(t, []string{"high", "normal 1", "normal 2", "low"}, order)
+	assert.Zero(t, l.inFlight)
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	client := newTestClient(t, &Config{
+		RateLimit:   50,
+		RateBurst:   2,
+		MethodCosts: map[string]float64{"getblockhash": 2},
+	}, func(req *Request) (any, *RPCError) {
+		if req.Method == "getblockhash" {
+			return "00ff", nil
+		}
+
+		return 1, nil
+	})
+
+	// The burst is sent at once, and every request after it waits 20ms for a token.
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := client.GetBlockCount()
+		require.NoError(t, err)
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 55*time.Millisecond)
+
+	// getblockhash costs 2 tokens, so waits 40ms.
+	start = time.Now()
+	_, err := client.GetBlockHash(1)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+
+	// Requests waiting for tokens give up when their context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	_, err = client.WithContext(ctx).GetBlockStats("00ff")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_Priority(t *testing.T) {
+	client := newTestClient(t, &Config{
+		MethodPriorities: map[string]Priority{"getblockcount": PriorityLow},
+	}, nil)
+
+	ctx := context.Background()
+	assert.Equal(t, PriorityLow, client.priority(ctx, "getblockcount"))
+	assert.Equal(t, PriorityNormal, client.priority(ctx, "getblockhash"))
+	assert.Equal(t, PriorityHigh, client.priority(ctx, "sendrawtransaction"))
+	assert.Equal(t, PriorityNormal, client.priority(WithPriority(ctx, PriorityNormal), "sendrawtransaction"))
+
+	assert.Equal(t, float64(1), client.cost("getblockcount"))
+	assert.Equal(t, float64(50), client.cost("scantxoutset"))
+}
@@ -0,0 +1,366 @@
+// Package regtest spins up isolated bitcoind or litecoind regtest nodes for integration tests.
+package regtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core"
+)
+
+// rpcInWarmup is the RPC error code returned while the node is still starting up.
+const rpcInWarmup = -28
+
+// walletName is the name of the wallet created on every node, used for addresses and funding.
+const walletName = "regtest"
+
+// coinbaseMaturity is the number of blocks that have to be mined on top of a coinbase before it can be spent.
+const coinbaseMaturity = 100
+
+// maxFundingBlocks is the maximum number of blocks FundAndConfirm mines to get enough mature coins. The block subsidy
+// of regtest halves every 150 blocks, so mining more rarely helps.
+const maxFundingBlocks = 10 * (coinbaseMaturity + 1)
+
+// Config are the options for starting a regtest node.
+type Config struct {
+	// Binary is the path to the bitcoind or litecoind binary.
+	Binary string
+
+	// DataDir is the directory the node stores its data in. If empty, a temporary directory is created and removed
+	// again on Close.
+	DataDir string
+
+	// Args are extra arguments passed to the node, eg "-txindex=1".
+	Args []string
+
+	// StartupTimeout is how long to wait for the RPC server to finish warming up. Defaults to 30 seconds.
+	StartupTimeout time.Duration
+}
+
+// Node is a running regtest node.
+type Node struct {
+	// Client is connected to the node's RPC server using cookie authentication.
+	Client *rpcclient.Client
+
+	// DataDir is the directory the node stores its data in.
+	DataDir string
+
+	// RPCPort is the port the RPC server listens on.
+	RPCPort int
+
+	cmd        *exec.Cmd
+	exited     chan struct{}
+	removeDir  bool
+	walletInit bool
+}
+
+// Start starts a regtest node as configured and waits for its RPC server to be ready.
+func Start(config *Config) (*Node, error) {
+	if _, err := exec.LookPath(config.Binary); err != nil {
+		return nil, err
+	}
+
+	node := &Node{
+		DataDir: config.DataDir,
+		exited:  make(chan struct{}),
+	}
+
+	if node.DataDir == "" {
+		dir, err := os.MkdirTemp("", "rpcclient-regtest-")
+		if err != nil {
+			return nil, err
+		}
+
+		node.DataDir = dir
+		node.removeDir = true
+	}
+
+	rpcPort, err := freePort()
+	if err != nil {
+		node.cleanup()
+		return nil, err
+	}
+
+	p2pPort, err := freePort()
+	if err != nil {
+		node.cleanup()
+		return nil, err
+	}
+
+	node.RPCPort = rpcPort
+
+	args := []string{
+		"-regtest",
+		"-server",
+		"-listen=0",
+		"-printtoconsole=0",
+		"-fallbackfee=0.0002",
+		"-datadir=" + node.DataDir,
+		"-rpcbind=127.0.0.1",
+		"-rpcallowip=127.0.0.1",
+		"-rpcport=" + strconv.Itoa(rpcPort),
+		"-port=" + strconv.Itoa(p2pPort),
+	}
+	args = append(args, config.Args...)
+
+	node.cmd = exec.Command(config.Binary, args...)
+	if err := node.cmd.Start(); err != nil {
+		node.cleanup()
+		return nil, err
+	}
+
+	go func() {
+		_ = node.cmd.Wait()
+		close(node.exited)
+	}()
+
+	timeout := config.StartupTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	if err := node.waitReady(timeout); err != nil {
+		_ = node.cmd.Process.Kill()
+		<-node.exited
+		node.cleanup()
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// New starts a regtest node for the test using the binary at path. The test is skipped if path is empty or the binary
+// cannot be found, and the node is stopped when the test finishes.
+func New(t testing.TB, binary string, args ...string) *Node {
+	t.Helper()
+
+	if binary == "" {
+		t.Skip("regtest: no node binary configured")
+	}
+
+	if _, err := exec.LookPath(binary); err != nil {
+		t.Skipf("regtest: node binary not found: %v", err)
+	}
+
+	node, err := Start(&Config{
+		Binary:  binary,
+		DataDir: t.TempDir(),
+		Args:    args,
+	})
+	if err != nil {
+		t.Fatalf("regtest: starting node: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := node.Close(); err != nil {
+			t.Errorf("regtest: stopping node: %v", err)
+		}
+	})
+
+	return node
+}
+
+// Close stops the node with the stop RPC and waits for the process to exit. The node is killed if it has not exited
+// within 30 seconds.
+func (n *Node) Close() error {
+	defer n.cleanup()
+
+	select {
+	case <-n.exited:
+		return nil
+	default:
+	}
+
+	// The deadline also bounds the stop request, so a node which never answers is killed too.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := n.Client.SendReqContext(ctx, "stop", new(string))
+
+	select {
+	case <-n.exited:
+		return nil
+	case <-ctx.Done():
+		_ = n.cmd.Process.Kill()
+		<-n.exited
+		if err != nil {
+			return err
+		}
+
+		return errors.New("regtest: node did not shut down in time")
+	}
+}
+
+// NewAddress returns a new address from the node's wallet.
+func (n *Node) NewAddress() (string, error) {
+	if err := n.ensureWallet(); err != nil {
+		return "", err
+	}
+
+	var address string
+
+	return address, n.Client.SendReq("getnewaddress", &address)
+}
+
+// Mine mines nblocks blocks to a new address and returns their hashes.
+func (n *Node) Mine(nblocks int) ([]string, error) {
+	address, err := n.NewAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	return n.Client.GenerateToAddress(nblocks, address, 0)
+}
+
+// FundAndConfirm sends amount to address from the node's wallet and mines a block to confirm it.
+// Blocks are mined first if the wallet does not have enough mature coins, up to maxFundingBlocks. Returns the txid of
+// the funding transaction.
+func (n *Node) FundAndConfirm(address string, amount float64) (string, error) {
+	if err := n.ensureWallet(); err != nil {
+		return "", err
+	}
+
+	for mined := 0; ; mined += coinbaseMaturity + 1 {
+		var balance float64
+		if err := n.Client.SendReq("getbalance", &balance); err != nil {
+			return "", err
+		}
+
+		if balance > amount {
+			break
+		}
+
+		if mined >= maxFundingBlocks {
+			return "", fmt.Errorf("regtest: balance %v is not above %v after mining %v blocks", balance, amount, mined)
+		}
+
+		if _, err := n.Mine(coinbaseMaturity + 1); err != nil {
+			return "", err
+		}
+	}
+
+	var txid string
+	if err := n.Client.SendReq("sendtoaddress", &txid, address, amount); err != nil {
+		return "", err
+	}
+
+	if _, err := n.Mine(1); err != nil {
+		return "", err
+	}
+
+	return txid, nil
+}
+
+// waitReady waits until the cookie file has been written and the RPC server has finished warming up.
+func (n *Node) waitReady(timeout time.Duration) error {
+	// The deadline also bounds the requests, so a node which accepts connections but never answers can't hang.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-n.exited:
+			return fmt.Errorf("regtest: node exited during startup: %v", n.cmd.ProcessState)
+		case <-ctx.Done():
+			return fmt.Errorf("regtest: node not ready after %v: %w", timeout, lastErr)
+		case <-ticker.C:
+		}
+
+		if n.Client == nil {
+			client, err := n.connect()
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			n.Client = client
+		}
+
+		var count int64
+
+		err := n.Client.SendReqContext(ctx, "getblockcount", &count)
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+
+		var rpcErr *rpcclient.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code != rpcInWarmup {
+			return err
+		}
+	}
+}
+
+// connect reads the cookie file and creates a client for the node.
+func (n *Node) connect() (*rpcclient.Client, error) {
+	cookie, err := os.ReadFile(filepath.Join(n.DataDir, "regtest", ".cookie"))
+	if err != nil {
+		return nil, err
+	}
+
+	user, pass, ok := strings.Cut(strings.TrimSpace(string(cookie)), ":")
+	if !ok {
+		return nil, errors.New("regtest: malformed cookie file")
+	}
+
+	return rpcclient.New(&rpcclient.Config{
+		Host:       "http://127.0.0.1:" + strconv.Itoa(n.RPCPort),
+		User:       user,
+		Pass:       pass,
+		DisableTLS: true,
+	})
+}
+
+// ensureWallet creates the wallet used for addresses and funding, unless it has already been created or loaded.
+func (n *Node) ensureWallet() error {
+	if n.walletInit {
+		return nil
+	}
+
+	var wallets []string
+	if err := n.Client.SendReq("listwallets", &wallets); err != nil {
+		return err
+	}
+
+	if len(wallets) == 0 {
+		if err := n.Client.SendReq("createwallet", new(map[string]any), walletName); err != nil {
+			return err
+		}
+	}
+
+	n.walletInit = true
+
+	return nil
+}
+
+// cleanup removes the data directory if it was created by Start.
+func (n *Node) cleanup() {
+	if n.removeDir {
+		_ = os.RemoveAll(n.DataDir)
+	}
+}
+
+// freePort asks the kernel for a free TCP port on the loopback interface.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
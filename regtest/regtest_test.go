@@ -0,0 +1,63 @@
+package regtest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The node binary used by these tests is read from RPCCLIENT_NODE_BINARY, eg /usr/local/bin/bitcoind.
+func TestNode_FundAndConfirm(t *testing.T) {
+	node := New(t, os.Getenv("RPCCLIENT_NODE_BINARY"), "-txindex=1")
+
+	hashes, err := node.Mine(5)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 5)
+
+	address, err := node.NewAddress()
+	require.NoError(t, err)
+
+	txid, err := node.FundAndConfirm(address, 1.5)
+	require.NoError(t, err)
+
+	tx, err := node.Client.GetRawTransactionVerbose(txid, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tx.Confirmations)
+}
+
+func TestNode_FundAndConfirm_NoFunds(t *testing.T) {
+	var mined int
+
+	// A node whose wallet never gets a balance, eg because the coinbases pay elsewhere.
+	client := rpcclient.NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		var res any
+
+		switch method {
+		case "getbalance":
+			res = 0
+		case "getnewaddress":
+			res = "bcrt1q"
+		case "generatetoaddress":
+			mined += params[0].(int)
+			res = []string{}
+		default:
+			return &rpcclient.RPCError{Code: -32601, Message: "Method not found"}
+		}
+
+		data, err := json.Marshal(res)
+		require.NoError(t, err)
+
+		return json.Unmarshal(data, result)
+	})
+
+	node := &Node{Client: client, walletInit: true}
+
+	_, err := node.FundAndConfirm("bcrt1q", 1)
+	assert.EqualError(t, err, "regtest: balance 0 is not above 1 after mining 1010 blocks")
+	assert.Equal(t, maxFundingBlocks, mined)
+}
@@ -0,0 +1,138 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the limit set by Config.MaxResponseSize or
+// Config.MaxResponseSizes.
+var ErrResponseTooLarge = errors.New("rpcclient: response too large")
+
+// Defaults for the retry options in Config.
+const (
+	defaultRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff     = 5 * time.Second
+)
+
+// maxSnippetSize is the maximum number of bytes of the body included in an HTTPError.
+const maxSnippetSize = 512
+
+// HTTPError is returned when the server responds with something other than JSON, like the HTML error page of a proxy
+// or the empty body of a failed authentication.
+type HTTPError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// ContentType is the Content-Type header of the response.
+	ContentType string
+	// Body holds the start of the body, truncated to 512 bytes.
+	Body string
+}
+
+// Error implements the error interface.
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("rpcclient: unexpected HTTP response: %v %v", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.ContentType != "" {
+		msg += fmt.Sprintf(" (%v)", e.ContentType)
+	}
+
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+
+	return msg
+}
+
+// checkResponse returns an *HTTPError if the response isn't JSON. Bitcoind answers RPC errors with a status of 404 or
+// 500 and a JSON body, so the status is not checked.
+func checkResponse(res *http.Response) error {
+	contentType := res.Header.Get("Content-Type")
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, maxSnippetSize))
+
+	return &HTTPError{
+		StatusCode:  res.StatusCode,
+		ContentType: contentType,
+		Body:        strings.TrimSpace(string(snippet)),
+	}
+}
+
+// isRetryable reports whether a request which failed with err may succeed when it is sent again: when the server
+// can't be reached, is busy with a full work queue, or is still starting up.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrResponseTooLarge) {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusServiceUnavailable
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == rpcInWarmup
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryBackoff returns the time to wait before the first retry.
+func (c *Client) retryBackoff() time.Duration {
+	if c.config.RetryBackoff > 0 {
+		return c.config.RetryBackoff
+	}
+
+	return defaultRetryBackoff
+}
+
+// maxResponseSize returns the limit on the size of the response for method, or 0 if there is no limit.
+func (c *Client) maxResponseSize(method string) int64 {
+	if size, ok := c.config.MaxResponseSizes[method]; ok {
+		return size
+	}
+
+	return c.config.MaxResponseSize
+}
+
+// limitedReader reads from r until more than n bytes have been read, and fails with ErrResponseTooLarge after that.
+// Unlike io.LimitReader, a body which is too large is an error instead of being truncated.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrResponseTooLarge
+	}
+
+	// Read one byte past the limit, to tell a body of exactly n bytes from a larger one.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+
+	if l.n < 0 {
+		return n, ErrResponseTooLarge
+	}
+
+	return n, err
+}
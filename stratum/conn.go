@@ -0,0 +1,361 @@
+package stratum
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxMessageSize is the maximum size of a message from a miner.
+const maxMessageSize = 16 << 10
+
+// writeTimeout is the maximum amount of time writing a message to a miner may take.
+const writeTimeout = 10 * time.Second
+
+// minRetargetChange is the smallest relative change of the difficulty made by vardiff, so miners aren't sent a new
+// difficulty for every small fluctuation in their hash rate.
+const minRetargetChange = 0.1
+
+// Error codes of Stratum v1.
+const (
+	errOther         = 20
+	errJobNotFound   = 21
+	errDuplicate     = 22
+	errLowDifficulty = 23
+	errUnauthorized  = 24
+	errNotSubscribed = 25
+)
+
+// stratumError is an error returned to a miner, which is sent as [code, message, null].
+type stratumError struct {
+	code    int
+	message string
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{e.code, e.message, nil})
+}
+
+// request is a message from a miner.
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// response is the response to a request.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result any             `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+// notification is a message from the server which isn't a response.
+type notification struct {
+	ID     any    `json:"id"`
+	Method string `json:"method"`
+	Params any    `json:"params"`
+}
+
+// conn is a connection from a miner.
+type conn struct {
+	server      *Server
+	netConn     net.Conn
+	extraNonce1 []byte
+
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	subscribed bool
+	authorized map[string]bool
+
+	// difficulty is the current share difficulty. Shares at prevDifficulty are accepted until the next retarget,
+	// since miners may still be working on jobs from before the last change.
+	difficulty     float64
+	prevDifficulty float64
+
+	// shares is the number of shares accepted since retargetAt.
+	shares     int
+	retargetAt time.Time
+}
+
+// serve reads and handles the requests of the miner until the connection is closed.
+func (c *conn) serve() {
+	defer c.server.removeConn(c)
+	defer c.netConn.Close()
+
+	c.server.log(slog.LevelDebug, "miner connected", slog.String("remote", c.netConn.RemoteAddr().String()))
+
+	scanner := bufio.NewScanner(c.netConn)
+	scanner.Buffer(make([]byte, 0, 1024), maxMessageSize)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		req := &request{}
+		if err := json.Unmarshal(scanner.Bytes(), req); err != nil {
+			c.server.log(slog.LevelDebug, "invalid message", slog.String("remote", c.netConn.RemoteAddr().String()))
+			return
+		}
+
+		c.handle(req)
+	}
+}
+
+// handle handles a request and sends the response.
+func (c *conn) handle(req *request) {
+	var (
+		result any
+		err    *stratumError
+	)
+
+	switch req.Method {
+	case "mining.subscribe":
+		result = c.subscribe()
+	case "mining.authorize":
+		result, err = c.authorize(req.Params)
+	case "mining.submit":
+		result, err = c.submit(req.Params)
+	case "mining.suggest_difficulty":
+		result, err = c.suggestDifficulty(req.Params)
+	case "mining.configure":
+		// No extensions, like version rolling, are supported.
+		result = map[string]any{}
+	case "mining.extranonce.subscribe":
+		// The extranonce of a connection never changes.
+		result = true
+	default:
+		err = &stratumError{code: errOther, message: "Unknown method"}
+	}
+
+	c.write(&response{ID: req.ID, Result: result, Error: err})
+
+	if req.Method == "mining.subscribe" {
+		c.sendDifficulty()
+
+		if j := c.server.currentJob(); j != nil {
+			c.notify(j)
+		}
+	}
+}
+
+// subscribe handles mining.subscribe.
+func (c *conn) subscribe() any {
+	c.mu.Lock()
+	c.subscribed = true
+	c.mu.Unlock()
+
+	id := hex.EncodeToString(c.extraNonce1)
+
+	return []any{
+		[][]string{{"mining.set_difficulty", id}, {"mining.notify", id}},
+		id,
+		extraNonce2Size,
+	}
+}
+
+// authorize handles mining.authorize.
+func (c *conn) authorize(params []json.RawMessage) (any, *stratumError) {
+	var user, password string
+	if !parseParams(params, &user) || user == "" {
+		return nil, &stratumError{code: errOther, message: "Invalid params"}
+	}
+
+	// Some miners leave out the password.
+	if len(params) > 1 {
+		_ = json.Unmarshal(params[1], &password)
+	}
+
+	if c.server.config.Authorize != nil && !c.server.config.Authorize(user, password) {
+		c.server.log(slog.LevelInfo, "worker not authorized", slog.String("worker", user))
+		return nil, &stratumError{code: errUnauthorized, message: "Unauthorized worker"}
+	}
+
+	c.mu.Lock()
+	c.authorized[user] = true
+	c.mu.Unlock()
+
+	return true, nil
+}
+
+// submit handles mining.submit, and submits the block if the share meets the target of the network.
+func (c *conn) submit(params []json.RawMessage) (any, *stratumError) {
+	var worker, jobID, extraNonce2Hex, ntimeHex, nonceHex string
+	if !parseParams(params, &worker, &jobID, &extraNonce2Hex, &ntimeHex, &nonceHex) {
+		return nil, &stratumError{code: errOther, message: "Invalid params"}
+	}
+
+	c.mu.Lock()
+	subscribed, authorized := c.subscribed, c.authorized[worker]
+	difficulty := min(c.difficulty, c.prevDifficulty)
+	c.mu.Unlock()
+
+	switch {
+	case !subscribed:
+		return nil, &stratumError{code: errNotSubscribed, message: "Not subscribed"}
+	case !authorized:
+		return nil, &stratumError{code: errUnauthorized, message: "Unauthorized worker"}
+	}
+
+	j := c.server.lookupJob(jobID)
+	if j == nil {
+		return nil, c.reject(worker, &stratumError{code: errJobNotFound, message: "Job not found"})
+	}
+
+	extraNonce2, err := hex.DecodeString(extraNonce2Hex)
+	if err != nil || len(extraNonce2) != extraNonce2Size {
+		return nil, c.reject(worker, &stratumError{code: errOther, message: "Invalid extranonce2"})
+	}
+
+	ntime, err := strconv.ParseUint(ntimeHex, 16, 32)
+	if err != nil || int(ntime) < j.work.Template.MinTime || int64(ntime) > time.Now().Add(maxFutureTime).Unix() {
+		return nil, c.reject(worker, &stratumError{code: errOther, message: "Invalid ntime"})
+	}
+
+	nonce, err := strconv.ParseUint(nonceHex, 16, 32)
+	if err != nil {
+		return nil, c.reject(worker, &stratumError{code: errOther, message: "Invalid nonce"})
+	}
+
+	extraNonce := append(append([]byte(nil), c.extraNonce1...), extraNonce2...)
+
+	block, err := j.work.Block(extraNonce, uint32(ntime), uint32(nonce))
+	if err != nil {
+		return nil, c.reject(worker, &stratumError{code: errOther, message: err.Error()})
+	}
+
+	if !block.MeetsTarget(shareTarget(difficulty)) {
+		return nil, c.reject(worker, &stratumError{code: errLowDifficulty, message: "Low difficulty share"})
+	}
+
+	if !c.server.recordShare(j, fmt.Sprintf("%x:%08x:%08x", extraNonce, ntime, nonce)) {
+		return nil, c.reject(worker, &stratumError{code: errDuplicate, message: "Duplicate share"})
+	}
+
+	c.mu.Lock()
+	c.shares++
+	c.mu.Unlock()
+
+	if block.CheckProofOfWork() {
+		c.server.submitBlock(worker, block)
+	}
+
+	return true, nil
+}
+
+// reject logs a rejected share and returns err.
+func (c *conn) reject(worker string, err *stratumError) *stratumError {
+	c.server.log(slog.LevelDebug, "share rejected", slog.String("worker", worker), slog.String("reason", err.message))
+
+	return err
+}
+
+// suggestDifficulty handles mining.suggest_difficulty.
+func (c *conn) suggestDifficulty(params []json.RawMessage) (any, *stratumError) {
+	var difficulty float64
+	if !parseParams(params, &difficulty) {
+		return nil, &stratumError{code: errOther, message: "Invalid params"}
+	}
+
+	c.mu.Lock()
+	c.prevDifficulty = c.difficulty
+	c.difficulty = c.server.clampDifficulty(difficulty)
+	c.mu.Unlock()
+
+	c.sendDifficulty()
+
+	return true, nil
+}
+
+// retarget adjusts the difficulty of the connection, so the miner submits a share about every interval.
+func (c *conn) retarget(interval time.Duration) {
+	c.mu.Lock()
+
+	now := time.Now()
+	factor := 1.0 / maxRetargetFactor
+
+	if c.shares > 0 {
+		actual := now.Sub(c.retargetAt) / time.Duration(c.shares)
+		factor = min(max(float64(interval)/float64(actual), 1.0/maxRetargetFactor), maxRetargetFactor)
+	}
+
+	difficulty := c.server.clampDifficulty(c.difficulty * factor)
+	changed := difficulty/c.difficulty > 1+minRetargetChange || difficulty/c.difficulty < 1-minRetargetChange
+
+	c.prevDifficulty = c.difficulty
+	if changed {
+		c.difficulty = difficulty
+	}
+
+	c.shares = 0
+	c.retargetAt = now
+	subscribed := c.subscribed
+
+	c.mu.Unlock()
+
+	if changed && subscribed {
+		c.sendDifficulty()
+	}
+}
+
+// sendDifficulty sends mining.set_difficulty with the difficulty of the connection.
+func (c *conn) sendDifficulty() {
+	c.mu.Lock()
+	difficulty := c.difficulty
+	c.mu.Unlock()
+
+	c.write(&notification{Method: "mining.set_difficulty", Params: []any{difficulty}})
+}
+
+// notify sends j to the miner with mining.notify, if it is subscribed.
+func (c *conn) notify(j *job) {
+	c.mu.Lock()
+	subscribed := c.subscribed
+	c.mu.Unlock()
+
+	if subscribed {
+		c.write(&notification{Method: "mining.notify", Params: j.params})
+	}
+}
+
+// write sends msg to the miner. The connection is closed if that fails.
+func (c *conn) write(msg any) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_ = c.netConn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	if _, err := c.netConn.Write(append(b, '\n')); err != nil {
+		c.netConn.Close()
+	}
+}
+
+// parseParams decodes params into dst in order, and reports whether there were enough params and all of them could be
+// decoded. Params beyond dst are ignored.
+func parseParams(params []json.RawMessage, dst ...any) bool {
+	if len(params) < len(dst) {
+		return false
+	}
+
+	for idx := range dst {
+		if err := json.Unmarshal(params[idx], dst[idx]); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,462 @@
+// Package stratum implements a small Stratum v1 pool server, which hands out work from the block templates of a node
+// to miners and submits the blocks they find.
+//
+//	server := stratum.NewServer(&stratum.Config{
+//		Source:       client,
+//		PayoutScript: script,
+//	})
+//
+//	err := server.ListenAndServe(ctx, ":3333")
+package stratum
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core/internal/wire"
+	"github.com/omarhachach/rpcclient-core/mining"
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// Sizes of the extranonce parts. The server assigns the first part to every connection, and miners vary the second.
+const (
+	extraNonce1Size = 4
+	extraNonce2Size = 4
+)
+
+// Defaults for the options in Config.
+const (
+	defaultDifficulty       = 1
+	defaultShareInterval    = 10 * time.Second
+	defaultRetargetInterval = 90 * time.Second
+)
+
+// maxJobs is the number of recent jobs shares are accepted for, as long as the previous block hasn't changed.
+const maxJobs = 16
+
+// maxFutureTime is how far the time in a share may be ahead of the clock of the server, like bitcoind allows for blocks.
+const maxFutureTime = 2 * time.Hour
+
+// maxRetargetFactor is the most the difficulty of a connection changes in one retarget.
+const maxRetargetFactor = 4
+
+// diff1Target is the target of a share of difficulty 1.
+var diff1Target = wire.CompactToBig(0x1d00ffff)
+
+// ErrNoPayoutScript is returned when Config.PayoutScript is empty.
+var ErrNoPayoutScript = errors.New("stratum: no payout script")
+
+// TemplateSource provides the block templates miners work on, and accepts the blocks they find. It is implemented by
+// *rpcclient.Client.
+type TemplateSource interface {
+	// SubscribeBlockTemplates calls fn with the current block template and every newer one, until ctx is done.
+	SubscribeBlockTemplates(ctx context.Context, req *types.BlockTemplateRequest, fn func(*types.BlockTemplate) error) error
+	// SubmitBlock submits a block found by a miner.
+	SubmitBlock(hexdata string) error
+}
+
+// Config are the options of a Server.
+type Config struct {
+	// Source provides the block templates and accepts found blocks.
+	Source TemplateSource
+
+	// PayoutScript is the scriptPubKey the coinbase pays to. See mining.Options.
+	PayoutScript []byte
+
+	// Tag is added to the script of the coinbase, eg to identify the pool.
+	Tag []byte
+
+	// Authorize checks the credentials of a worker. If nil, every worker is authorized.
+	Authorize func(user, password string) bool
+
+	// Difficulty is the initial share difficulty of a connection. Defaults to 1.
+	Difficulty float64
+
+	// MinDifficulty and MaxDifficulty bound the difficulty set by vardiff and requested by miners. Zero means no bound.
+	MinDifficulty float64
+	MaxDifficulty float64
+
+	// ShareInterval is the time between shares that vardiff aims for. Defaults to 10 seconds.
+	ShareInterval time.Duration
+
+	// RetargetInterval is how often vardiff adjusts the difficulty of every connection. Defaults to 90 seconds.
+	RetargetInterval time.Duration
+
+	// Logger logs connections, rejected shares and found blocks if set.
+	Logger *slog.Logger
+}
+
+// Server is a Stratum v1 pool server.
+type Server struct {
+	config *Config
+
+	mu sync.Mutex
+
+	// jobs are the recent jobs by id, and job is the latest one.
+	jobs      map[string]*job
+	job       *job
+	nextJobID uint64
+
+	conns           map[*conn]struct{}
+	nextExtraNonce1 uint32
+
+	// closed is set when Serve shuts down.
+	closed bool
+}
+
+// job is a unit of work sent to miners with mining.notify.
+type job struct {
+	id    string
+	work  *mining.Work
+	clean bool
+
+	// params are the params of the mining.notify of the job.
+	params []any
+
+	// shares are the shares submitted for the job, to reject duplicates.
+	shares map[string]bool
+}
+
+// NewServer creates a new *Server with config.
+func NewServer(config *Config) *Server {
+	return &Server{
+		config: config,
+		jobs:   map[string]*job{},
+		conns:  map[*conn]struct{}{},
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ctx, l)
+}
+
+// Serve follows the block templates of the source, and accepts connections from miners on l until ctx is done or the
+// template subscription fails, and returns the reason. l is closed when Serve returns.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	defer l.Close()
+
+	if len(s.config.PayoutScript) == 0 {
+		return ErrNoPayoutScript
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		cancel(s.config.Source.SubscribeBlockTemplates(ctx, nil, s.setTemplate))
+	}()
+
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		l.Close()
+		s.closeConns()
+	}()
+
+	go func() {
+		defer wg.Done()
+		s.retargetLoop(ctx)
+	}()
+
+	for {
+		netConn, err := l.Accept()
+		if err != nil {
+			cancel(err)
+			break
+		}
+
+		c := s.newConn(netConn)
+		if c == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.serve()
+		}()
+	}
+
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+// setTemplate makes a job of tmpl and sends it to all miners.
+func (s *Server) setTemplate(tmpl *types.BlockTemplate) error {
+	work, err := mining.NewWork(tmpl, &mining.Options{
+		PayoutScript:   s.config.PayoutScript,
+		ExtraNonceSize: extraNonce1Size + extraNonce2Size,
+		Tag:            s.config.Tag,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+
+	s.nextJobID++
+	j := &job{
+		id:     strconv.FormatUint(s.nextJobID, 16),
+		work:   work,
+		clean:  s.job == nil || s.job.work.Template.PreviousBlockhash != tmpl.PreviousBlockhash,
+		shares: map[string]bool{},
+	}
+	j.params = notifyParams(j)
+
+	// Shares for jobs on top of an old block can't become blocks anymore.
+	if j.clean {
+		s.jobs = map[string]*job{}
+	}
+
+	s.jobs[j.id] = j
+	s.job = j
+
+	if len(s.jobs) > maxJobs {
+		for id := range s.jobs {
+			if old, _ := strconv.ParseUint(id, 16, 64); old <= s.nextJobID-maxJobs {
+				delete(s.jobs, id)
+			}
+		}
+	}
+
+	conns := s.connList()
+	s.mu.Unlock()
+
+	s.log(slog.LevelDebug, "new job", slog.String("job", j.id), slog.Int("height", tmpl.Height), slog.Bool("clean", j.clean))
+
+	for _, c := range conns {
+		c.notify(j)
+	}
+
+	return nil
+}
+
+// notifyParams returns the params of the mining.notify of j.
+func notifyParams(j *job) []any {
+	tmpl := j.work.Template
+
+	coinb1, coinb2 := j.work.CoinbaseParts()
+
+	branch := []string{}
+	for _, hash := range j.work.MerkleBranch() {
+		branch = append(branch, hex.EncodeToString(hash))
+	}
+
+	return []any{
+		j.id,
+		stratumPrevHash(tmpl.PreviousBlockhash),
+		hex.EncodeToString(coinb1),
+		hex.EncodeToString(coinb2),
+		branch,
+		fmt.Sprintf("%08x", uint32(tmpl.Version)),
+		tmpl.Bits,
+		fmt.Sprintf("%08x", uint32(tmpl.CurTime)),
+		j.clean,
+	}
+}
+
+// stratumPrevHash returns the previous block hash as sent in mining.notify, which is the hash in internal byte order
+// with the bytes of every 4-byte word swapped.
+func stratumPrevHash(prevBlockHash string) string {
+	hash, err := wire.HashFromHex(prevBlockHash)
+	if err != nil {
+		return prevBlockHash
+	}
+
+	for idx := 0; idx < len(hash); idx += 4 {
+		hash[idx], hash[idx+1], hash[idx+2], hash[idx+3] = hash[idx+3], hash[idx+2], hash[idx+1], hash[idx]
+	}
+
+	return hex.EncodeToString(hash[:])
+}
+
+// currentJob returns the latest job, or nil if there is none yet.
+func (s *Server) currentJob() *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.job
+}
+
+// newConn registers a connection from a miner, with a unique extranonce1. It returns nil, and closes the connection,
+// if the server is shutting down.
+func (s *Server) newConn(netConn net.Conn) *conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		netConn.Close()
+		return nil
+	}
+
+	s.nextExtraNonce1++
+
+	extraNonce1 := make([]byte, extraNonce1Size)
+	for idx := range extraNonce1 {
+		extraNonce1[idx] = byte(s.nextExtraNonce1 >> (8 * (extraNonce1Size - 1 - idx)))
+	}
+
+	c := &conn{
+		server:      s,
+		netConn:     netConn,
+		extraNonce1: extraNonce1,
+		difficulty:  s.clampDifficulty(s.config.Difficulty),
+		authorized:  map[string]bool{},
+		retargetAt:  time.Now(),
+	}
+	c.prevDifficulty = c.difficulty
+
+	s.conns[c] = struct{}{}
+
+	return c
+}
+
+// removeConn unregisters a closed connection.
+func (s *Server) removeConn(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, c)
+}
+
+// connList returns the open connections. It must be called with s.mu held.
+func (s *Server) connList() []*conn {
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+
+	return conns
+}
+
+// closeConns closes all connections, and makes newConn reject new ones.
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	s.closed = true
+	conns := s.connList()
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.netConn.Close()
+	}
+}
+
+// lookupJob returns the job with id, or nil if it is unknown or stale.
+func (s *Server) lookupJob(id string) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.jobs[id]
+}
+
+// recordShare records a share for j, and reports whether it is new.
+func (s *Server) recordShare(j *job, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j.shares[key] {
+		return false
+	}
+
+	j.shares[key] = true
+
+	return true
+}
+
+// submitBlock submits a block found by a miner to the source.
+func (s *Server) submitBlock(worker string, block *mining.Block) {
+	err := s.config.Source.SubmitBlock(block.Hex())
+	if err != nil {
+		s.log(slog.LevelError, "block rejected", slog.String("worker", worker), slog.String("hash", block.Hash()), slog.String("error", err.Error()))
+		return
+	}
+
+	s.log(slog.LevelInfo, "block found", slog.String("worker", worker), slog.String("hash", block.Hash()))
+}
+
+// retargetLoop adjusts the difficulty of every connection every Config.RetargetInterval until ctx is done.
+func (s *Server) retargetLoop(ctx context.Context) {
+	interval := s.config.RetargetInterval
+	if interval <= 0 {
+		interval = defaultRetargetInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.retarget()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// retarget adjusts the difficulty of every connection, so it submits a share about every Config.ShareInterval.
+func (s *Server) retarget() {
+	s.mu.Lock()
+	conns := s.connList()
+	s.mu.Unlock()
+
+	interval := s.config.ShareInterval
+	if interval <= 0 {
+		interval = defaultShareInterval
+	}
+
+	for _, c := range conns {
+		c.retarget(interval)
+	}
+}
+
+// clampDifficulty returns difficulty within Config.MinDifficulty and Config.MaxDifficulty, or the default difficulty if
+// it is not positive.
+func (s *Server) clampDifficulty(difficulty float64) float64 {
+	if difficulty <= 0 {
+		difficulty = defaultDifficulty
+	}
+
+	if s.config.MinDifficulty > 0 {
+		difficulty = max(difficulty, s.config.MinDifficulty)
+	}
+
+	if s.config.MaxDifficulty > 0 {
+		difficulty = min(difficulty, s.config.MaxDifficulty)
+	}
+
+	return difficulty
+}
+
+// shareTarget returns the target of a share of difficulty.
+func shareTarget(difficulty float64) *big.Int {
+	target, _ := new(big.Float).Quo(new(big.Float).SetInt(diff1Target), big.NewFloat(difficulty)).Int(nil)
+
+	return target
+}
+
+// log logs msg with attrs to Config.Logger, if it is set.
+func (s *Server) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	if s.config.Logger != nil {
+		s.config.Logger.LogAttrs(context.Background(), level, msg, attrs...)
+	}
+}
@@ -0,0 +1,429 @@
+package stratum
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core/internal/wire"
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSource is a TemplateSource which sends the templates written to its channel.
+type testSource struct {
+	templates chan *types.BlockTemplate
+
+	mu        sync.Mutex
+	submitted []string
+}
+
+func (s *testSource) SubscribeBlockTemplates(ctx context.Context, req *types.BlockTemplateRequest, fn func(*types.BlockTemplate) error) error {
+	for {
+		select {
+		case tmpl := <-s.templates:
+			if err := fn(tmpl); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *testSource) SubmitBlock(hexdata string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.submitted = append(s.submitted, hexdata)
+
+	return nil
+}
+
+func (s *testSource) blocks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.submitted...)
+}
+
+// testTemplate returns a template on top of prevBlockHash with one transaction. Its target is 16 times harder than a
+// share at the difficulty used by the tests, so not every share is a block.
+func testTemplate(prevBlockHash string) *types.BlockTemplate {
+	tx := &wire.Tx{
+		Version: 2,
+		Inputs:  []*wire.TxIn{{PrevIndex: 1, Script: []byte{0x51}, Sequence: 0xfffffffd}},
+		Outputs: []*wire.TxOut{{Value: 1000, Script: []byte{0x51}}},
+	}
+	txid := tx.TxID()
+	now := int(time.Now().Unix())
+
+	return &types.BlockTemplate{
+		Version:           0x20000000,
+		PreviousBlockhash: prevBlockHash,
+		Transactions:      []*types.BlockTemplateTransaction{{Data: hex.EncodeToString(tx.Bytes(true)), Txid: txid.String()}},
+		CoinbaseValue:     5000000100,
+		MinTime:           now - 600,
+		CurTime:           now,
+		Bits:              "1f00ffff",
+		Height:            200,
+	}
+}
+
+// testDifficulty is the share difficulty used by the tests, at which about one in 4096 hashes is a share.
+const testDifficulty = 1.0 / (1 << 20)
+
+// message is a message sent by the server.
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  []any           `json:"error"`
+}
+
+// testMiner is a miner connected to the server.
+type testMiner struct {
+	t      *testing.T
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID int
+
+	extraNonce1 []byte
+	difficulty  float64
+	job         []json.RawMessage
+}
+
+func newTestMiner(t *testing.T, addr string) *testMiner {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return &testMiner{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// read reads the next message, and keeps track of the difficulty and job.
+func (m *testMiner) read() *message {
+	require.NoError(m.t, m.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	line, err := m.r.ReadBytes('\n')
+	require.NoError(m.t, err)
+
+	msg := &message{}
+	require.NoError(m.t, json.Unmarshal(line, msg))
+
+	switch msg.Method {
+	case "mining.set_difficulty":
+		var params []float64
+		require.NoError(m.t, json.Unmarshal(msg.Params, &params))
+		m.difficulty = params[0]
+	case "mining.notify":
+		m.job = nil
+		require.NoError(m.t, json.Unmarshal(msg.Params, &m.job))
+	}
+
+	return msg
+}
+
+// waitFor reads messages until a notification for method.
+func (m *testMiner) waitFor(method string) *message {
+	for {
+		if msg := m.read(); msg.Method == method {
+			return msg
+		}
+	}
+}
+
+// call sends a request and returns the response.
+func (m *testMiner) call(method string, params ...any) *message {
+	m.nextID++
+	id := m.nextID
+
+	b, err := json.Marshal(map[string]any{"id": id, "method": method, "params": params})
+	require.NoError(m.t, err)
+
+	_, err = m.conn.Write(append(b, '\n'))
+	require.NoError(m.t, err)
+
+	for {
+		if msg := m.read(); msg.ID != nil && *msg.ID == id {
+			return msg
+		}
+	}
+}
+
+// start subscribes and authorizes the miner, and waits for its first job.
+func (m *testMiner) start(worker string) {
+	msg := m.call("mining.subscribe", "test/1.0")
+	require.Nil(m.t, msg.Error)
+
+	var result []json.RawMessage
+	require.NoError(m.t, json.Unmarshal(msg.Result, &result))
+
+	var extraNonce1 string
+	require.NoError(m.t, json.Unmarshal(result[1], &extraNonce1))
+	m.extraNonce1, _ = hex.DecodeString(extraNonce1)
+
+	msg = m.call("mining.authorize", worker, "x")
+	require.Nil(m.t, msg.Error)
+
+	if m.job == nil {
+		m.waitFor("mining.notify")
+	}
+}
+
+// jobParam returns the string param at idx of the current job.
+func (m *testMiner) jobParam(idx int) string {
+	var s string
+	require.NoError(m.t, json.Unmarshal(m.job[idx], &s))
+
+	return s
+}
+
+// header builds the header for the current job like a miner does.
+func (m *testMiner) header(extraNonce2 []byte, nonce uint32) *wire.Header {
+	coinb1, _ := hex.DecodeString(m.jobParam(2))
+	coinb2, _ := hex.DecodeString(m.jobParam(3))
+
+	coinbase := append(append(append(coinb1, m.extraNonce1...), extraNonce2...), coinb2...)
+	root := wire.DoubleHash(coinbase)
+
+	var branch []string
+	require.NoError(m.t, json.Unmarshal(m.job[4], &branch))
+
+	for _, hashHex := range branch {
+		hash, _ := hex.DecodeString(hashHex)
+		root = wire.DoubleHash(append(root[:], hash...))
+	}
+
+	prevHash, _ := hex.DecodeString(m.jobParam(1))
+	header := &wire.Header{MerkleRoot: root, Nonce: nonce}
+
+	for idx := 0; idx < len(prevHash); idx += 4 {
+		binary.LittleEndian.PutUint32(header.PrevBlock[idx:], binary.BigEndian.Uint32(prevHash[idx:]))
+	}
+
+	version, _ := strconv.ParseUint(m.jobParam(5), 16, 32)
+	bits, _ := strconv.ParseUint(m.jobParam(6), 16, 32)
+	ntime, _ := strconv.ParseUint(m.jobParam(7), 16, 32)
+	header.Version, header.Bits, header.Time = int32(version), uint32(bits), uint32(ntime)
+
+	return header
+}
+
+// findNonce returns the first nonce from start whose hash meets (or misses, if meets is false) target.
+func (m *testMiner) findNonce(extraNonce2 []byte, start uint32, target *big.Int, meets bool) (uint32, *wire.Header) {
+	for nonce := start; ; nonce++ {
+		header := m.header(extraNonce2, nonce)
+		hash := header.Hash()
+		if (hash.Big().Cmp(target) <= 0) == meets {
+			return nonce, header
+		}
+	}
+}
+
+// submit submits a share for the current job.
+func (m *testMiner) submit(worker string, extraNonce2 []byte, nonce uint32) *message {
+	return m.call("mining.submit", worker, m.jobParam(0), hex.EncodeToString(extraNonce2), m.jobParam(7), fmt08x(nonce))
+}
+
+func fmt08x(n uint32) string {
+	return hex.EncodeToString(binary.BigEndian.AppendUint32(nil, n))
+}
+
+func startServer(t *testing.T, config *Config) (*Server, *testSource, string) {
+	source := &testSource{templates: make(chan *types.BlockTemplate, 1)}
+	config.Source = source
+	config.PayoutScript = []byte{0x51}
+
+	server := NewServer(config)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() { done <- server.Serve(ctx, l) }()
+
+	t.Cleanup(func() {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	})
+
+	source.templates <- testTemplate("0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206")
+	require.Eventually(t, func() bool { return server.currentJob() != nil }, time.Second, time.Millisecond)
+
+	return server, source, l.Addr().String()
+}
+
+func TestServer_Submit(t *testing.T) {
+	_, source, addr := startServer(t, &Config{Difficulty: testDifficulty})
+
+	miner := newTestMiner(t, addr)
+	miner.start("worker")
+	assert.Equal(t, testDifficulty, miner.difficulty)
+
+	shareTarget := shareTarget(testDifficulty)
+	networkTarget := wire.CompactToBig(0x1f00ffff)
+	extraNonce2 := []byte{0, 0, 0, 1}
+
+	// Submit shares until one of them is a block.
+	var (
+		nonce  uint32
+		header *wire.Header
+		shares int
+	)
+
+	for {
+		nonce, header = miner.findNonce(extraNonce2, nonce, shareTarget, true)
+
+		msg := miner.submit("worker", extraNonce2, nonce)
+		require.Nil(t, msg.Error)
+		assert.Equal(t, "true", string(msg.Result))
+		shares++
+
+		hash := header.Hash()
+		if hash.Big().Cmp(networkTarget) <= 0 {
+			break
+		}
+
+		nonce++
+	}
+
+	blocks := source.blocks()
+	require.Len(t, blocks, 1, "shares: %v", shares)
+	assert.Equal(t, hex.EncodeToString(header.Bytes()), blocks[0][:2*wire.HeaderSize])
+
+	// Shares are rejected if they are duplicates, too easy or for unknown workers or jobs.
+	msg := miner.submit("worker", extraNonce2, nonce)
+	assert.Equal(t, float64(errDuplicate), msg.Error[0])
+
+	lowNonce, _ := miner.findNonce(extraNonce2, 0, shareTarget, false)
+	msg = miner.submit("worker", extraNonce2, lowNonce)
+	assert.Equal(t, float64(errLowDifficulty), msg.Error[0])
+
+	msg = miner.submit("other", extraNonce2, nonce)
+	assert.Equal(t, float64(errUnauthorized), msg.Error[0])
+
+	msg = miner.call("mining.submit", "worker", "ff", "00000001", miner.jobParam(7), "00000000")
+	assert.Equal(t, float64(errJobNotFound), msg.Error[0])
+
+	msg = miner.call("mining.submit", "worker", miner.jobParam(0), "01", miner.jobParam(7), "00000000")
+	assert.Equal(t, float64(errOther), msg.Error[0])
+}
+
+func TestServer_NewBlock(t *testing.T) {
+	server, source, addr := startServer(t, &Config{Difficulty: testDifficulty})
+
+	miner := newTestMiner(t, addr)
+	miner.start("worker")
+
+	// The share is submitted after the next job is sent, so it must carry the ntime of the job it was found for.
+	oldJob, oldTime := miner.jobParam(0), miner.jobParam(7)
+	extraNonce2 := []byte{0, 0, 0, 2}
+	nonce, _ := miner.findNonce(extraNonce2, 0, shareTarget(testDifficulty), true)
+
+	// A template on the same block adds a job, and shares for the old one are still accepted.
+	source.templates <- testTemplate("0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206")
+	miner.waitFor("mining.notify")
+	assert.Equal(t, "false", string(miner.job[8]))
+	sameBlockJob := miner.jobParam(0)
+
+	msg := miner.call("mining.submit", "worker", oldJob, hex.EncodeToString(extraNonce2), oldTime, fmt08x(nonce))
+	assert.Nil(t, msg.Error)
+
+	// A template on a new block makes the old jobs stale.
+	source.templates <- testTemplate("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f")
+	miner.waitFor("mining.notify")
+	assert.Equal(t, "true", string(miner.job[8]))
+	assert.Nil(t, server.lookupJob(oldJob))
+	assert.Nil(t, server.lookupJob(sameBlockJob))
+	assert.NotNil(t, server.lookupJob(miner.jobParam(0)))
+
+	msg = miner.call("mining.submit", "worker", oldJob, hex.EncodeToString(extraNonce2), oldTime, fmt08x(nonce+1))
+	assert.Equal(t, float64(errJobNotFound), msg.Error[0])
+}
+
+func TestServer_Vardiff(t *testing.T) {
+	server, _, addr := startServer(t, &Config{
+		Difficulty:    16,
+		MinDifficulty: 2,
+		MaxDifficulty: 64,
+		ShareInterval: time.Second,
+	})
+
+	miner := newTestMiner(t, addr)
+	miner.start("worker")
+	assert.Equal(t, float64(16), miner.difficulty)
+
+	// Without shares, the difficulty drops by the maximum factor and then to the minimum.
+	server.retarget()
+	miner.waitFor("mining.set_difficulty")
+	assert.Equal(t, float64(4), miner.difficulty)
+
+	server.retarget()
+	miner.waitFor("mining.set_difficulty")
+	assert.Equal(t, float64(2), miner.difficulty)
+
+	// Many shares in a short time raise the difficulty, up to the maximum.
+	var c *conn
+
+	server.mu.Lock()
+	for c = range server.conns {
+	}
+	server.mu.Unlock()
+
+	c.mu.Lock()
+	c.shares = 1000
+	c.mu.Unlock()
+
+	server.retarget()
+	miner.waitFor("mining.set_difficulty")
+	assert.Equal(t, float64(8), miner.difficulty)
+
+	msg := miner.call("mining.suggest_difficulty", 1000)
+	assert.Nil(t, msg.Error)
+	assert.Equal(t, float64(64), miner.difficulty)
+}
+
+func TestServer_Authorize(t *testing.T) {
+	_, _, addr := startServer(t, &Config{
+		Authorize: func(user, password string) bool {
+			return password == "secret"
+		},
+	})
+
+	miner := newTestMiner(t, addr)
+
+	msg := miner.call("mining.submit", "worker", "1", "00000000", "00000000", "00000000")
+	assert.Equal(t, float64(errNotSubscribed), msg.Error[0])
+
+	miner.call("mining.subscribe")
+
+	msg = miner.call("mining.authorize", "worker", "wrong")
+	assert.Equal(t, float64(errUnauthorized), msg.Error[0])
+
+	msg = miner.call("mining.authorize", "worker", "secret")
+	assert.Nil(t, msg.Error)
+	assert.Equal(t, "true", string(msg.Result))
+
+	msg = miner.call("mining.unknown")
+	assert.Equal(t, float64(errOther), msg.Error[0])
+}
+
+func TestStratumPrevHash(t *testing.T) {
+	assert.Equal(t,
+		"0a8ce26f72b3f1b646a2a6c14ff763ae65831e939c085ae10019d66800000000",
+		stratumPrevHash("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"),
+	)
+}
@@ -0,0 +1,192 @@
+package rpcclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// StreamResult is a result which decodes itself straight from the response body, instead of from a buffered copy of
+// the result. When a StreamResult is passed to SendReq, DecodeJSONStream is called with the decoder positioned at the
+// start of the result, which may be null. It must consume exactly the result value.
+type StreamResult interface {
+	DecodeJSONStream(dec *json.Decoder) error
+}
+
+// decodeStreamResponse decodes a response envelope, passing the result to stream.
+func decodeStreamResponse(dec *json.Decoder, stream StreamResult) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	var rpcErr *RPCError
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "result":
+			err = stream.DecodeJSONStream(dec)
+		case "error":
+			err = dec.Decode(&rpcErr)
+		default:
+			err = dec.Decode(new(json.RawMessage))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if rpcErr != nil {
+		return rpcErr
+	}
+
+	return expectDelim(dec, '}')
+}
+
+// expectDelim reads the next token, which must be delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("rpcclient: expected %v in response, got %v", delim, token)
+	}
+
+	return nil
+}
+
+// decodeStreamObject decodes a JSON object or null, calling fn for every key with the decoder positioned at its value.
+// Returns false if the value was null.
+func decodeStreamObject(dec *json.Decoder, fn func(key string) error) (bool, error) {
+	token, err := dec.Token()
+	if err != nil || token == nil {
+		return false, err
+	}
+
+	if token != json.Delim('{') {
+		return false, fmt.Errorf("rpcclient: expected an object in response, got %v", token)
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+
+		if err := fn(key.(string)); err != nil {
+			return false, err
+		}
+	}
+
+	return true, expectDelim(dec, '}')
+}
+
+// unmarshalStream implements json.Unmarshaler for a StreamResult, so it can be decoded from a buffered result too.
+func unmarshalStream(stream StreamResult, data []byte) error {
+	return stream.DecodeJSONStream(json.NewDecoder(bytes.NewReader(data)))
+}
+
+// blockTxStream decodes a verbose block, passing every transaction to fn instead of collecting them.
+type blockTxStream struct {
+	block *types.BlockTx
+	fn    func(tx *types.Transaction) error
+}
+
+// DecodeJSONStream implements StreamResult.
+func (s *blockTxStream) DecodeJSONStream(dec *json.Decoder) error {
+	fields := map[string]json.RawMessage{}
+
+	found, err := decodeStreamObject(dec, func(key string) error {
+		if key != "tx" {
+			var value json.RawMessage
+			err := dec.Decode(&value)
+			fields[key] = value
+
+			return err
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+
+		for dec.More() {
+			tx := &types.Transaction{}
+			if err := dec.Decode(tx); err != nil {
+				return err
+			}
+
+			if err := s.fn(tx); err != nil {
+				return err
+			}
+		}
+
+		return expectDelim(dec, ']')
+	})
+	if err != nil || !found {
+		return err
+	}
+
+	header, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	s.block = &types.BlockTx{}
+
+	return json.Unmarshal(header, s.block)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *blockTxStream) UnmarshalJSON(data []byte) error {
+	return unmarshalStream(s, data)
+}
+
+// mempoolStream decodes a verbose mempool, passing every entry to fn instead of collecting them.
+type mempoolStream struct {
+	fn func(txid string, entry *types.MempoolTransaction) error
+}
+
+// DecodeJSONStream implements StreamResult.
+func (s *mempoolStream) DecodeJSONStream(dec *json.Decoder) error {
+	_, err := decodeStreamObject(dec, func(txid string) error {
+		entry := &types.MempoolTransaction{}
+		if err := dec.Decode(entry); err != nil {
+			return err
+		}
+
+		return s.fn(txid, entry)
+	})
+
+	return err
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *mempoolStream) UnmarshalJSON(data []byte) error {
+	return unmarshalStream(s, data)
+}
+
+// IterBlockTx is like GetBlockVerboseTx, but calls fn for every transaction as it is decoded from the response, so
+// only one transaction is held in memory at a time. The returned block has no transactions. If fn returns an error,
+// the iteration stops and the error is returned.
+func (c *Client) IterBlockTx(hash string, fn func(tx *types.Transaction) error) (*types.BlockTx, error) {
+	stream := &blockTxStream{fn: fn}
+	if err := c.SendReq("getblock", stream, hash, 2); err != nil {
+		return nil, err
+	}
+
+	return stream.block, nil
+}
+
+// IterRawMempoolVerbose is like GetRawMempoolVerbose, but calls fn for every entry as it is decoded from the response,
+// so only one entry is held in memory at a time. If fn returns an error, the iteration stops and the error is returned.
+func (c *Client) IterRawMempoolVerbose(fn func(txid string, entry *types.MempoolTransaction) error) error {
+	return c.SendReq("getrawmempool", &mempoolStream{fn: fn}, true, false)
+}
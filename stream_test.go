@@ -0,0 +1,77 @@
+package rpcclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamHandler answers getblock with a block of three transactions and getrawmempool with three entries.
+func streamHandler(req *Request) (any, *RPCError) {
+	switch req.Method {
+	case "getblock":
+		if req.Params[0] == "missing" {
+			return nil, &RPCError{Code: -5, Message: "Block not found"}
+		}
+
+		return map[string]any{
+			"hash":   req.Params[0],
+			"height": 7,
+			"tx":     []map[string]any{{"txid": "t1"}, {"txid": "t2"}, {"txid": "t3"}},
+			"weight": 4000,
+		}, nil
+	case "getrawmempool":
+		return map[string]any{"t1": map[string]any{"vsize": 1}, "t2": map[string]any{"vsize": 2}, "t3": map[string]any{"vsize": 3}}, nil
+	}
+
+	return nil, &RPCError{Code: -32601, Message: "Method not found"}
+}
+
+func TestClient_IterBlockTx(t *testing.T) {
+	client := newTestClient(t, nil, streamHandler)
+	quorum, err := NewQuorumClient(&QuorumConfig{Nodes: []*Client{client}})
+	require.NoError(t, err)
+
+	for _, c := range []*Client{client, newTestClient(t, &Config{DeduplicateRequests: true}, streamHandler), quorum.Client} {
+		var txids []string
+		block, err := c.IterBlockTx("00ff", func(tx *types.Transaction) error {
+			txids = append(txids, tx.Txid)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"t1", "t2", "t3"}, txids)
+		assert.Equal(t, "00ff", block.Hash)
+		assert.Equal(t, 7, block.Height)
+		assert.Equal(t, 4000, block.Weight)
+		assert.Nil(t, block.Tx)
+	}
+
+	stop := errors.New("stop")
+	count := 0
+	_, err = client.IterBlockTx("00ff", func(tx *types.Transaction) error {
+		count++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, count)
+
+	_, err = client.IterBlockTx("missing", func(tx *types.Transaction) error { return nil })
+	var rpcErr *RPCError
+	require.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -5, rpcErr.Code)
+}
+
+func TestClient_IterRawMempoolVerbose(t *testing.T) {
+	client := newTestClient(t, nil, streamHandler)
+
+	sizes := map[string]int{}
+	err := client.IterRawMempoolVerbose(func(txid string, entry *types.MempoolTransaction) error {
+		sizes[txid] = entry.Vsize
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"t1": 1, "t2": 2, "t3": 3}, sizes)
+}
@@ -0,0 +1,299 @@
+package rpcclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrInvalidCertificates is returned by New if Certificates or CertificatesFile contain no valid PEM certificates.
+var ErrInvalidCertificates = errors.New("rpcclient: no valid PEM certificates found")
+
+// ErrPinMismatch is returned when none of the server's certificates match Config.PinnedPublicKeys.
+var ErrPinMismatch = errors.New("rpcclient: server public key does not match any pinned key")
+
+// newTLSConfig creates the tls.Config for the TLS options in the Config.
+func newTLSConfig(config *Config) (*tls.Config, error) {
+	minVersion := config.MinTLSVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: minVersion,
+		ServerName: config.ServerName,
+	}
+
+	pins, err := parsePins(config.PinnedPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader := &certReloader{
+		staticCAs: config.Certificates,
+		caFile:    config.CertificatesFile,
+	}
+
+	// The inline client certificate takes precedence, so the files aren't loaded at all when it is set.
+	inlineCert := len(config.ClientCertificate) > 0 || len(config.ClientKey) > 0
+	if !inlineCert {
+		reloader.certFile = config.ClientCertificateFile
+		reloader.keyFile = config.ClientKeyFile
+	}
+
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	if inlineCert {
+		cert, err := tls.X509KeyPair(config.ClientCertificate, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("rpcclient: client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	} else if reloader.certFile != "" {
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			_ = reloader.reload()
+			return reloader.clientCertificate(), nil
+		}
+	}
+
+	switch {
+	case reloader.caFile != "":
+		// The roots can change between handshakes, so the chain is verified in VerifyConnection instead.
+		tlsConfig.InsecureSkipVerify = true
+		serverName := verifiedServerName(config)
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			_ = reloader.reload()
+			if err := verifyChain(cs, reloader.rootCAs(), serverName); err != nil {
+				return err
+			}
+
+			return verifyPins(cs, pins)
+		}
+	case len(config.Certificates) > 0:
+		tlsConfig.RootCAs = reloader.rootCAs()
+		fallthrough
+	default:
+		if len(pins) > 0 {
+			tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+				return verifyPins(cs, pins)
+			}
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// certReloader loads the root CAs and client certificate, and reloads the files when they change on disk. Invalid
+// files are only an error on the first load, afterwards the previous certificates are kept until the files are valid
+// again, so a rotation that writes the certificate and key one after the other does not break connections.
+type certReloader struct {
+	staticCAs []byte
+	caFile    string
+	certFile  string
+	keyFile   string
+
+	mu      sync.Mutex
+	loaded  bool
+	caMod   time.Time
+	certMod time.Time
+	keyMod  time.Time
+	pool    *x509.CertPool
+	cert    *tls.Certificate
+}
+
+// reload reloads the files that have changed since the last call.
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	first := !r.loaded
+	r.loaded = true
+
+	if first || r.caFile != "" {
+		if err := r.reloadCAs(first); err != nil && first {
+			return err
+		}
+	}
+
+	if r.certFile != "" || r.keyFile != "" {
+		if err := r.reloadClientCertificate(first); err != nil && first {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reloadCAs rebuilds the pool of root CAs if the CA file has changed.
+func (r *certReloader) reloadCAs(force bool) error {
+	var (
+		pem []byte
+		mod time.Time
+	)
+
+	if r.caFile != "" {
+		info, err := os.Stat(r.caFile)
+		if err != nil {
+			return err
+		}
+
+		mod = info.ModTime()
+		if !force && mod.Equal(r.caMod) {
+			return nil
+		}
+
+		if pem, err = os.ReadFile(r.caFile); err != nil {
+			return err
+		}
+	} else if len(r.staticCAs) == 0 {
+		return nil
+	}
+
+	pool := x509.NewCertPool()
+	if len(r.staticCAs) > 0 && !pool.AppendCertsFromPEM(r.staticCAs) {
+		return ErrInvalidCertificates
+	}
+
+	if r.caFile != "" && !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("%w in %v", ErrInvalidCertificates, r.caFile)
+	}
+
+	r.pool, r.caMod = pool, mod
+
+	return nil
+}
+
+// reloadClientCertificate reloads the client certificate if the certificate or key file has changed.
+func (r *certReloader) reloadClientCertificate(force bool) error {
+	if r.certFile == "" || r.keyFile == "" {
+		return errors.New("rpcclient: both ClientCertificateFile and ClientKeyFile must be set")
+	}
+
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	if !force && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("rpcclient: client certificate: %w", err)
+	}
+
+	r.cert, r.certMod, r.keyMod = &cert, certInfo.ModTime(), keyInfo.ModTime()
+
+	return nil
+}
+
+// rootCAs returns the current pool of root CAs.
+func (r *certReloader) rootCAs() *x509.CertPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.pool
+}
+
+// clientCertificate returns the current client certificate.
+func (r *certReloader) clientCertificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cert
+}
+
+// verifiedServerName returns the name the server's certificate must be valid for: Config.ServerName, or else the
+// hostname or IP address in Config.Host. ConnectionState.ServerName can't be used, as it is empty for IP addresses.
+func verifiedServerName(config *Config) string {
+	if config.ServerName != "" {
+		return config.ServerName
+	}
+
+	host, err := url.Parse(config.Host)
+	if err != nil {
+		return ""
+	}
+
+	return host.Hostname()
+}
+
+// verifyChain verifies the server's certificate chain against roots and serverName, as crypto/tls would have done.
+func verifyChain(cs tls.ConnectionState, roots *x509.CertPool, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("rpcclient: server presented no certificates")
+	}
+
+	if serverName == "" {
+		return errors.New("rpcclient: no server name to verify the certificate against")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+
+	return err
+}
+
+// verifyPins checks that one of the server's certificates has a pinned public key. All certificates are accepted if
+// there are no pins.
+func verifyPins(cs tls.ConnectionState, pins map[[sha256.Size]byte]bool) error {
+	if len(pins) == 0 {
+		return nil
+	}
+
+	for _, cert := range cs.PeerCertificates {
+		if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+			return nil
+		}
+	}
+
+	return ErrPinMismatch
+}
+
+// parsePins decodes base64-encoded SHA-256 hashes of SubjectPublicKeyInfos.
+func parsePins(encoded []string) (map[[sha256.Size]byte]bool, error) {
+	pins := make(map[[sha256.Size]byte]bool, len(encoded))
+	for _, pin := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("rpcclient: invalid pinned public key %q: expected a base64-encoded SHA-256 hash", pin)
+		}
+
+		var hash [sha256.Size]byte
+		copy(hash[:], raw)
+		pins[hash] = true
+	}
+
+	return pins, nil
+}
+
+// PublicKeyPin returns the pin for cert's public key, for use in Config.PinnedPublicKeys.
+func PublicKeyPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
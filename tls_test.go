@@ -0,0 +1,262 @@
+package rpcclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCert is a certificate with its PEM encodings.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert creates a certificate for cn signed by parent, or a self-signed CA if parent is nil. It is valid for
+// hosts, which default to 127.0.0.1 and node.internal.
+func newTestCert(t *testing.T, cn string, parent *testCert, hosts ...string) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"node.internal"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	if len(hosts) > 0 {
+		tmpl.IPAddresses, tmpl.DNSNames = nil, nil
+
+		for _, host := range hosts {
+			if ip := net.ParseIP(host); ip != nil {
+				tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+			} else {
+				tmpl.DNSNames = append(tmpl.DNSNames, host)
+			}
+		}
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// newMTLSServer starts a server requiring client certificates signed by ca. It answers every request with the common
+// name of the client certificate.
+func newMTLSServer(t *testing.T, ca, serverCert *testCert) *httptest.Server {
+	pair, err := tls.X509KeyPair(serverCert.certPEM, serverCert.keyPEM)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": r.TLS.PeerCertificates[0].Subject.CommonName})
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClient_MutualTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newMTLSServer(t, ca, newTestCert(t, "server", ca))
+	client := newTestCert(t, "client", ca)
+
+	c, err := New(&Config{
+		Host:              server.URL,
+		Certificates:      ca.certPEM,
+		ClientCertificate: client.certPEM,
+		ClientKey:         client.keyPEM,
+	})
+	require.NoError(t, err)
+
+	var cn string
+	require.NoError(t, c.SendReq("whoami", &cn))
+	assert.Equal(t, "client", cn)
+
+	// The inline pair takes precedence, so the files are never read.
+	c, err = New(&Config{
+		Host:                  server.URL,
+		Certificates:          ca.certPEM,
+		ClientCertificate:     client.certPEM,
+		ClientKey:             client.keyPEM,
+		ClientCertificateFile: filepath.Join(t.TempDir(), "missing.pem"),
+		ClientKeyFile:         filepath.Join(t.TempDir(), "missing.key"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.SendReq("whoami", &cn))
+	assert.Equal(t, "client", cn)
+
+	c, err = New(&Config{Host: server.URL, Certificates: ca.certPEM})
+	require.NoError(t, err)
+	assert.Error(t, c.SendReq("whoami", &cn))
+}
+
+func TestClient_TLSServerNameAndPinning(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	serverCert := newTestCert(t, "server", ca)
+	server := newMTLSServer(t, ca, serverCert)
+	client := newTestCert(t, "client", ca)
+
+	config := &Config{
+		Host:              server.URL,
+		Certificates:      ca.certPEM,
+		ClientCertificate: client.certPEM,
+		ClientKey:         client.keyPEM,
+		ServerName:        "node.internal",
+		PinnedPublicKeys:  []string{PublicKeyPin(serverCert.cert)},
+		MinTLSVersion:     tls.VersionTLS13,
+	}
+
+	c, err := New(config)
+	require.NoError(t, err)
+	assert.NoError(t, c.SendReq("whoami", new(string)))
+
+	config.ServerName = "other.internal"
+	c, err = New(config)
+	require.NoError(t, err)
+	assert.Error(t, c.SendReq("whoami", new(string)))
+
+	config.ServerName = ""
+	config.PinnedPublicKeys = []string{PublicKeyPin(client.cert)}
+	c, err = New(config)
+	require.NoError(t, err)
+	assert.ErrorIs(t, c.SendReq("whoami", new(string)), ErrPinMismatch)
+}
+
+func TestClient_TLSReload(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newMTLSServer(t, ca, newTestCert(t, "server", ca))
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+
+	writeCert := func(cert *testCert, mod time.Time) {
+		require.NoError(t, os.WriteFile(certFile, cert.certPEM, 0o600))
+		require.NoError(t, os.WriteFile(keyFile, cert.keyPEM, 0o600))
+		require.NoError(t, os.Chtimes(certFile, mod, mod))
+		require.NoError(t, os.Chtimes(keyFile, mod, mod))
+	}
+
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+	writeCert(newTestCert(t, "first", ca), time.Now().Add(-time.Minute))
+
+	c, err := New(&Config{
+		Host:                  server.URL,
+		CertificatesFile:      caFile,
+		ClientCertificateFile: certFile,
+		ClientKeyFile:         keyFile,
+		DisableKeepAlives:     true,
+	})
+	require.NoError(t, err)
+
+	var cn string
+	require.NoError(t, c.SendReq("whoami", &cn))
+	assert.Equal(t, "first", cn)
+
+	writeCert(newTestCert(t, "second", ca), time.Now())
+
+	require.NoError(t, c.SendReq("whoami", &cn))
+	assert.Equal(t, "second", cn)
+}
+
+func TestClient_TLSReloadVerifiesHost(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	client := newTestCert(t, "client", ca)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+
+	// The certificates are signed by the trusted CA, but issued for other nodes.
+	for _, hosts := range [][]string{{"other.internal"}, {"10.0.0.5"}} {
+		server := newMTLSServer(t, ca, newTestCert(t, "server", ca, hosts...))
+
+		c, err := New(&Config{
+			Host:              server.URL,
+			CertificatesFile:  caFile,
+			ClientCertificate: client.certPEM,
+			ClientKey:         client.keyPEM,
+		})
+		require.NoError(t, err)
+
+		var hostErr x509.HostnameError
+		assert.ErrorAs(t, c.SendReq("whoami", new(string)), &hostErr, "certificate for %v", hosts)
+	}
+
+	// ServerName takes precedence over the IP address in Host.
+	server := newMTLSServer(t, ca, newTestCert(t, "server", ca, "other.internal"))
+
+	c, err := New(&Config{
+		Host:              server.URL,
+		CertificatesFile:  caFile,
+		ClientCertificate: client.certPEM,
+		ClientKey:         client.keyPEM,
+		ServerName:        "other.internal",
+	})
+	require.NoError(t, err)
+	assert.NoError(t, c.SendReq("whoami", new(string)))
+}
+
+func TestNew_InvalidCertificates(t *testing.T) {
+	_, err := New(&Config{Host: "https://127.0.0.1:8332", Certificates: []byte("not a certificate")})
+	assert.ErrorIs(t, err, ErrInvalidCertificates)
+
+	_, err = New(&Config{Host: "https://127.0.0.1:8332", PinnedPublicKeys: []string{"short"}})
+	assert.Error(t, err)
+
+	_, err = New(&Config{Host: "https://127.0.0.1:8332", ClientCertificate: []byte("x"), ClientKey: []byte("y")})
+	assert.Error(t, err)
+}
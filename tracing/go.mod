@@ -0,0 +1,26 @@
+module github.com/omarhachach/rpcclient-core/tracing
+
+go 1.21
+
+require (
+	github.com/omarhachach/rpcclient-core v0.0.0
+	github.com/stretchr/testify v1.9.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The root module is used from this checkout until it is tagged. Releases require the tag of the root module cut
+// with them instead of v0.0.0.
+replace github.com/omarhachach/rpcclient-core => ../
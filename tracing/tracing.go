@@ -0,0 +1,190 @@
+// Package tracing traces the requests of rpcclient clients with OpenTelemetry. It is a separate module, so users of
+// rpcclient who don't import it don't depend on OpenTelemetry.
+//
+//	tracer := tracing.NewTracer(nil)
+//
+//	client, err := rpcclient.New(&rpcclient.Config{
+//		Host:              "http://127.0.0.1:8332",
+//		Interceptors:      []rpcclient.Interceptor{tracer.Interceptor()},
+//		BatchInterceptors: []rpcclient.BatchInterceptor{tracer.BatchInterceptor()},
+//	})
+//
+// Spans are started from the span in the context of the request, so use SendReqContext or WithContext to link them
+// to the caller's trace.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/omarhachach/rpcclient-core"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer, which identifies this package.
+const instrumentationName = "github.com/omarhachach/rpcclient-core/tracing"
+
+// batchSpanName is the name of the span around a batch.
+const batchSpanName = "batch"
+
+// Attribute keys of the spans, following the OpenTelemetry semantic conventions for JSON-RPC where there is one.
+const (
+	keyRPCSystem     = attribute.Key("rpc.system")
+	keyRPCMethod     = attribute.Key("rpc.method")
+	keyServerAddress = attribute.Key("server.address")
+	keyServerPort    = attribute.Key("server.port")
+	keyStatusCode    = attribute.Key("http.response.status_code")
+	keyErrorCode     = attribute.Key("rpc.jsonrpc.error_code")
+	keyErrorMessage  = attribute.Key("rpc.jsonrpc.error_message")
+	keyRetries       = attribute.Key("rpc.retries")
+	keyBatchSize     = attribute.Key("rpc.jsonrpc.batch_size")
+)
+
+// rpcSystemJSONRPC is the value of the rpc.system attribute.
+const rpcSystemJSONRPC = "jsonrpc"
+
+// Tracer creates spans for the requests sent through its interceptors.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a new *Tracer which gets its tracer from provider, or from the global provider if provider is nil.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return &Tracer{
+		tracer: provider.Tracer(instrumentationName),
+	}
+}
+
+// Interceptor returns an rpcclient.Interceptor which wraps every request in a span named after the method, with the
+// host, HTTP status and RPC error code as attributes.
+func (t *Tracer) Interceptor() rpcclient.Interceptor {
+	return func(ctx context.Context, method string, result any, params []any, next rpcclient.SendFunc) error {
+		ctx, span := t.tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			keyRPCSystem.String(rpcSystemJSONRPC),
+			keyRPCMethod.String(method),
+		))
+		defer span.End()
+
+		info := rpcclient.CallInfoFromContext(ctx)
+		if info == nil {
+			info = &rpcclient.CallInfo{}
+			ctx = rpcclient.WithCallInfo(ctx, info)
+		}
+
+		err := next(ctx, method, result, params...)
+
+		setCallInfo(span, info)
+		setError(span, err)
+
+		return err
+	}
+}
+
+// BatchInterceptor returns an rpcclient.BatchInterceptor which wraps every batch in a span, with a child span for
+// every request in it. The children share the duration, host and HTTP status of the batch, but each has its own RPC
+// error code.
+func (t *Tracer) BatchInterceptor() rpcclient.BatchInterceptor {
+	return func(ctx context.Context, reqs []*rpcclient.BatchRequest, next rpcclient.BatchSendFunc) error {
+		ctx, span := t.tracer.Start(ctx, batchSpanName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			keyRPCSystem.String(rpcSystemJSONRPC),
+			keyBatchSize.Int(len(reqs)),
+		))
+		defer span.End()
+
+		children := make([]trace.Span, len(reqs))
+		for idx, req := range reqs {
+			_, children[idx] = t.tracer.Start(ctx, req.Method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+				keyRPCSystem.String(rpcSystemJSONRPC),
+				keyRPCMethod.String(req.Method),
+			))
+		}
+
+		info := rpcclient.CallInfoFromContext(ctx)
+		if info == nil {
+			info = &rpcclient.CallInfo{}
+			ctx = rpcclient.WithCallInfo(ctx, info)
+		}
+
+		err := next(ctx, reqs)
+
+		setCallInfo(span, info)
+		setError(span, err)
+
+		for idx, child := range children {
+			setCallInfo(child, info)
+
+			// If the batch failed as a whole, the errors of the requests weren't set.
+			if err != nil {
+				setError(child, err)
+			} else {
+				setError(child, reqs[idx].Err)
+			}
+
+			child.End()
+		}
+
+		return err
+	}
+}
+
+// setCallInfo sets the attributes of the HTTP exchange in info on span.
+func setCallInfo(span trace.Span, info *rpcclient.CallInfo) {
+	if info.Host != "" {
+		address, port := splitHost(info.Host)
+		span.SetAttributes(keyServerAddress.String(address))
+
+		if port > 0 {
+			span.SetAttributes(keyServerPort.Int(port))
+		}
+	}
+
+	if info.StatusCode != 0 {
+		span.SetAttributes(keyStatusCode.Int(info.StatusCode))
+	}
+
+	if info.Retries > 0 {
+		span.SetAttributes(keyRetries.Int(info.Retries))
+	}
+}
+
+// setError records err on span, with the code and message if it is an *rpcclient.RPCError.
+func setError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	var rpcErr *rpcclient.RPCError
+	if errors.As(err, &rpcErr) {
+		span.SetAttributes(keyErrorCode.Int(rpcErr.Code), keyErrorMessage.String(rpcErr.Message))
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// splitHost splits the Config.Host of a client, which may be a URL or a host and port, into the hostname and port.
+// The port is 0 if it is not known.
+func splitHost(host string) (string, int) {
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	hostname, portStr, err := net.SplitHostPort(host)
+	if err != nil {
+		return host, 0
+	}
+
+	port, _ := strconv.Atoi(portStr)
+
+	return hostname, port
+}
@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// handle answers getblockcount, and fails every other method with an RPC error.
+func handle(method string) map[string]any {
+	if method == "getblockcount" {
+		return map[string]any{"result": 10, "error": nil}
+	}
+
+	return map[string]any{"result": nil, "error": &rpcclient.RPCError{Code: -5, Message: "Block not found"}}
+}
+
+func newTestClient(t *testing.T) (*rpcclient.Client, *tracetest.SpanRecorder) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&raw))
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var batch []map[string]any
+		if json.Unmarshal(raw, &batch) == nil {
+			resps := make([]map[string]any, len(batch))
+			for idx, req := range batch {
+				resps[idx] = handle(req["method"].(string))
+				resps[idx]["id"] = req["id"]
+			}
+
+			_ = json.NewEncoder(w).Encode(resps)
+
+			return
+		}
+
+		var req rpcclient.Request
+		require.NoError(t, json.Unmarshal(raw, &req))
+		_ = json.NewEncoder(w).Encode(handle(req.Method))
+	}))
+	t.Cleanup(server.Close)
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := NewTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	client, err := rpcclient.New(&rpcclient.Config{
+		Host:              server.URL,
+		DisableTLS:        true,
+		Interceptors:      []rpcclient.Interceptor{tracer.Interceptor()},
+		BatchInterceptors: []rpcclient.BatchInterceptor{tracer.BatchInterceptor()},
+	})
+	require.NoError(t, err)
+
+	return client, recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]any {
+	attrs := map[attribute.Key]any{}
+	for _, attr := range span.Attributes() {
+		attrs[attr.Key] = attr.Value.AsInterface()
+	}
+
+	return attrs
+}
+
+func TestTracer_Interceptor(t *testing.T) {
+	client, recorder := newTestClient(t)
+
+	provider := sdktrace.NewTracerProvider()
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+
+	count, err := client.WithContext(ctx).GetBlockCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), count)
+
+	_, err = client.GetBlockVerbose("00ff")
+	require.Error(t, err)
+
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "getblockcount", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	attrs := attributes(spans[0])
+	assert.Equal(t, "getblockcount", attrs[keyRPCMethod])
+	assert.Equal(t, "127.0.0.1", attrs[keyServerAddress])
+	assert.Equal(t, int64(http.StatusOK), attrs[keyStatusCode])
+
+	assert.Equal(t, "getblock", spans[1].Name())
+	assert.False(t, spans[1].Parent().IsValid())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, int64(-5), attributes(spans[1])[keyErrorCode])
+	assert.Len(t, spans[1].Events(), 1)
+}
+
+func TestTracer_BatchInterceptor(t *testing.T) {
+	client, recorder := newTestClient(t)
+
+	var count int64
+	reqs := []*rpcclient.BatchRequest{
+		{Method: "getblockcount", Result: &count},
+		{Method: "getblockheader", Params: []any{"00ff"}},
+	}
+
+	require.NoError(t, client.SendBatch(context.Background(), reqs))
+	assert.Equal(t, int64(10), count)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	batch := spans[2]
+	assert.Equal(t, batchSpanName, batch.Name())
+	assert.Equal(t, int64(2), attributes(batch)[keyBatchSize])
+	assert.Equal(t, codes.Unset, batch.Status().Code)
+
+	for idx, child := range spans[:2] {
+		assert.Equal(t, reqs[idx].Method, child.Name())
+		assert.Equal(t, batch.SpanContext().SpanID(), child.Parent().SpanID())
+		assert.Equal(t, int64(http.StatusOK), attributes(child)[keyStatusCode])
+	}
+
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, int64(-5), attributes(spans[1])[keyErrorCode])
+}
+
+func TestSplitHost(t *testing.T) {
+	for host, expected := range map[string]struct {
+		address string
+		port    int
+	}{
+		"http://127.0.0.1:8332":    {"127.0.0.1", 8332},
+		"https://node.example":     {"node.example", 0},
+		"127.0.0.1:18443":          {"127.0.0.1", 18443},
+		"http://[::1]:8332/wallet": {"::1", 8332},
+	} {
+		address, port := splitHost(host)
+		assert.Equal(t, expected.address, address, host)
+		assert.Equal(t, expected.port, port, host)
+	}
+}
@@ -0,0 +1,163 @@
+package rpcclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Defaults for the transport options in Config.
+const (
+	defaultDialTimeout         = 30 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultMaxIdleConnsPerHost = 4
+)
+
+// newHTTPClient creates a new http.Client that is configured with the transport, proxy and TLS settings in the Config.
+func newHTTPClient(config *Config) (*http.Client, error) {
+	if config.HTTPClient != nil {
+		return config.HTTPClient, nil
+	}
+
+	if config.Transport != nil {
+		return &http.Client{Transport: config.Transport}, nil
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   durationOrDefault(config.DialTimeout, defaultDialTimeout),
+		KeepAlive: durationOrDefault(config.KeepAlive, defaultKeepAlive),
+	}
+
+	dialContext := dialer.DialContext
+
+	var proxyFunc func(*http.Request) (*url.URL, error)
+	if config.Proxy != "" {
+		proxyUrl, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		switch proxyUrl.Scheme {
+		case "socks5", "socks5h":
+			dialContext = newSOCKSDialContext(proxyUrl, config, dialer)
+		case "http", "https":
+			if config.ProxyUser != "" && proxyUrl.User == nil {
+				proxyUrl.User = url.UserPassword(config.ProxyUser, config.ProxyPass)
+			}
+
+			proxyFunc = http.ProxyURL(proxyUrl)
+		default:
+			return nil, fmt.Errorf("rpcclient: unsupported proxy scheme %q", proxyUrl.Scheme)
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if !config.DisableTLS {
+		var err error
+		if tlsConfig, err = newTLSConfig(config); err != nil {
+			return nil, err
+		}
+	}
+
+	maxIdleConnsPerHost := config.MaxIdleConnsPerHost
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 proxyFunc,
+			DialContext:           dialContext,
+			TLSClientConfig:       tlsConfig,
+			TLSHandshakeTimeout:   durationOrDefault(config.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+			ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+			MaxConnsPerHost:       config.MaxConnsPerHost,
+			MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+			IdleConnTimeout:       durationOrDefault(config.IdleConnTimeout, defaultIdleConnTimeout),
+			DisableKeepAlives:     config.DisableKeepAlives,
+		},
+	}
+
+	return client, nil
+}
+
+// newSOCKSDialContext returns a DialContext function which connects through the SOCKS5 proxy at proxyUrl.
+// With the socks5h scheme, hostnames are resolved by the proxy. With socks5 they are resolved locally, except for .onion
+// hostnames which can only be resolved by Tor. Credentials in the URL take precedence over ProxyUser and ProxyPass.
+func newSOCKSDialContext(proxyUrl *url.URL, config *Config, forward *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if proxyUrl.User != nil {
+		pass, _ := proxyUrl.User.Password()
+		auth = &proxy.Auth{User: proxyUrl.User.Username(), Password: pass}
+	} else if config.ProxyUser != "" {
+		auth = &proxy.Auth{User: config.ProxyUser, Password: config.ProxyPass}
+	}
+
+	remoteDNS := proxyUrl.Scheme == "socks5h"
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if !remoteDNS && net.ParseIP(host) == nil && !strings.HasSuffix(host, ".onion") {
+			ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+
+			addr = net.JoinHostPort(ips[0].IP.String(), port)
+		}
+
+		connAuth := auth
+		if config.ProxyIsolation {
+			if connAuth, err = isolationAuth(auth); err != nil {
+				return nil, err
+			}
+		}
+
+		dialer, err := proxy.SOCKS5("tcp", proxyUrl.Host, connAuth, forward)
+		if err != nil {
+			return nil, err
+		}
+
+		return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	}
+}
+
+// isolationAuth returns credentials which are unique to a connection. Tor uses a separate circuit for every distinct
+// set of SOCKS credentials (IsolateSOCKSAuth), so this isolates the connection from all others.
+func isolationAuth(auth *proxy.Auth) (*proxy.Auth, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	user := hex.EncodeToString(random[:8])
+	if auth != nil && auth.User != "" {
+		user = auth.User
+	}
+
+	return &proxy.Auth{User: user, Password: hex.EncodeToString(random[8:])}, nil
+}
+
+// durationOrDefault returns d, or def if d is zero.
+func durationOrDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+
+	return d
+}
@@ -0,0 +1,269 @@
+package rpcclient
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socksRequest is a connection request received by the test SOCKS5 proxy.
+type socksRequest struct {
+	user, pass string
+	host       string
+	domain     bool
+}
+
+// newSOCKSProxy starts a minimal SOCKS5 proxy which requires username/password authentication and connects every
+// request to target, recording the requested address and credentials.
+func newSOCKSProxy(t *testing.T, target string) (string, func() []socksRequest) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var (
+		mu       sync.Mutex
+		requests []socksRequest
+	)
+
+	handle := func(conn net.Conn) error {
+		defer conn.Close()
+
+		buf := make([]byte, 256)
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+			return err
+		}
+
+		// Select username/password authentication.
+		if _, err := conn.Write([]byte{5, 2}); err != nil {
+			return err
+		}
+
+		var req socksRequest
+		readString := func() (string, error) {
+			if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+				return "", err
+			}
+
+			n := int(buf[0])
+			_, err := io.ReadFull(conn, buf[:n])
+
+			return string(buf[:n]), err
+		}
+
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return err
+		}
+
+		if req.user, err = readString(); err != nil {
+			return err
+		}
+
+		if req.pass, err = readString(); err != nil {
+			return err
+		}
+
+		if _, err := conn.Write([]byte{1, 0}); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return err
+		}
+
+		switch buf[3] {
+		case 1:
+			if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+				return err
+			}
+
+			req.host = net.IP(buf[:4]).String()
+		case 3:
+			req.domain = true
+			if req.host, err = readString(); err != nil {
+				return err
+			}
+		default:
+			if _, err := io.ReadFull(conn, buf[:16]); err != nil {
+				return err
+			}
+
+			req.host = net.IP(buf[:16]).String()
+		}
+
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return err
+		}
+
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			return err
+		}
+		defer upstream.Close()
+
+		if _, err := conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0}); err != nil {
+			return err
+		}
+
+		go func() { _, _ = io.Copy(upstream, conn) }()
+		_, err = io.Copy(conn, upstream)
+
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() { _ = handle(conn) }()
+		}
+	}()
+
+	return listener.Addr().String(), func() []socksRequest {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]socksRequest(nil), requests...)
+	}
+}
+
+// newResultServer starts a server answering every request with result.
+func newResultServer(t *testing.T, result any) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": result})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClient_SOCKSProxy(t *testing.T) {
+	server := newResultServer(t, "ok")
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	proxyAddr, requests := newSOCKSProxy(t, server.Listener.Addr().String())
+
+	tests := []struct {
+		name   string
+		config Config
+		host   string
+		domain bool
+		user   string
+		pass   string
+	}{
+		{
+			name:   "socks5 resolves locally",
+			config: Config{Host: "http://localhost:" + port, Proxy: "socks5://" + proxyAddr, ProxyUser: "u", ProxyPass: "p"},
+			host:   "127.0.0.1",
+			user:   "u",
+			pass:   "p",
+		},
+		{
+			name:   "socks5 passes onion hosts to the proxy",
+			config: Config{Host: "http://example.onion:" + port, Proxy: "socks5://url:creds@" + proxyAddr, ProxyUser: "u"},
+			host:   "example.onion",
+			domain: true,
+			user:   "url",
+			pass:   "creds",
+		},
+		{
+			name:   "socks5h resolves remotely",
+			config: Config{Host: "http://node.internal:" + port, Proxy: "socks5h://" + proxyAddr, ProxyUser: "u", ProxyPass: "p"},
+			host:   "node.internal",
+			domain: true,
+			user:   "u",
+			pass:   "p",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(requests())
+
+			tt.config.DisableTLS = true
+			c, err := New(&tt.config)
+			require.NoError(t, err)
+
+			var result string
+			require.NoError(t, c.SendReq("test", &result))
+			assert.Equal(t, "ok", result)
+
+			got := requests()[before:]
+			require.Len(t, got, 1)
+			assert.Equal(t, socksRequest{user: tt.user, pass: tt.pass, host: tt.host, domain: tt.domain}, got[0])
+		})
+	}
+}
+
+func TestClient_SOCKSProxyIsolation(t *testing.T) {
+	server := newResultServer(t, "ok")
+	proxyAddr, requests := newSOCKSProxy(t, server.Listener.Addr().String())
+
+	c, err := New(&Config{
+		Host:              "http://" + server.Listener.Addr().String(),
+		DisableTLS:        true,
+		Proxy:             "socks5h://" + proxyAddr,
+		ProxyUser:         "wallet",
+		ProxyIsolation:    true,
+		DisableKeepAlives: true,
+	})
+	require.NoError(t, err)
+
+	for idx := 0; idx < 3; idx++ {
+		require.NoError(t, c.SendReq("test", new(string)))
+	}
+
+	passwords := map[string]bool{}
+	for _, req := range requests() {
+		assert.Equal(t, "wallet", req.user)
+		passwords[req.pass] = true
+	}
+
+	assert.Len(t, passwords, 3)
+}
+
+func TestClient_HTTPProxyCredentials(t *testing.T) {
+	var auth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Proxy-Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": r.URL.Host})
+	}))
+	t.Cleanup(proxy.Close)
+
+	c, err := New(&Config{
+		Host:       "http://node.internal:8332",
+		DisableTLS: true,
+		Proxy:      proxy.URL,
+		ProxyUser:  "u",
+		ProxyPass:  "p",
+	})
+	require.NoError(t, err)
+
+	var host string
+	require.NoError(t, c.SendReq("test", &host))
+	assert.Equal(t, "node.internal:8332", host)
+	assert.Equal(t, "Basic dTpw", auth)
+
+	_, err = New(&Config{Host: "http://node.internal:8332", Proxy: "ftp://proxy"})
+	assert.Error(t, err)
+}
@@ -1,6 +1,8 @@
 package rpcclient
 
 import (
+	"encoding/json"
+
 	"github.com/omarhachach/rpcclient-core/types"
 )
 
@@ -35,20 +37,40 @@ func (c *Client) GetTxOutSetInfo() (*types.TransactionOutSetInfo, error) {
 }
 
 // ScanTxOutSet is experimental. Please read the docs https://developer.bitcoin.org/reference/rpc/scantxoutset.html.
-func (c *Client) ScanTxOutSet(action types.ScanTxOutSetObject, scanObjects ...*types.ScanTxOutSetObject) (*types.ScanTxOutSetDetails, error) {
+func (c *Client) ScanTxOutSet(action types.ScanTxOutSetAction, scanObjects ...*types.ScanTxOutSetObject) (*types.ScanTxOutSetDetails, error) {
 	var details *types.ScanTxOutSetDetails
 
-	objs := make([]string, len(scanObjects))
+	// The scan objects are only accepted by start.
+	if action != types.ScanTxOutSetStart {
+		return details, c.SendReq("scantxoutset", &details, action)
+	}
+
+	objs, err := scanObjectParams(scanObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	return details, c.SendReq("scantxoutset", &details, action, objs)
+}
+
+// scanObjectParams returns the scan objects as params: descriptors as strings, and other objects as JSON objects.
+func scanObjectParams(scanObjects []*types.ScanTxOutSetObject) ([]any, error) {
+	objs := make([]any, len(scanObjects))
 	for idx, obj := range scanObjects {
+		if obj.Descriptor != "" {
+			objs[idx] = obj.Descriptor
+			continue
+		}
+
 		serializedObj, err := obj.ToJSON()
 		if err != nil {
 			return nil, err
 		}
 
-		objs[idx] = string(serializedObj)
+		objs[idx] = json.RawMessage(serializedObj)
 	}
 
-	return details, c.SendReq("scantxoutset", &details, action, objs)
+	return objs, nil
 }
 
 // VerifyTxOutProof verifies that proof points to a transaction in ablock.
@@ -226,14 +248,9 @@ func (c *Client) TestMempoolAccept(rawtxs []string, maxfeeRate *float64) ([]*typ
 func (c *Client) UtxoUpdatePSBT(psbt string, scanObjects ...*types.ScanTxOutSetObject) (string, error) {
 	var res string
 
-	objs := make([]string, len(scanObjects))
-	for idx, obj := range scanObjects {
-		serializedObj, err := obj.ToJSON()
-		if err != nil {
-			return "", err
-		}
-
-		objs[idx] = string(serializedObj)
+	objs, err := scanObjectParams(scanObjects)
+	if err != nil {
+		return "", err
 	}
 
 	return res, c.SendReq("utxoupdatepsbt", &res, psbt, objs)
@@ -0,0 +1,49 @@
+package rpcclient
+
+import (
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ScanTxOutSet(t *testing.T) {
+	var sent []*Request
+	client := newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		sent = append(sent, req)
+
+		return map[string]any{
+			"success":   true,
+			"height":    200,
+			"bestblock": "00ff",
+			"unspents": []map[string]any{
+				{"txid": "aa", "vout": 1, "scriptPubKey": "0014bb", "desc": "addr(bcrt1q)#cs", "amount": 0.5, "height": 150},
+			},
+			"total_amount": 0.5,
+		}, nil
+	})
+
+	res, err := client.ScanTxOutSet(types.ScanTxOutSetStart,
+		&types.ScanTxOutSetObject{Descriptor: "addr(bcrt1q)"},
+		&types.ScanTxOutSetObject{Desc: "wpkh(tpub/0/*)", RangeN: []int{0, 10}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []*types.ScanTxOutSetUnspent{
+		{Txid: "aa", Vout: 1, ScriptPubKey: "0014bb", Desc: "addr(bcrt1q)#cs", Amount: 0.5, Height: 150},
+	}, res.Unspents)
+
+	// Descriptors are sent as strings, and other scan objects as objects.
+	assert.Equal(t, []any{"start", []any{
+		"addr(bcrt1q)",
+		map[string]any{"desc": "wpkh(tpub/0/*)", "range": []any{float64(0), float64(10)}},
+	}}, sent[0].Params)
+
+	_, err = client.ScanTxOutSet(types.ScanTxOutSetStatus)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"status"}, sent[1].Params)
+
+	_, err = client.ScanTxOutSet(types.ScanTxOutSetStart, &types.ScanTxOutSetObject{})
+	assert.Error(t, err)
+	assert.Len(t, sent, 2)
+}
@@ -1,10 +1,13 @@
 package types
 
-// BlockTemplateRequest is
+// BlockTemplateRequest is the template request passed to getblocktemplate.
 type BlockTemplateRequest struct {
 	Mode         string   `json:"mode,omitempty"`
 	Capabilities []string `json:"capabilities,omitempty"`
 	Rules        []string `json:"rules"`
+	// LongPollID is the LongpollId of a previous template. The node holds the request open until there is a newer
+	// template (BIP22).
+	LongPollID string `json:"longpollid,omitempty"`
 }
 
 // BlockTemplate contains info about a mining block template.
@@ -44,12 +47,12 @@ type BlockTemplateTransaction struct {
 
 // MiningInfo contains mining-related information.
 type MiningInfo struct {
-	Blocks             int    `json:"blocks"`
-	CurrentBlockWeight int    `json:"currentblockweight"`
-	CurrentBlockTx     int    `json:"currentblocktx"`
-	Difficulty         int    `json:"difficulty"`
-	NetworkHashPS      int    `json:"networkhashps"`
-	PooledTx           int    `json:"pooledtx"`
-	Chain              string `json:"chain"`
-	Warnings           string `json:"warnings"`
+	Blocks             int     `json:"blocks"`
+	CurrentBlockWeight int     `json:"currentblockweight"`
+	CurrentBlockTx     int     `json:"currentblocktx"`
+	Difficulty         float64 `json:"difficulty"`
+	NetworkHashPS      float64 `json:"networkhashps"`
+	PooledTx           int     `json:"pooledtx"`
+	Chain              string  `json:"chain"`
+	Warnings           string  `json:"warnings"`
 }
@@ -373,11 +373,45 @@ type SignRawTransactionResultError struct {
 
 // TestMempoolAcceptResult is the result of testmempoolaccept.
 type TestMempoolAcceptResult struct {
-	Txid    string `json:"txid"`
-	Allowed bool   `json:"allowed"`
-	Vsize   int
-	Fees    struct {
-		Base float64 `json:"base"`
-	} `json:"fees"`
-	RejectReason string `json:"reject-reason"`
+	Txid  string `json:"txid"`
+	Wtxid string `json:"wtxid"`
+	// PackageError is set when testing a package fails as a whole, eg because it isn't sorted.
+	PackageError string `json:"package-error"`
+	Allowed      bool   `json:"allowed"`
+	// Vsize and Fees are only set if Allowed.
+	Vsize        int               `json:"vsize"`
+	Fees         MempoolAcceptFees `json:"fees"`
+	RejectReason string            `json:"reject-reason"`
+}
+
+// MempoolAcceptFees are the fees of a transaction tested by testmempoolaccept or submitted by submitpackage.
+type MempoolAcceptFees struct {
+	// Base is the fee of the transaction in BTC.
+	Base float64 `json:"base"`
+	// EffectiveFeeRate is the fee rate in BTC/kvB the transaction was evaluated at, which is the fee rate of the
+	// package of EffectiveIncludes when it was accepted with them.
+	EffectiveFeeRate float64 `json:"effective-feerate"`
+	// EffectiveIncludes are the wtxids of the transactions whose fees and sizes make up EffectiveFeeRate.
+	EffectiveIncludes []string `json:"effective-includes"`
+}
+
+// SubmitPackageResult is the result of submitpackage.
+type SubmitPackageResult struct {
+	// PackageMsg is "success" if the package was accepted, and the reason otherwise.
+	PackageMsg string `json:"package_msg"`
+	// TxResults maps the wtxids of the transactions in the package to their results.
+	TxResults map[string]*SubmitPackageTxResult `json:"tx-results"`
+	// ReplacedTransactions are the txids of the transactions replaced by the package.
+	ReplacedTransactions []string `json:"replaced-transactions"`
+}
+
+// SubmitPackageTxResult is the result of a transaction submitted by submitpackage.
+type SubmitPackageTxResult struct {
+	Txid string `json:"txid"`
+	// OtherWtxid is the wtxid of a different transaction with the same txid, which is in the mempool already.
+	OtherWtxid string             `json:"other-wtxid"`
+	Vsize      int                `json:"vsize"`
+	Fees       *MempoolAcceptFees `json:"fees"`
+	// Error is the reason the transaction wasn't accepted.
+	Error string `json:"error"`
 }
@@ -6,3 +6,54 @@ type EstimateSmartFeeResult struct {
 	Errors  []string `json:"errors"`
 	Blocks  int      `json:"blocks"`
 }
+
+// EstimateRawFeeResult is the result of the estimaterawfee call. A horizon is nil when confTarget is beyond its range.
+type EstimateRawFeeResult struct {
+	Short  *RawFeeEstimate `json:"short"`
+	Medium *RawFeeEstimate `json:"medium"`
+	Long   *RawFeeEstimate `json:"long"`
+}
+
+// RawFeeEstimate is the fee rate estimate of a single horizon of the fee estimator.
+type RawFeeEstimate struct {
+	// FeeRate is the estimated fee rate in BTC/kvB, or 0 if there is no estimate.
+	FeeRate float64 `json:"feerate"`
+	// Decay is the exponential decay per block of the historical moving average of the confirmation data.
+	Decay float64 `json:"decay"`
+	// Scale is the resolution of the confirmation targets at this horizon.
+	Scale int `json:"scale"`
+	// Pass is the lowest range of fee rate buckets which met the threshold.
+	Pass *FeeRateBucket `json:"pass"`
+	// Fail is the highest range of fee rate buckets which didn't meet the threshold.
+	Fail   *FeeRateBucket `json:"fail"`
+	Errors []string       `json:"errors"`
+}
+
+// FeeRateBucket holds the confirmation statistics of a range of fee rate buckets.
+type FeeRateBucket struct {
+	// StartRange and EndRange are the fee rates of the range in BTC/kvB.
+	StartRange float64 `json:"startrange"`
+	EndRange   float64 `json:"endrange"`
+	// WithinTarget is the number of transactions which confirmed within the target.
+	WithinTarget float64 `json:"withintarget"`
+	// TotalConfirmed is the number of transactions which confirmed at any point.
+	TotalConfirmed float64 `json:"totalconfirmed"`
+	// InMempool is the number of transactions in the mempool which haven't confirmed within the target yet.
+	InMempool float64 `json:"inmempool"`
+	// LeftMempool is the number of transactions which left the mempool without confirming.
+	LeftMempool float64 `json:"leftmempool"`
+}
+
+// DescriptorInfo is the result of the getdescriptorinfo call.
+type DescriptorInfo struct {
+	// Descriptor is the descriptor in canonical form, with its checksum and without private keys.
+	Descriptor string `json:"descriptor"`
+	// Checksum is the checksum of the descriptor as given.
+	Checksum string `json:"checksum"`
+	// IsRange is whether the descriptor is ranged.
+	IsRange bool `json:"isrange"`
+	// IsSolvable is whether the descriptor has all the information needed to sign its outputs, besides private keys.
+	IsSolvable bool `json:"issolvable"`
+	// HasPrivateKeys is whether the descriptor contains private keys.
+	HasPrivateKeys bool `json:"hasprivatekeys"`
+}
@@ -14,3 +14,37 @@ func (c *Client) EstimateSmartFee(confTarget int, estimateMode *types.EstimateMo
 
 	return res, c.SendReq("estimatesmartfee", &res, confTarget)
 }
+
+// EstimateRawFee returns the raw fee rate estimates of the short, medium and long horizons of the fee estimator for
+// transactions confirming within confTarget blocks. If threshold is nil, the node default of 0.95 is used.
+func (c *Client) EstimateRawFee(confTarget int, threshold *float64) (*types.EstimateRawFeeResult, error) {
+	var res *types.EstimateRawFeeResult
+
+	if threshold != nil {
+		return res, c.SendReq("estimaterawfee", &res, confTarget, *threshold)
+	}
+
+	return res, c.SendReq("estimaterawfee", &res, confTarget)
+}
+
+// GetDescriptorInfo analyses a descriptor, and returns it in canonical form with its checksum.
+func (c *Client) GetDescriptorInfo(descriptor string) (*types.DescriptorInfo, error) {
+	var info *types.DescriptorInfo
+
+	return info, c.SendReq("getdescriptorinfo", &info, descriptor)
+}
+
+// DeriveAddresses derives the addresses of a descriptor, which must have a checksum. For ranged descriptors,
+// descRange is the end or the [begin, end] of the range to derive, and must be set.
+func (c *Client) DeriveAddresses(descriptor string, descRange []int) ([]string, error) {
+	var addresses []string
+
+	switch len(descRange) {
+	case 0:
+		return addresses, c.SendReq("deriveaddresses", &addresses, descriptor)
+	case 1:
+		return addresses, c.SendReq("deriveaddresses", &addresses, descriptor, descRange[0])
+	default:
+		return addresses, c.SendReq("deriveaddresses", &addresses, descriptor, descRange)
+	}
+}
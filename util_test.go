@@ -0,0 +1,70 @@
+package rpcclient
+
+import (
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_EstimateRawFee(t *testing.T) {
+	var sent []*Request
+	client := newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		sent = append(sent, req)
+
+		return map[string]any{
+			"medium": map[string]any{
+				"feerate": 0.0002,
+				"decay":   0.9952,
+				"scale":   2,
+				"pass":    map[string]any{"startrange": 0.00019, "endrange": 0.0002, "withintarget": 120.5, "totalconfirmed": 124.2, "inmempool": 0, "leftmempool": 1},
+			},
+			"long": map[string]any{
+				"decay":  0.99931,
+				"scale":  24,
+				"errors": []string{"Insufficient data or no feerate found which meets threshold"},
+			},
+		}, nil
+	})
+
+	res, err := client.EstimateRawFee(20, nil)
+	require.NoError(t, err)
+	assert.Nil(t, res.Short)
+	assert.Equal(t, &types.RawFeeEstimate{
+		FeeRate: 0.0002,
+		Decay:   0.9952,
+		Scale:   2,
+		Pass:    &types.FeeRateBucket{StartRange: 0.00019, EndRange: 0.0002, WithinTarget: 120.5, TotalConfirmed: 124.2, LeftMempool: 1},
+	}, res.Medium)
+	assert.Zero(t, res.Long.FeeRate)
+	assert.Len(t, res.Long.Errors, 1)
+	assert.Equal(t, []any{float64(20)}, sent[0].Params)
+
+	threshold := 0.5
+	_, err = client.EstimateRawFee(6, &threshold)
+	require.NoError(t, err)
+	assert.Equal(t, []any{float64(6), 0.5}, sent[1].Params)
+}
+
+func TestClient_DeriveAddresses(t *testing.T) {
+	var sent []*Request
+	client := newTestClient(t, nil, func(req *Request) (any, *RPCError) {
+		sent = append(sent, req)
+
+		return []string{"bcrt1a", "bcrt1b"}, nil
+	})
+
+	addresses, err := client.DeriveAddresses("wpkh(tpub/0/*)#cs", []int{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bcrt1a", "bcrt1b"}, addresses)
+	assert.Equal(t, []any{"wpkh(tpub/0/*)#cs", []any{float64(0), float64(1)}}, sent[0].Params)
+
+	_, err = client.DeriveAddresses("wpkh(tpub/0/*)#cs", []int{1})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"wpkh(tpub/0/*)#cs", float64(1)}, sent[1].Params)
+
+	_, err = client.DeriveAddresses("addr(bcrt1a)#cs", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"addr(bcrt1a)#cs"}, sent[2].Params)
+}
@@ -0,0 +1,679 @@
+// Package utxo keeps the unspent outputs of a watch list of addresses and descriptors up to date, without a wallet.
+//
+//	tracker, err := utxo.New(&utxo.Config{Client: client, Addresses: []string{address}})
+//	err = tracker.Sync()
+//	balance := tracker.Balance()
+//
+// The outputs are found with scantxoutset first. Then the blocks after the scan are followed, rolling back the blocks
+// which are reorganized out of the main chain, and the mempool is followed for unconfirmed outputs and spends.
+package utxo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/types"
+)
+
+// Defaults for the options in Config.
+const (
+	DefaultRange      = 1000
+	DefaultReorgDepth = 100
+	DefaultStoreKey   = "utxo"
+)
+
+// rpcInvalidAddressOrKey is the error code returned by deriveaddresses for descriptors without addresses, and by
+// getrawtransaction for transactions which are no longer in the mempool.
+const rpcInvalidAddressOrKey = -5
+
+var (
+	// ErrNoClient is returned by New when Config.Client is nil.
+	ErrNoClient = errors.New("utxo: no client")
+
+	// ErrEmptyWatchList is returned by New when there are no addresses or descriptors to watch.
+	ErrEmptyWatchList = errors.New("utxo: no addresses or descriptors to watch")
+
+	// ErrScanAborted is returned when scantxoutset didn't complete, eg because it was aborted.
+	ErrScanAborted = errors.New("utxo: scan aborted")
+)
+
+// Config are the options for a Tracker.
+type Config struct {
+	// Client is the client of the node the UTXO set is read from.
+	Client rpcclient.IClient
+
+	// Addresses are the addresses to watch.
+	Addresses []string
+
+	// Descriptors are the output descriptors to watch. Outputs of descriptors without addresses, like raw() and pk(),
+	// are only found by the scan, so receives to them after the scan are missed.
+	Descriptors []string
+
+	// Range is the number of indexes watched of ranged descriptors, starting at 0. Defaults to 1000.
+	Range int
+
+	// ReorgDepth is the number of blocks which can be rolled back. After deeper reorganizations, the UTXO set is scanned
+	// again. Defaults to 100.
+	ReorgDepth int
+
+	// Store persists the state after every Sync, so it survives restarts. The mempool is not persisted.
+	Store rpcclient.CacheStore
+
+	// StoreKey is the key of the state in Store. Defaults to DefaultStoreKey.
+	StoreKey string
+}
+
+// Balance is the value of the watched outputs.
+type Balance struct {
+	// Confirmed is the value in BTC of the outputs in the main chain which are not spent by the mempool.
+	Confirmed float64
+	// Unconfirmed is the value in BTC of the outputs in the mempool which are not spent by the mempool.
+	Unconfirmed float64
+}
+
+// state is the persisted state of a Tracker.
+type state struct {
+	// Watch is the watch list the state was built for.
+	Watch []string `json:"watch"`
+	Range int      `json:"range"`
+
+	Height    int    `json:"height"`
+	BestBlock string `json:"bestblock"`
+
+	// Scripts and Addresses map the watched scriptPubKeys in hex and addresses to their descriptors.
+	Scripts   map[string]string `json:"scripts"`
+	Addresses map[string]string `json:"addresses"`
+
+	// Unspents are the outputs in the main chain, by outpoint.
+	Unspents map[string]*types.ScanTxOutSetUnspent `json:"unspents"`
+
+	// Undo holds how to roll back the last blocks, the last one being the best block.
+	Undo []*blockUndo `json:"undo"`
+}
+
+// blockUndo holds the changes a block made to the unspent outputs.
+type blockUndo struct {
+	Hash    string                       `json:"hash"`
+	Prev    string                       `json:"prev"`
+	Created []string                     `json:"created"`
+	Spent   []*types.ScanTxOutSetUnspent `json:"spent"`
+}
+
+// mempoolTx holds what a transaction in the mempool does to the watched outputs.
+type mempoolTx struct {
+	receives []*types.ScanTxOutSetUnspent
+	// inputs are the outpoints spent by the transaction, and spends are the ones which were watched at the last Sync.
+	inputs []string
+	spends []string
+}
+
+// Tracker keeps the unspent outputs of a watch list up to date. It is safe for concurrent use.
+type Tracker struct {
+	config *Config
+	watch  []string
+
+	// syncMu serializes Sync.
+	syncMu sync.Mutex
+
+	mu    sync.RWMutex
+	state *state
+	// mempool holds the transactions in the mempool at the last Sync, so every transaction is only requested once.
+	mempool map[string]*mempoolTx
+}
+
+// New creates a Tracker for the watch list of config. Nothing is requested from the node until Sync is called. If the
+// store has a state for the same watch list, it is loaded.
+func New(config *Config) (*Tracker, error) {
+	if config.Client == nil {
+		return nil, ErrNoClient
+	}
+
+	if len(config.Addresses) == 0 && len(config.Descriptors) == 0 {
+		return nil, ErrEmptyWatchList
+	}
+
+	cfg := *config
+	if cfg.Range <= 0 {
+		cfg.Range = DefaultRange
+	}
+
+	if cfg.ReorgDepth <= 0 {
+		cfg.ReorgDepth = DefaultReorgDepth
+	}
+
+	if cfg.StoreKey == "" {
+		cfg.StoreKey = DefaultStoreKey
+	}
+
+	t := &Tracker{config: &cfg, mempool: map[string]*mempoolTx{}}
+
+	for _, address := range cfg.Addresses {
+		t.watch = append(t.watch, "addr("+address+")")
+	}
+
+	t.watch = append(t.watch, cfg.Descriptors...)
+
+	if cfg.Store != nil {
+		if data, ok := cfg.Store.Get(cfg.StoreKey); ok {
+			st := &state{}
+			if err := json.Unmarshal(data, st); err == nil && st.matches(t.watch, cfg.Range) {
+				t.state = st
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// matches reports whether the state was built for watch and descRange.
+func (s *state) matches(watch []string, descRange int) bool {
+	if s.Range != descRange || len(s.Watch) != len(watch) || s.Unspents == nil {
+		return false
+	}
+
+	for idx := range watch {
+		if s.Watch[idx] != watch[idx] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sync brings the tracker up to date with the node: the UTXO set is scanned if there is no state yet, then the new
+// blocks are applied, blocks which are no longer in the main chain are rolled back, and the mempool is read again.
+func (t *Tracker) Sync() error {
+	t.syncMu.Lock()
+	defer t.syncMu.Unlock()
+
+	t.mu.RLock()
+	scanned := t.state != nil
+	t.mu.RUnlock()
+
+	if !scanned {
+		if err := t.scan(); err != nil {
+			return err
+		}
+	}
+
+	if err := t.syncBlocks(); err != nil {
+		return err
+	}
+
+	if err := t.syncMempool(); err != nil {
+		return err
+	}
+
+	return t.save()
+}
+
+// Run calls Sync every interval until ctx is done or Sync fails.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Sync(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Tip returns the height and hash of the last block applied. It is zero until the first Sync.
+func (t *Tracker) Tip() (int, string) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.state == nil {
+		return 0, ""
+	}
+
+	return t.state.Height, t.state.BestBlock
+}
+
+// Balance returns the confirmed and unconfirmed value of the watched outputs.
+func (t *Tracker) Balance() *Balance {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var confirmed, unconfirmed int64
+
+	spent := t.mempoolSpends()
+
+	if t.state != nil {
+		for key, unspent := range t.state.Unspents {
+			if !spent[key] {
+				confirmed += sats(unspent.Amount)
+			}
+		}
+	}
+
+	for _, tx := range t.mempool {
+		for _, unspent := range tx.receives {
+			if !spent[outpoint(unspent.Txid, unspent.Vout)] {
+				unconfirmed += sats(unspent.Amount)
+			}
+		}
+	}
+
+	return &Balance{Confirmed: btc(confirmed), Unconfirmed: btc(unconfirmed)}
+}
+
+// Unspents returns the watched outputs which are not spent by the mempool, including the outputs in the mempool,
+// which have a height of 0. They are sorted by height, with the unconfirmed outputs last.
+func (t *Tracker) Unspents() []*types.ScanTxOutSetUnspent {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var unspents []*types.ScanTxOutSetUnspent
+
+	spent := t.mempoolSpends()
+
+	if t.state != nil {
+		for key, unspent := range t.state.Unspents {
+			if !spent[key] {
+				unspentCopy := *unspent
+				unspents = append(unspents, &unspentCopy)
+			}
+		}
+	}
+
+	for _, tx := range t.mempool {
+		for _, unspent := range tx.receives {
+			if !spent[outpoint(unspent.Txid, unspent.Vout)] {
+				unspentCopy := *unspent
+				unspents = append(unspents, &unspentCopy)
+			}
+		}
+	}
+
+	sort.Slice(unspents, func(i, j int) bool {
+		a, b := unspents[i], unspents[j]
+		if a.Height != b.Height {
+			return a.Height != 0 && (b.Height == 0 || a.Height < b.Height)
+		}
+
+		if a.Txid != b.Txid {
+			return a.Txid < b.Txid
+		}
+
+		return a.Vout < b.Vout
+	})
+
+	return unspents
+}
+
+// mempoolSpends returns the outpoints spent by the mempool. t.mu must be held.
+func (t *Tracker) mempoolSpends() map[string]bool {
+	spent := map[string]bool{}
+
+	for _, tx := range t.mempool {
+		for _, key := range tx.spends {
+			spent[key] = true
+		}
+	}
+
+	return spent
+}
+
+// scan builds the state from scantxoutset, and derives the addresses of the watch list.
+func (t *Tracker) scan() error {
+	st := &state{
+		Watch:     t.watch,
+		Range:     t.config.Range,
+		Scripts:   map[string]string{},
+		Addresses: map[string]string{},
+		Unspents:  map[string]*types.ScanTxOutSetUnspent{},
+	}
+
+	client := t.config.Client
+	objs := make([]*types.ScanTxOutSetObject, len(t.watch))
+	descRange := []int{0, t.config.Range - 1}
+
+	for idx, desc := range t.watch {
+		info, err := client.GetDescriptorInfo(desc)
+		if err != nil {
+			return fmt.Errorf("utxo: descriptor %v: %w", desc, err)
+		}
+
+		var addresses []string
+
+		if info.IsRange {
+			objs[idx] = &types.ScanTxOutSetObject{Desc: info.Descriptor, RangeN: descRange}
+			addresses, err = client.DeriveAddresses(info.Descriptor, descRange)
+		} else {
+			objs[idx] = &types.ScanTxOutSetObject{Descriptor: info.Descriptor}
+			addresses, err = client.DeriveAddresses(info.Descriptor, nil)
+		}
+
+		var rpcErr *rpcclient.RPCError
+		if err != nil && !(errors.As(err, &rpcErr) && rpcErr.Code == rpcInvalidAddressOrKey) {
+			return fmt.Errorf("utxo: descriptor %v: %w", desc, err)
+		}
+
+		for _, address := range addresses {
+			st.Addresses[address] = info.Descriptor
+		}
+	}
+
+	res, err := client.ScanTxOutSet(types.ScanTxOutSetStart, objs...)
+	if err != nil {
+		return err
+	}
+
+	if res == nil || !res.Success {
+		return ErrScanAborted
+	}
+
+	st.Height = res.Height
+	st.BestBlock = res.BestBlock
+
+	for _, unspent := range res.Unspents {
+		st.Scripts[unspent.ScriptPubKey] = unspent.Desc
+		st.Unspents[outpoint(unspent.Txid, unspent.Vout)] = unspent
+	}
+
+	t.mu.Lock()
+	t.state = st
+	t.mu.Unlock()
+
+	return nil
+}
+
+// syncBlocks applies the blocks after the best block, and rolls back the blocks which are no longer in the main chain.
+func (t *Tracker) syncBlocks() error {
+	client := t.config.Client
+
+	count, err := client.GetBlockCount()
+	if err != nil {
+		return err
+	}
+
+	for {
+		height, bestBlock := t.Tip()
+
+		if int64(height) > count {
+			if err := t.rollback(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		hash, err := client.GetBlockHash(height)
+		if err != nil {
+			return err
+		}
+
+		if hash != bestBlock {
+			if err := t.rollback(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if int64(height) == count {
+			return nil
+		}
+
+		next, err := client.GetBlockHash(height + 1)
+		if err != nil {
+			return err
+		}
+
+		block, err := client.GetBlockVerboseTx(next)
+		if err != nil {
+			return err
+		}
+
+		// The chain changed since the hash of the best block was checked.
+		if block == nil || block.BlockHeader == nil || block.Previousblockhash != bestBlock {
+			continue
+		}
+
+		t.apply(block)
+	}
+}
+
+// apply applies the spends and receives of block, which follows the best block.
+func (t *Tracker) apply(block *types.BlockTx) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st := t.state
+	undo := &blockUndo{Hash: block.Hash, Prev: block.Previousblockhash}
+
+	for _, tx := range block.Tx {
+		for _, in := range tx.Vin {
+			key := outpoint(in.Txid, in.Vout)
+			if unspent, ok := st.Unspents[key]; ok {
+				undo.Spent = append(undo.Spent, unspent)
+				delete(st.Unspents, key)
+			}
+		}
+
+		for _, unspent := range st.receives(tx, block.Height) {
+			key := outpoint(unspent.Txid, unspent.Vout)
+			undo.Created = append(undo.Created, key)
+			st.Unspents[key] = unspent
+		}
+
+		// The transaction is confirmed now, so it must not be counted as unconfirmed until the mempool is read again.
+		delete(t.mempool, tx.Txid)
+	}
+
+	st.Height = block.Height
+	st.BestBlock = block.Hash
+	st.Undo = append(st.Undo, undo)
+
+	if len(st.Undo) > t.config.ReorgDepth {
+		st.Undo = append([]*blockUndo(nil), st.Undo[len(st.Undo)-t.config.ReorgDepth:]...)
+	}
+}
+
+// rollback rolls back the best block. Without a way to roll back, the UTXO set is scanned again.
+func (t *Tracker) rollback() error {
+	t.mu.Lock()
+
+	st := t.state
+	if len(st.Undo) == 0 {
+		t.mu.Unlock()
+
+		return t.scan()
+	}
+
+	undo := st.Undo[len(st.Undo)-1]
+	st.Undo = st.Undo[:len(st.Undo)-1]
+
+	// Outputs created and spent in the block are restored and removed again.
+	for _, unspent := range undo.Spent {
+		st.Unspents[outpoint(unspent.Txid, unspent.Vout)] = unspent
+	}
+
+	for _, key := range undo.Created {
+		delete(st.Unspents, key)
+	}
+
+	st.Height--
+	st.BestBlock = undo.Prev
+
+	t.mu.Unlock()
+
+	return nil
+}
+
+// syncMempool reads the transactions which entered the mempool since the last Sync, and forgets the ones which left.
+func (t *Tracker) syncMempool() error {
+	client := t.config.Client
+
+	txids, err := client.GetRawMempool()
+	if err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	inMempool := make(map[string]bool, len(txids))
+	var added []string
+
+	for _, txid := range txids {
+		inMempool[txid] = true
+
+		if _, ok := t.mempool[txid]; !ok {
+			added = append(added, txid)
+		}
+	}
+	t.mu.RUnlock()
+
+	txs := make([]*types.Transaction, 0, len(added))
+
+	for _, txid := range added {
+		tx, err := client.GetRawTransactionVerbose(txid, nil)
+
+		var rpcErr *rpcclient.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == rpcInvalidAddressOrKey {
+			// The transaction left the mempool in the meantime.
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		txs = append(txs, tx)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for txid := range t.mempool {
+		if !inMempool[txid] {
+			delete(t.mempool, txid)
+		}
+	}
+
+	// The receives of all transactions are found first, as children may come before their parents.
+	received := map[string]bool{}
+
+	for _, mtx := range t.mempool {
+		for _, unspent := range mtx.receives {
+			received[outpoint(unspent.Txid, unspent.Vout)] = true
+		}
+	}
+
+	for _, tx := range txs {
+		entry := &mempoolTx{receives: t.state.receives(tx, 0)}
+
+		for _, in := range tx.Vin {
+			entry.inputs = append(entry.inputs, outpoint(in.Txid, in.Vout))
+		}
+
+		for _, unspent := range entry.receives {
+			received[outpoint(unspent.Txid, unspent.Vout)] = true
+		}
+
+		t.mempool[tx.Txid] = entry
+	}
+
+	// The spends of every transaction are found again, as the outputs they spend may have been confirmed since.
+	for _, entry := range t.mempool {
+		entry.spends = nil
+
+		for _, key := range entry.inputs {
+			if _, ok := t.state.Unspents[key]; ok || received[key] {
+				entry.spends = append(entry.spends, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// receives returns the outputs of tx which pay to the watch list, at height.
+func (s *state) receives(tx *types.Transaction, height int) []*types.ScanTxOutSetUnspent {
+	var unspents []*types.ScanTxOutSetUnspent
+
+	for _, out := range tx.Vout {
+		if out.ScriptPubKey == nil {
+			continue
+		}
+
+		script := scriptHex(out.ScriptPubKey)
+
+		desc, ok := s.Scripts[script]
+		if !ok {
+			desc, ok = s.Addresses[out.ScriptPubKey.GetAddress()]
+		}
+
+		if !ok {
+			continue
+		}
+
+		unspents = append(unspents, &types.ScanTxOutSetUnspent{
+			Txid:         tx.Txid,
+			Vout:         out.N,
+			ScriptPubKey: script,
+			Desc:         desc,
+			Amount:       out.Value,
+			Height:       height,
+		})
+	}
+
+	return unspents
+}
+
+// save persists the state to the store.
+func (t *Tracker) save() error {
+	if t.config.Store == nil {
+		return nil
+	}
+
+	t.mu.RLock()
+	data, err := json.Marshal(t.state)
+	t.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	t.config.Store.Set(t.config.StoreKey, data)
+
+	return nil
+}
+
+// outpoint returns the key of an output.
+func outpoint(txid string, vout int) string {
+	return fmt.Sprintf("%v:%v", txid, vout)
+}
+
+// scriptHex returns the hex of an output script, or "" if it's missing.
+func scriptHex(spk *types.ScriptPubKey) string {
+	if spk == nil || spk.RedeemScript == nil || spk.RedeemScript.ScriptSig == nil {
+		return ""
+	}
+
+	return spk.Hex
+}
+
+// sats converts an amount in BTC to satoshis.
+func sats(amount float64) int64 {
+	return int64(math.Round(amount * 1e8))
+}
+
+// btc converts an amount in satoshis to BTC.
+func btc(amount int64) float64 {
+	return float64(amount) / 1e8
+}
@@ -0,0 +1,336 @@
+package utxo
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/omarhachach/rpcclient-core"
+	"github.com/omarhachach/rpcclient-core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNode is a node whose chain and mempool are set by the test. Its UTXO set has unspents at the block scanHeight.
+type testNode struct {
+	blocks     []*types.BlockTx
+	mempool    map[string]*types.Transaction
+	scanHeight int
+	unspents   []*types.ScanTxOutSetUnspent
+
+	calls map[string]int
+}
+
+// addresses are the scriptPubKeys of the addresses of the test.
+var addresses = map[string]string{
+	"bcrt1watch":   "0014aa",
+	"bcrt1ranged0": "0014b0",
+	"bcrt1ranged1": "0014b1",
+	"bcrt1other":   "0014ff",
+}
+
+// testTx returns a transaction spending the outpoints ins, paying amounts to addresses in the order of outs.
+func testTx(txid string, ins []string, outs ...any) *types.Transaction {
+	tx := &types.Transaction{Txid: txid}
+
+	for _, in := range ins {
+		txid, vout, _ := strings.Cut(in, ":")
+		tx.Vin = append(tx.Vin, &types.Vin{Txid: txid, Vout: int(vout[0] - '0')})
+	}
+
+	for idx := 0; idx < len(outs); idx += 2 {
+		address := outs[idx].(string)
+		tx.Vout = append(tx.Vout, &types.Vout{
+			Value: outs[idx+1].(float64),
+			N:     idx / 2,
+			ScriptPubKey: &types.ScriptPubKey{
+				RedeemScript: &types.RedeemScript{ScriptSig: &types.ScriptSig{Hex: addresses[address]}},
+				Address:      address,
+			},
+		})
+	}
+
+	return tx
+}
+
+// mine adds a block with txs on top of the block at height-1.
+func (n *testNode) mine(height int, hash string, txs ...*types.Transaction) {
+	block := &types.BlockTx{BlockHeader: &types.BlockHeader{Hash: hash, Height: height}, Tx: txs}
+	if height > 0 {
+		block.Previousblockhash = n.blocks[height-1].Hash
+	}
+
+	n.blocks = append(n.blocks[:height], block)
+}
+
+func (n *testNode) client(t *testing.T) *rpcclient.Client {
+	n.calls = map[string]int{}
+
+	return rpcclient.NewWithSendFunc(func(ctx context.Context, method string, result any, params ...any) error {
+		var res any
+
+		n.calls[method]++
+
+		switch method {
+		case "getdescriptorinfo":
+			desc := params[0].(string)
+			res = &types.DescriptorInfo{Descriptor: desc + "#check", IsRange: strings.Contains(desc, "*")}
+		case "deriveaddresses":
+			switch {
+			case strings.HasPrefix(params[0].(string), "addr(bcrt1watch)"):
+				res = []string{"bcrt1watch"}
+			case strings.HasPrefix(params[0].(string), "wpkh("):
+				assert.Equal(t, []int{0, 1}, params[1])
+				res = []string{"bcrt1ranged0", "bcrt1ranged1"}
+			default:
+				return &rpcclient.RPCError{Code: -5, Message: "Descriptor does not have a corresponding address"}
+			}
+		case "scantxoutset":
+			assert.Equal(t, types.ScanTxOutSetStart, params[0])
+			res = &types.ScanTxOutSetDetails{
+				Success:   true,
+				Height:    n.scanHeight,
+				BestBlock: n.blocks[n.scanHeight].Hash,
+				Unspents:  n.unspents,
+			}
+		case "getblockcount":
+			res = len(n.blocks) - 1
+		case "getblockhash":
+			height := params[0].(int)
+			if height >= len(n.blocks) {
+				return &rpcclient.RPCError{Code: -8, Message: "Block height out of range"}
+			}
+
+			res = n.blocks[height].Hash
+		case "getblock":
+			for _, block := range n.blocks {
+				if block.Hash == params[0] {
+					res = block
+				}
+			}
+		case "getrawmempool":
+			txids := []string{}
+			for txid := range n.mempool {
+				txids = append(txids, txid)
+			}
+
+			res = txids
+		case "getrawtransaction":
+			tx, ok := n.mempool[params[0].(string)]
+			if !ok {
+				return &rpcclient.RPCError{Code: -5, Message: "No such mempool or blockchain transaction"}
+			}
+
+			res = tx
+		default:
+			return &rpcclient.RPCError{Code: -32601, Message: "Method not found"}
+		}
+
+		data, err := json.Marshal(res)
+		require.NoError(t, err)
+
+		return json.Unmarshal(data, result)
+	})
+}
+
+// testChain returns a node with 3 blocks, scanned at block 1.
+func testChain() *testNode {
+	node := &testNode{
+		scanHeight: 1,
+		unspents: []*types.ScanTxOutSetUnspent{
+			{Txid: "t0", Vout: 0, ScriptPubKey: "0014aa", Desc: "addr(bcrt1watch)#check", Amount: 1, Height: 1},
+			{Txid: "t0", Vout: 1, ScriptPubKey: "51", Desc: "raw(51)#check", Amount: 0.5, Height: 1},
+		},
+	}
+
+	node.mine(0, "b0")
+	node.mine(1, "b1", testTx("t0", nil, "bcrt1watch", 1.0))
+	node.mine(2, "b2",
+		testTx("t2", []string{"t0:0"}, "bcrt1other", 0.3, "bcrt1ranged1", 0.69),
+		// t3 spends an output created in the same block.
+		testTx("t3", []string{"t2:1"}, "bcrt1watch", 0.68),
+	)
+
+	return node
+}
+
+// outpoints returns the outpoints of unspents.
+func outpoints(unspents []*types.ScanTxOutSetUnspent) []string {
+	keys := make([]string, len(unspents))
+	for idx, unspent := range unspents {
+		keys[idx] = outpoint(unspent.Txid, unspent.Vout)
+	}
+
+	return keys
+}
+
+func testConfig(client rpcclient.IClient) *Config {
+	return &Config{
+		Client:      client,
+		Addresses:   []string{"bcrt1watch"},
+		Descriptors: []string{"wpkh(tpub/0/*)", "raw(51)"},
+		Range:       2,
+	}
+}
+
+func TestTracker_Sync(t *testing.T) {
+	node := testChain()
+
+	tracker, err := New(testConfig(node.client(t)))
+	require.NoError(t, err)
+	require.NoError(t, tracker.Sync())
+
+	height, hash := tracker.Tip()
+	assert.Equal(t, 2, height)
+	assert.Equal(t, "b2", hash)
+
+	assert.Equal(t, []*types.ScanTxOutSetUnspent{
+		{Txid: "t0", Vout: 1, ScriptPubKey: "51", Desc: "raw(51)#check", Amount: 0.5, Height: 1},
+		{Txid: "t3", Vout: 0, ScriptPubKey: "0014aa", Desc: "addr(bcrt1watch)#check", Amount: 0.68, Height: 2},
+	}, tracker.Unspents())
+	assert.Equal(t, &Balance{Confirmed: 1.18}, tracker.Balance())
+
+	// m2 spends an output of m1, but comes first.
+	node.mempool = map[string]*types.Transaction{
+		"m2": testTx("m2", []string{"m1:0"}, "bcrt1ranged0", 0.19),
+		"m1": testTx("m1", []string{"t3:0"}, "bcrt1watch", 0.2, "bcrt1other", 0.47),
+		"m3": testTx("m3", []string{"x:0"}, "bcrt1other", 1.0),
+	}
+
+	require.NoError(t, tracker.Sync())
+	assert.Equal(t, []string{"t0:1", "m2:0"}, outpoints(tracker.Unspents()))
+	assert.Equal(t, &Balance{Confirmed: 0.5, Unconfirmed: 0.19}, tracker.Balance())
+
+	// The mempool is only requested again for new transactions.
+	require.NoError(t, tracker.Sync())
+	assert.Equal(t, 3, node.calls["getrawtransaction"])
+
+	// m1 is confirmed, and m2 is dropped.
+	node.mine(3, "b3", node.mempool["m1"])
+	node.mempool = nil
+
+	require.NoError(t, tracker.Sync())
+	assert.Equal(t, []string{"t0:1", "m1:0"}, outpoints(tracker.Unspents()))
+	assert.Equal(t, &Balance{Confirmed: 0.7}, tracker.Balance())
+	assert.Equal(t, 1, node.calls["scantxoutset"])
+}
+
+func TestTracker_MempoolParentConfirmed(t *testing.T) {
+	node := testChain()
+
+	tracker, err := New(testConfig(node.client(t)))
+	require.NoError(t, err)
+	require.NoError(t, tracker.Sync())
+
+	// m1 is confirmed between the block and mempool requests, so the tracker only sees its child m2.
+	m1 := testTx("m1", []string{"t3:0"}, "bcrt1watch", 0.2, "bcrt1other", 0.47)
+	node.mempool = map[string]*types.Transaction{
+		"m2": testTx("m2", []string{"m1:0"}, "bcrt1ranged0", 0.19),
+	}
+
+	require.NoError(t, tracker.Sync())
+	assert.Equal(t, []string{"t0:1", "t3:0", "m2:0"}, outpoints(tracker.Unspents()))
+
+	// Once the block with m1 is seen, m2 spends its output.
+	node.mine(3, "b3", m1)
+
+	require.NoError(t, tracker.Sync())
+	assert.Equal(t, []string{"t0:1", "m2:0"}, outpoints(tracker.Unspents()))
+	assert.Equal(t, &Balance{Confirmed: 0.5, Unconfirmed: 0.19}, tracker.Balance())
+	assert.Equal(t, 1, node.calls["getrawtransaction"])
+}
+
+func TestTracker_Reorg(t *testing.T) {
+	node := testChain()
+	node.mine(3, "b3", testTx("t4", []string{"t3:0"}, "bcrt1ranged0", 0.6))
+
+	tracker, err := New(testConfig(node.client(t)))
+	require.NoError(t, err)
+	require.NoError(t, tracker.Sync())
+	assert.Equal(t, []string{"t0:1", "t4:0"}, outpoints(tracker.Unspents()))
+
+	// Blocks 2 and 3 are replaced by a longer chain without t3.
+	node.mine(2, "b2x", testTx("t2", []string{"t0:0"}, "bcrt1other", 0.3, "bcrt1ranged1", 0.69))
+	node.mine(3, "b3x")
+	node.mine(4, "b4x")
+
+	require.NoError(t, tracker.Sync())
+
+	height, hash := tracker.Tip()
+	assert.Equal(t, 4, height)
+	assert.Equal(t, "b4x", hash)
+	assert.Equal(t, []string{"t0:1", "t2:1"}, outpoints(tracker.Unspents()))
+	assert.Equal(t, &Balance{Confirmed: 1.19}, tracker.Balance())
+
+	// The chain gets shorter again.
+	node.blocks = node.blocks[:3]
+
+	require.NoError(t, tracker.Sync())
+
+	height, _ = tracker.Tip()
+	assert.Equal(t, 2, height)
+	assert.Equal(t, 1, node.calls["scantxoutset"])
+
+	// A reorganization deeper than ReorgDepth scans again.
+	config := testConfig(node.client(t))
+	config.ReorgDepth = 1
+
+	tracker, err = New(config)
+	require.NoError(t, err)
+	require.NoError(t, tracker.Sync())
+
+	node.mine(1, "b1y")
+	node.mine(2, "b2y")
+	node.scanHeight = 2
+	node.unspents = nil
+
+	require.NoError(t, tracker.Sync())
+
+	_, hash = tracker.Tip()
+	assert.Equal(t, "b2y", hash)
+	assert.Empty(t, tracker.Unspents())
+	assert.Equal(t, 2, node.calls["scantxoutset"])
+}
+
+func TestTracker_Store(t *testing.T) {
+	node := testChain()
+	client := node.client(t)
+
+	config := testConfig(client)
+	config.Store = rpcclient.NewLRUStore(10)
+
+	tracker, err := New(config)
+	require.NoError(t, err)
+	require.NoError(t, tracker.Sync())
+
+	// The state is loaded, so there is no scan, and new blocks are applied.
+	node.mine(3, "b3", testTx("t4", []string{"t3:0"}, "bcrt1ranged0", 0.6))
+
+	tracker, err = New(config)
+	require.NoError(t, err)
+
+	height, _ := tracker.Tip()
+	assert.Equal(t, 2, height)
+
+	require.NoError(t, tracker.Sync())
+	assert.Equal(t, []string{"t0:1", "t4:0"}, outpoints(tracker.Unspents()))
+	assert.Equal(t, 1, node.calls["scantxoutset"])
+
+	// The state of another watch list isn't used.
+	config.Addresses = nil
+
+	tracker, err = New(config)
+	require.NoError(t, err)
+
+	height, _ = tracker.Tip()
+	assert.Zero(t, height)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&Config{Addresses: []string{"bcrt1watch"}})
+	assert.ErrorIs(t, err, ErrNoClient)
+
+	_, err = New(&Config{Client: testChain().client(t)})
+	assert.ErrorIs(t, err, ErrEmptyWatchList)
+}